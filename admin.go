@@ -0,0 +1,373 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/keenfury/axenda/audit"
+	"github.com/keenfury/axenda/config"
+	d "github.com/keenfury/axenda/discovery"
+	fr "github.com/keenfury/axenda/frequency"
+	h "github.com/keenfury/axenda/history"
+	j "github.com/keenfury/axenda/job"
+	"github.com/keenfury/axenda/sla"
+	"github.com/keenfury/axenda/util"
+)
+
+/*
+Admin API, served when SCH_ADMIN_ADDR is set, if SCH_ADMIN_TOKEN is set every request needs "Authorization: Bearer <token>",
+without a token SCH_ADMIN_ADDR has to bind to localhost
+
+	GET    /jobs                  list the jobs of the discovery adapter
+	POST   /jobs                  create a job
+	GET    /jobs/{token}          get a job
+	PUT    /jobs/{token}          update a job
+	DELETE /jobs/{token}          delete a job
+	POST   /jobs/{token}/pause    set the job inactive
+	POST   /jobs/{token}/resume   set the job active
+	POST   /jobs/{token}/trigger  run the job now, outside of its schedule (the schedule is left as is)
+	GET    /status                the jobs the scheduler currently knows about with their status
+	GET    /simulate              replay the schedule, ?job=TOKEN (default all) &from=RFC3339 (default now) &window=24h
+	GET    /history/export        the run history as csv or json lines, see reports.go
+	GET    /history/report        success rate, schedule lag and durations per job of the run history
+	GET    /audit                 the audit log, ?job=TOKEN &actor=NAME &action=update &from=RFC3339 &to=RFC3339
+
+Changes and manual actions are audited with the actor of the X-Actor header ("admin" when not set)
+
+Managing jobs needs a discovery adapter that is a JobStore (File or DB)
+*/
+
+// maxJobBody: the largest job body the admin api reads
+const maxJobBody = 1 << 20
+
+type (
+	JobStore interface {
+		ListJobs() ([]j.Job, error)
+		AddJob(j.Job) error
+		UpdateJob(j.Job) error
+		DeleteJob(string) error
+	}
+
+	JobStatusView struct {
+		Token       string         `json:"token"`
+		JobName     string         `json:"job_name"`
+		RunTime     time.Time      `json:"run_time"`
+		Status      j.Status       `json:"status"`
+		Error       string         `json:"error,omitempty"`
+		Transitions []j.Transition `json:"transitions"`
+	}
+)
+
+// AdminHandler: all the admin routes
+func AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jobs", AdminJobsHandler)
+	mux.HandleFunc("/jobs/", AdminJobHandler)
+	mux.HandleFunc("/status", AdminStatusHandler)
+	mux.HandleFunc("/simulate", AdminSimulateHandler)
+	mux.HandleFunc("/audit", AdminAuditHandler)
+	mux.HandleFunc("/history/", AdminHistoryHandler)
+	mux.HandleFunc("/workflows", AdminWorkflowsHandler)
+	mux.HandleFunc("/workflows/", AdminWorkflowsHandler)
+	return AdminAuth(mux)
+}
+
+// AdminAuth: check the bearer token when SCH_ADMIN_TOKEN is set, in constant time
+func AdminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		adminToken := config.Get(&config.AdminToken)
+		authorization := []byte(req.Header.Get("Authorization"))
+		if len(adminToken) > 0 && subtle.ConstantTimeCompare(authorization, []byte("Bearer "+adminToken)) != 1 {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("Unauthorized"))
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+func AdminJobsHandler(w http.ResponseWriter, req *http.Request) {
+	store, ok := CurrentDiscovery().(JobStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, fmt.Errorf("Discovery does not manage jobs: %s", CurrentDiscovery().WhichDiscovery()))
+		return
+	}
+	switch req.Method {
+	case http.MethodGet:
+		jobs, errList := store.ListJobs()
+		if errList != nil {
+			writeError(w, http.StatusInternalServerError, errList)
+			return
+		}
+		writeJSON(w, http.StatusOK, jobs)
+	case http.MethodPost:
+		job, code, errJob := readJob(w, req)
+		if errJob != nil {
+			writeError(w, code, errJob)
+			return
+		}
+		if errWorkflow := CheckWorkflow(store, withJob(job)); errWorkflow != nil {
+			writeError(w, http.StatusBadRequest, errWorkflow)
+			return
+		}
+		if errAdd := store.AddJob(job); errAdd != nil {
+			writeError(w, storeErrorCode(errAdd), errAdd)
+			return
+		}
+		RecordAudit(adminActor(req), audit.SourceAPI, audit.ActionCreate, nil, &job)
+		logAdapter.Info("Admin: created job", "token", job.Token, "job_name", job.JobName)
+		writeJSON(w, http.StatusCreated, job)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method not allowed"))
+	}
+}
+
+func AdminJobHandler(w http.ResponseWriter, req *http.Request) {
+	store, ok := CurrentDiscovery().(JobStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, fmt.Errorf("Discovery does not manage jobs: %s", CurrentDiscovery().WhichDiscovery()))
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, "/jobs/"), "/"), "/")
+	token, action := parts[0], ""
+	if len(parts) > 1 {
+		action = parts[1]
+	}
+	if len(token) == 0 || len(parts) > 2 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("Not found"))
+		return
+	}
+	if len(action) > 0 {
+		if req.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method not allowed"))
+			return
+		}
+		adminAction(w, store, token, action, adminActor(req))
+		return
+	}
+	switch req.Method {
+	case http.MethodGet:
+		job, errFind := FindJob(store, token)
+		if errFind != nil {
+			writeError(w, storeErrorCode(errFind), errFind)
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	case http.MethodPut:
+		job, code, errJob := readJob(w, req)
+		if errJob != nil {
+			writeError(w, code, errJob)
+			return
+		}
+		if job.Token != token {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("Token in body does not match url"))
+			return
+		}
+		if errWorkflow := CheckWorkflow(store, withJob(job)); errWorkflow != nil {
+			writeError(w, http.StatusBadRequest, errWorkflow)
+			return
+		}
+		before, errFind := FindJob(store, token)
+		if errFind != nil {
+			writeError(w, storeErrorCode(errFind), errFind)
+			return
+		}
+		if errUpdate := store.UpdateJob(job); errUpdate != nil {
+			writeError(w, storeErrorCode(errUpdate), errUpdate)
+			return
+		}
+		RecordAudit(adminActor(req), audit.SourceAPI, audit.ActionUpdate, &before, &job)
+		// pick up the new definition on the next discovery
+		JobRemoveCh <- job
+		logAdapter.Info("Admin: updated job", "token", job.Token, "job_name", job.JobName)
+		writeJSON(w, http.StatusOK, job)
+	case http.MethodDelete:
+		if errWorkflow := CheckWorkflow(store, withoutJob(token)); errWorkflow != nil {
+			writeError(w, http.StatusConflict, errWorkflow)
+			return
+		}
+		before, errFind := FindJob(store, token)
+		if errFind != nil {
+			writeError(w, storeErrorCode(errFind), errFind)
+			return
+		}
+		if errDelete := store.DeleteJob(token); errDelete != nil {
+			writeError(w, storeErrorCode(errDelete), errDelete)
+			return
+		}
+		RecordAudit(adminActor(req), audit.SourceAPI, audit.ActionDelete, &before, nil)
+		JobRemoveCh <- j.Job{Token: token}
+		logAdapter.Info("Admin: deleted job", "token", token)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method not allowed"))
+	}
+}
+
+func adminAction(w http.ResponseWriter, store JobStore, token, action, actor string) {
+	job, errFind := FindJob(store, token)
+	if errFind != nil {
+		writeError(w, storeErrorCode(errFind), errFind)
+		return
+	}
+	switch action {
+	case "pause", "resume":
+		before := job
+		job.Active = action == "resume"
+		if errUpdate := store.UpdateJob(job); errUpdate != nil {
+			writeError(w, storeErrorCode(errUpdate), errUpdate)
+			return
+		}
+		RecordAudit(actor, audit.SourceAPI, action, &before, &job)
+		if !job.Active {
+			JobRemoveCh <- job
+		}
+		logAdapter.Info("Admin: "+action+" job", "token", token)
+		writeJSON(w, http.StatusOK, job)
+	case "trigger":
+		RecordAudit(actor, audit.SourceAPI, audit.ActionTrigger, &job, &job)
+		goInFlight(func() { RunManual(job) })
+		logAdapter.Info("Admin: triggered job", "token", token)
+		writeJSON(w, http.StatusAccepted, job)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("Unknown action: %s", action))
+	}
+}
+
+func AdminStatusHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method not allowed"))
+		return
+	}
+	views := []JobStatusView{}
+	for _, job := range CurrentJobs() {
+		views = append(views, JobStatusView{Token: job.Token, JobName: job.JobName, RunTime: job.RunTime, Status: job.Status, Error: job.Error, Transitions: job.Transitions})
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+func AdminSimulateHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method not allowed"))
+		return
+	}
+	query := req.URL.Query()
+	events, errSim := Simulate(query.Get("job"), query.Get("from"), query.Get("window"))
+	if errSim != nil {
+		writeError(w, http.StatusBadRequest, errSim)
+		return
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// FindJob: look up a job by token in the store
+func FindJob(store JobStore, token string) (job j.Job, err error) {
+	jobs, errList := store.ListJobs()
+	if errList != nil {
+		err = errList
+		return
+	}
+	for _, js := range jobs {
+		if js.Token == token {
+			job = js
+			return
+		}
+	}
+	err = d.ErrJobNotFound
+	return
+}
+
+// ListAllJobs: all the jobs of a JobStore, or for other adapters the jobs discovery hands out as due by until
+func ListAllJobs(until time.Time) ([]j.Job, error) {
+	if store, ok := CurrentDiscovery().(JobStore); ok {
+		return store.ListJobs()
+	}
+	return CurrentDiscovery().GetJobs(until)
+}
+
+// ValidateJob: check the fields needed to schedule a job
+func ValidateJob(job j.Job) error {
+	if len(job.Token) == 0 {
+		return fmt.Errorf("Missing token")
+	}
+	if job.RunTime.IsZero() && len(job.DependsOn) == 0 {
+		// a job with depends_on runs after its upstream jobs
+		return fmt.Errorf("Missing run_time")
+	}
+	if len(job.UrlPath) == 0 {
+		return fmt.Errorf("Missing url_path")
+	}
+	if !fr.Valid(job.Frequency) {
+		return fmt.Errorf("Invalid frequency: %d", job.Frequency)
+	}
+	if _, errSLA := sla.Parse(job); errSLA != nil {
+		return errSLA
+	}
+	for _, targets := range [][]string{job.OnSuccess, job.OnFailure, job.Always} {
+		for _, target := range targets {
+			if len(strings.TrimSpace(target)) == 0 {
+				return fmt.Errorf("Empty hook target")
+			}
+		}
+	}
+	return nil
+}
+
+// RunManual: run the job now through the runner, the job's schedule is not changed, the error of the run is returned
+func RunManual(job j.Job) error {
+	runner, history, done := acquireAdapters()
+	defer done()
+	job.Result = &j.RunResult{}
+	record := h.NewRecord(job, runner.WhichRunner(), util.GetNow())
+	record.Manual = true
+	errRun := RunnerRun(runner, job)
+	if errRun != nil {
+		logAdapter.Error("RunManual: run failed", "token", job.Token, "job_name", job.JobName, "runner", record.Runner, "run_id", record.RunID, "error", errRun)
+	}
+	record.Finish(util.GetNow(), errRun)
+	record.SetResult(job.Result)
+	if errSave := history.Save(record); errSave != nil {
+		logAdapter.Error("RunManual: unable to save history", "token", job.Token, "run_id", record.RunID, "error", errSave)
+	}
+	NotifyRun(job, errRun)
+	RunHooks(job, errRun, nil)
+	return errRun
+}
+
+// readJob: the job in the body of the request, code is the status to answer with on error
+func readJob(w http.ResponseWriter, req *http.Request) (job j.Job, code int, err error) {
+	code = http.StatusBadRequest
+	if err = json.NewDecoder(http.MaxBytesReader(w, req.Body, maxJobBody)).Decode(&job); err != nil {
+		var errTooLarge *http.MaxBytesError
+		if errors.As(err, &errTooLarge) {
+			code = http.StatusRequestEntityTooLarge
+		}
+		return
+	}
+	err = ValidateJob(job)
+	return
+}
+
+func storeErrorCode(err error) int {
+	switch err {
+	case d.ErrJobNotFound:
+		return http.StatusNotFound
+	case d.ErrJobExists:
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
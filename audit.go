@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/keenfury/axenda/audit"
+	"github.com/keenfury/axenda/config"
+	j "github.com/keenfury/axenda/job"
+	"github.com/keenfury/axenda/util"
+)
+
+type AuditAdapter interface {
+	WhichAudit() string
+	Append(audit.Event) error
+	List(audit.Filter) ([]audit.Event, error)
+}
+
+// auditAdapter: guarded by adapterMu, kept on reload unless SCH_AUDIT_FILE_NAME changed
+var auditAdapter AuditAdapter = &audit.Memory{MaxEvents: 1000}
+
+func CurrentAudit() AuditAdapter {
+	adapterMu.RLock()
+	defer adapterMu.RUnlock()
+	return auditAdapter
+}
+
+// SetAuditAdapter: the file of SCH_AUDIT_FILE_NAME, the failsafe is memory (the events already in memory are kept)
+func SetAuditAdapter() {
+	adapterMu.Lock()
+	defer adapterMu.Unlock()
+	if len(config.AuditFileName) > 0 {
+		auditAdapter = &audit.File{FileName: config.AuditFileName}
+		return
+	}
+	if _, ok := auditAdapter.(*audit.Memory); !ok {
+		auditAdapter = &audit.Memory{MaxEvents: 1000}
+	}
+}
+
+// RecordAudit: append the event of the action on the job, before/after are nil when the job didn't/doesn't exist
+func RecordAudit(actor, source, action string, before, after *j.Job) {
+	event := audit.NewEvent(actor, source, action, util.GetNow(), before, after)
+	if errAppend := CurrentAudit().Append(event); errAppend != nil {
+		logAdapter.Error("RecordAudit: unable to append event", "token", event.Token, "actor", actor, "action", action, "error", errAppend)
+	}
+}
+
+// AuditFilter: the filter of the query values, from/to are RFC3339
+func AuditFilter(token, actor, action, from, to string) (filter audit.Filter, err error) {
+	filter = audit.Filter{Token: token, Actor: actor, Action: action}
+	if len(from) > 0 {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			err = fmt.Errorf("Invalid from: %s", err)
+			return
+		}
+	}
+	if len(to) > 0 {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			err = fmt.Errorf("Invalid to: %s", err)
+		}
+	}
+	return
+}
+
+func AdminAuditHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method not allowed"))
+		return
+	}
+	query := req.URL.Query()
+	filter, errFilter := AuditFilter(query.Get("job"), query.Get("actor"), query.Get("action"), query.Get("from"), query.Get("to"))
+	if errFilter != nil {
+		writeError(w, http.StatusBadRequest, errFilter)
+		return
+	}
+	events, errList := CurrentAudit().List(filter)
+	if errList != nil {
+		writeError(w, http.StatusInternalServerError, errList)
+		return
+	}
+	if events == nil {
+		events = []audit.Event{}
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// adminActor: who is calling the admin api, the X-Actor header as the bearer token is shared, it is what the client
+// says: any holder of the token can put any name in it, the audit log records who claimed the change, not who made it
+func adminActor(req *http.Request) string {
+	if actor := req.Header.Get("X-Actor"); len(actor) > 0 {
+		return actor
+	}
+	return "admin"
+}
+
+// cliActor: who runs the command, SCH_AUDIT_ACTOR or the user of the shell
+func cliActor() string {
+	for _, actor := range []string{config.AuditActor, os.Getenv("USER")} {
+		if len(actor) > 0 {
+			return actor
+		}
+	}
+	return "cli"
+}
@@ -0,0 +1,136 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+
+	j "github.com/keenfury/axenda/job"
+)
+
+/*
+An Event is kept for every change made to a job definition and every manual action, through one of the audit logs:
+- File: JSON lines, only ever appended to
+- Memory: the failsafe, lost on restart
+
+	{"time":"2020-04-23T12:22:00-06:00","actor":"alice","source":"api","action":"update","token":"NIGHTLY",
+	 "changes":[{"field":"run_time","before":"2020-04-24T02:00:00-06:00","after":"2020-04-24T03:00:00-06:00"}]}
+
+The changes are the json fields of job.Job that differ before/after: all of them on create (before is empty) and
+delete (after is empty), none on trigger.  Moving run_time along after a run is the scheduler's job, it is in the run
+history, not here.
+*/
+
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionPause   = "pause"
+	ActionResume  = "resume"
+	ActionTrigger = "trigger"
+
+	SourceAPI = "api"
+	SourceCLI = "cli"
+)
+
+type (
+	Event struct {
+		Time    time.Time `json:"time"`
+		Actor   string    `json:"actor"`
+		Source  string    `json:"source"`
+		Action  string    `json:"action"`
+		Token   string    `json:"token"`
+		Changes []Change  `json:"changes,omitempty"`
+	}
+
+	Change struct {
+		Field  string      `json:"field"`
+		Before interface{} `json:"before,omitempty"`
+		After  interface{} `json:"after,omitempty"`
+	}
+
+	// Filter: empty fields match all, from/to zero are open ended
+	Filter struct {
+		Token  string
+		Actor  string
+		Action string
+		From   time.Time
+		To     time.Time
+	}
+)
+
+// NewEvent: the event of the action on the job, before/after are nil when the job didn't/doesn't exist
+func NewEvent(actor, source, action string, at time.Time, before, after *j.Job) Event {
+	event := Event{Time: at, Actor: actor, Source: source, Action: action}
+	switch {
+	case after != nil:
+		event.Token = after.Token
+	case before != nil:
+		event.Token = before.Token
+	}
+	if action != ActionTrigger {
+		event.Changes = Diff(before, after)
+	}
+	return event
+}
+
+// Diff: the json fields of the jobs that differ, sorted by field, a nil job has no fields
+func Diff(before, after *j.Job) (changes []Change) {
+	fieldsBefore, fieldsAfter := fields(before), fields(after)
+	names := []string{}
+	for name := range fieldsBefore {
+		names = append(names, name)
+	}
+	for name := range fieldsAfter {
+		if _, ok := fieldsBefore[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !reflect.DeepEqual(fieldsBefore[name], fieldsAfter[name]) {
+			changes = append(changes, Change{Field: name, Before: fieldsBefore[name], After: fieldsAfter[name]})
+		}
+	}
+	return
+}
+
+// fields: the job as its json fields, through json so the names and values are the ones of the job files
+func fields(job *j.Job) map[string]interface{} {
+	value := map[string]interface{}{}
+	if job == nil {
+		return value
+	}
+	bJob, errM := json.Marshal(job)
+	if errM != nil {
+		return value
+	}
+	json.Unmarshal(bJob, &value)
+	for name, v := range value {
+		if v == nil {
+			delete(value, name)
+		}
+	}
+	return value
+}
+
+// Match: true if the event passes the filter
+func (e Event) Match(filter Filter) bool {
+	if len(filter.Token) > 0 && e.Token != filter.Token {
+		return false
+	}
+	if len(filter.Actor) > 0 && e.Actor != filter.Actor {
+		return false
+	}
+	if len(filter.Action) > 0 && e.Action != filter.Action {
+		return false
+	}
+	if !filter.From.IsZero() && e.Time.Before(filter.From) {
+		return false
+	}
+	if !filter.To.IsZero() && e.Time.After(filter.To) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	j "github.com/keenfury/axenda/job"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffUpdate(t *testing.T) {
+	runTime, _ := time.Parse(time.RFC3339, "2020-04-24T02:00:00-06:00")
+	before := j.Job{Token: "NIGHTLY", RunTime: runTime, Frequency: 4, Active: true, Payload: json.RawMessage(`{"report":"daily"}`)}
+	after := before
+	after.RunTime = runTime.Add(time.Hour)
+	after.Payload = json.RawMessage(`{"report":"weekly"}`)
+	changes := Diff(&before, &after)
+	assert.Equal(t, 2, len(changes), "Expected only the changed fields")
+	assert.Equal(t, "payload", changes[0].Field)
+	assert.Equal(t, map[string]interface{}{"report": "daily"}, changes[0].Before)
+	assert.Equal(t, "run_time", changes[1].Field)
+	assert.Equal(t, "2020-04-24T03:00:00-06:00", changes[1].After)
+}
+
+func TestNewEventCreateDeleteTrigger(t *testing.T) {
+	job := j.Job{Token: "NIGHTLY", Active: true}
+	now := time.Now()
+	created := NewEvent("alice", SourceAPI, ActionCreate, now, nil, &job)
+	assert.Equal(t, "NIGHTLY", created.Token)
+	for _, change := range created.Changes {
+		assert.Nil(t, change.Before, "Expected nothing before a create")
+	}
+	deleted := NewEvent("alice", SourceAPI, ActionDelete, now, &job, nil)
+	assert.Equal(t, len(created.Changes), len(deleted.Changes))
+	triggered := NewEvent("bob", SourceCLI, ActionTrigger, now, &job, &job)
+	assert.Equal(t, 0, len(triggered.Changes))
+}
+
+func TestFileAppendList(t *testing.T) {
+	fileName := "/tmp/audit_test_append"
+	os.Remove(fileName)
+	defer os.Remove(fileName)
+	file := &File{FileName: fileName}
+	now := time.Now()
+	job := j.Job{Token: "NIGHTLY"}
+	other := j.Job{Token: "OTHER"}
+	assert.Nil(t, file.Append(NewEvent("alice", SourceAPI, ActionCreate, now.Add(-time.Hour), nil, &job)))
+	assert.Nil(t, file.Append(NewEvent("bob", SourceCLI, ActionPause, now, &other, &other)))
+	assert.Nil(t, file.Append(NewEvent("bob", SourceCLI, ActionTrigger, now, &job, &job)))
+	events, err := file.List(Filter{Token: "NIGHTLY"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, ActionCreate, events[0].Action, "Expected oldest first")
+	events, err = file.List(Filter{Actor: "bob", From: now.Add(-time.Minute)})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(events))
+	events, err = file.List(Filter{Action: ActionPause})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "OTHER", events[0].Token)
+}
@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+type (
+	File struct {
+		FileName string
+
+		mu sync.Mutex
+	}
+)
+
+func (f *File) WhichAudit() string {
+	return "File"
+}
+
+// Append: add the event at the end of the file, the file is never rewritten
+func (f *File) Append(event Event) error {
+	bEvent, errM := json.Marshal(event)
+	if errM != nil {
+		return errM
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, errOpen := os.OpenFile(f.FileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if errOpen != nil {
+		return errOpen
+	}
+	if _, errWrite := file.Write(append(bEvent, '\n')); errWrite != nil {
+		file.Close()
+		return errWrite
+	}
+	return file.Close()
+}
+
+func (f *File) List(filter Filter) (events []Event, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	bContent, errRead := ioutil.ReadFile(f.FileName)
+	if errRead != nil {
+		if os.IsNotExist(errRead) {
+			return
+		}
+		err = errRead
+		return
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(bContent))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		e := Event{}
+		if err = json.Unmarshal(line, &e); err != nil {
+			return
+		}
+		if e.Match(filter) {
+			events = append(events, e)
+		}
+	}
+	err = scanner.Err()
+	return
+}
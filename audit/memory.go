@@ -0,0 +1,40 @@
+package audit
+
+import (
+	"sync"
+)
+
+type (
+	Memory struct {
+		// MaxEvents: the newest events kept, zero keeps all
+		MaxEvents int
+
+		mu     sync.Mutex
+		events []Event
+	}
+)
+
+func (m *Memory) WhichAudit() string {
+	return "Memory"
+}
+
+func (m *Memory) Append(event Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, event)
+	if m.MaxEvents > 0 && len(m.events) > m.MaxEvents {
+		m.events = m.events[len(m.events)-m.MaxEvents:]
+	}
+	return nil
+}
+
+func (m *Memory) List(filter Filter) (events []Event, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.events {
+		if e.Match(filter) {
+			events = append(events, e)
+		}
+	}
+	return
+}
@@ -0,0 +1,550 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/keenfury/axenda/audit"
+	"github.com/keenfury/axenda/config"
+	fr "github.com/keenfury/axenda/frequency"
+	h "github.com/keenfury/axenda/history"
+	j "github.com/keenfury/axenda/job"
+	sim "github.com/keenfury/axenda/simulation"
+	"github.com/keenfury/axenda/util"
+	wf "github.com/keenfury/axenda/workflow"
+)
+
+/*
+Command line, all commands use the adapters set by the configuration file and/or environment variables (see config)
+
+	axenda [--config axenda.yaml] <command>
+
+	axenda [run]                                   the scheduler's loop
+	axenda jobs list                               list all jobs
+	axenda jobs add --token T --run-time R ...     add a job, or --file job.json
+	axenda jobs rm|pause|resume|trigger TOKEN      delete, set inactive, set active or run a job now
+	axenda next --job TOKEN [--count 10]           next run times of a job
+	axenda simulate [--job TOKEN] [--window 24h]   replay the schedule without running anything
+	axenda audit [--job TOKEN] [--actor NAME]      the audit log of the job changes and manual actions
+	axenda history export|report [--month 2020-04] export the run history (csv, jsonl) or summarize it
+	axenda validate                                check the configuration and jobs
+	axenda version
+*/
+
+// the longest window replayed, the events are capped by sim.MaxEvents
+const maxSimulateWindow = 366 * 24 * time.Hour
+
+// Version: set at build time with -ldflags "-X main.Version=v1.2.3"
+var Version = "dev"
+
+const usage = `Usage: axenda [--config file] <command>
+
+The configuration file (.yaml, .yml, .toml or .json) can also be set with SCH_CONFIG_FILE, environment variables
+override its values.
+
+Commands:
+  run                                   run the scheduler (default)
+  jobs list                             list all jobs
+  jobs add [flags]                      add a job (axenda jobs add -h for flags)
+  jobs rm|pause|resume|trigger TOKEN    delete, set inactive, set active or run a job now
+  next --job TOKEN [--count 10]         show the next run times of a job
+  simulate [flags]                      replay the schedule without running anything (axenda simulate -h for flags)
+  audit [flags]                         show the audit log of job changes and manual actions (axenda audit -h for flags)
+  history export|report [flags]         export the run history or report on it (axenda history export -h for flags)
+  validate                              check the configuration and jobs
+  version                               show the version`
+
+// RunCommand: dispatch the command line arguments, no arguments runs the scheduler
+func RunCommand(args []string) error {
+	flags := flag.NewFlagSet("axenda", flag.ContinueOnError)
+	configFile := flags.String("config", config.ConfigFile, "yaml, toml or json configuration file (SCH_CONFIG_FILE)")
+	if errParse := flags.Parse(args); errParse != nil {
+		return errParse
+	}
+	config.ConfigFile = *configFile
+	args = flags.Args()
+	if len(args) == 0 {
+		args = []string{"run"}
+	}
+	switch args[0] {
+	case "run":
+		if errSetup := Setup(); errSetup != nil {
+			return errSetup
+		}
+		Run()
+	case "jobs":
+		return jobsCommand(args[1:])
+	case "next":
+		return nextCommand(args[1:])
+	case "simulate":
+		return simulateCommand(args[1:])
+	case "audit":
+		return auditCommand(args[1:])
+	case "history":
+		return historyCommand(args[1:])
+	case "validate":
+		return validateCommand()
+	case "version":
+		fmt.Println(Version)
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+	default:
+		return fmt.Errorf("Unknown command: %s\n%s", args[0], usage)
+	}
+	return nil
+}
+
+func jobsCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("Missing jobs command\n%s", usage)
+	}
+	if errSetup := Setup(); errSetup != nil {
+		return errSetup
+	}
+	store, ok := discoveryAdapter.(JobStore)
+	if !ok {
+		return fmt.Errorf("Discovery does not manage jobs: %s", discoveryAdapter.WhichDiscovery())
+	}
+	if args[0] != "list" && len(config.AuditFileName) == 0 {
+		// the memory audit log is gone once the command exits
+		fmt.Fprintln(os.Stderr, "Warning: SCH_AUDIT_FILE_NAME is not set, this change is not recorded in the audit log")
+	}
+	switch args[0] {
+	case "list":
+		jobs, errList := store.ListJobs()
+		if errList != nil {
+			return errList
+		}
+		printJobs(jobs)
+		return nil
+	case "add":
+		job, errJob := parseJobFlags(args[1:])
+		if errJob != nil {
+			return errJob
+		}
+		if errWorkflow := CheckWorkflow(store, withJob(job)); errWorkflow != nil {
+			return errWorkflow
+		}
+		if errAdd := store.AddJob(job); errAdd != nil {
+			return errAdd
+		}
+		RecordAudit(cliActor(), audit.SourceCLI, audit.ActionCreate, nil, &job)
+		fmt.Println("Added job:", job.Token)
+		return nil
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("Usage: axenda jobs %s TOKEN", args[0])
+	}
+	token := args[1]
+	switch args[0] {
+	case "rm":
+		if errWorkflow := CheckWorkflow(store, withoutJob(token)); errWorkflow != nil {
+			return errWorkflow
+		}
+		before, errFind := FindJob(store, token)
+		if errFind != nil {
+			return errFind
+		}
+		if errDelete := store.DeleteJob(token); errDelete != nil {
+			return errDelete
+		}
+		RecordAudit(cliActor(), audit.SourceCLI, audit.ActionDelete, &before, nil)
+		fmt.Println("Deleted job:", token)
+	case "pause", "resume":
+		job, errFind := FindJob(store, token)
+		if errFind != nil {
+			return errFind
+		}
+		before := job
+		job.Active = args[0] == "resume"
+		if errUpdate := store.UpdateJob(job); errUpdate != nil {
+			return errUpdate
+		}
+		RecordAudit(cliActor(), audit.SourceCLI, args[0], &before, &job)
+		fmt.Printf("Job %s active: %t\n", token, job.Active)
+	case "trigger":
+		job, errFind := FindJob(store, token)
+		if errFind != nil {
+			return errFind
+		}
+		RecordAudit(cliActor(), audit.SourceCLI, audit.ActionTrigger, &job, &job)
+		// like the admin api: history, notifications and hooks, the hooks are waited for before exiting
+		errRun := RunManual(job)
+		waitInFlight()
+		if errRun != nil {
+			return errRun
+		}
+		fmt.Println("Triggered job:", token)
+	default:
+		return fmt.Errorf("Unknown jobs command: %s\n%s", args[0], usage)
+	}
+	return nil
+}
+
+func auditCommand(args []string) error {
+	flags := flag.NewFlagSet("audit", flag.ContinueOnError)
+	token := flags.String("job", "", "token of the job (default all)")
+	actor := flags.String("actor", "", "who made the changes (default all)")
+	action := flags.String("action", "", "create, update, delete, pause, resume or trigger (default all)")
+	from := flags.String("from", "", "from this time, RFC3339 format")
+	to := flags.String("to", "", "up to this time, RFC3339 format")
+	asJSON := flags.Bool("json", false, "print the events as json")
+	if errParse := flags.Parse(args); errParse != nil {
+		return errParse
+	}
+	filter, errFilter := AuditFilter(*token, *actor, *action, *from, *to)
+	if errFilter != nil {
+		return errFilter
+	}
+	if errLoad := config.Load(config.ConfigFile); errLoad != nil {
+		return errLoad
+	}
+	if len(config.AuditFileName) == 0 {
+		return fmt.Errorf("SCH_AUDIT_FILE_NAME: required to read the audit log, the memory one is the scheduler's")
+	}
+	SetAuditAdapter()
+	events, errList := CurrentAudit().List(filter)
+	if errList != nil {
+		return errList
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, event := range events {
+			if errEncode := enc.Encode(event); errEncode != nil {
+				return errEncode
+			}
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tACTOR\tSOURCE\tACTION\tTOKEN\tCHANGES")
+	for _, event := range events {
+		changed := []string{}
+		for _, change := range event.Changes {
+			changed = append(changed, change.Field)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", event.Time.Format(time.RFC3339), event.Actor, event.Source, event.Action, event.Token, strings.Join(changed, ","))
+	}
+	return w.Flush()
+}
+
+func historyCommand(args []string) error {
+	if len(args) == 0 || (args[0] != "export" && args[0] != "report") {
+		return fmt.Errorf("Usage: axenda history export|report [flags]\n%s", usage)
+	}
+	flags := flag.NewFlagSet("history "+args[0], flag.ContinueOnError)
+	token := flags.String("job", "", "token of the job (default all)")
+	from := flags.String("from", "", "from this time, RFC3339 format")
+	to := flags.String("to", "", "up to this time, RFC3339 format")
+	month := flags.String("month", "", "a whole month instead of from/to, e.g. 2020-04")
+	format := flags.String("format", h.FormatCSV, "export format: csv or jsonl")
+	out := flags.String("out", "", "export to this file (default stdout)")
+	top := flags.Int("top", defaultReportTop, "report: the longest runs listed")
+	asJSON := flags.Bool("json", false, "report: print the report as json")
+	if errParse := flags.Parse(args[1:]); errParse != nil {
+		return errParse
+	}
+	start, end, errRange := HistoryRange(*from, *to, *month)
+	if errRange != nil {
+		return errRange
+	}
+	if errLoad := config.Load(config.ConfigFile); errLoad != nil {
+		return errLoad
+	}
+	if len(config.HistoryFileName) == 0 && config.HistoryUseDB != "true" {
+		return fmt.Errorf("SCH_HISTORY_FILE_NAME or SCH_HISTORY_USE_DB: required to read the run history, the memory one is the scheduler's")
+	}
+	history := SetHistoryAdapter()
+	if closer, ok := history.(io.Closer); ok {
+		defer closer.Close()
+	}
+	records, errRecords := HistoryRecords(history, *token, start, end)
+	if errRecords != nil {
+		return errRecords
+	}
+	if args[0] == "export" {
+		var w io.Writer = os.Stdout
+		if len(*out) > 0 {
+			file, errCreate := os.Create(*out)
+			if errCreate != nil {
+				return errCreate
+			}
+			defer file.Close()
+			w = file
+		}
+		return h.Export(w, records, *format)
+	}
+	report := h.Summarize(records, start, end, *top)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	printReport(report)
+	return nil
+}
+
+func printReport(report h.Report) {
+	fmt.Printf("Runs: %d, failures: %d, success rate: %.1f%%\n\n", report.Runs, report.Failures, report.SuccessRate)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TOKEN\tNAME\tRUNS\tFAILURES\tSUCCESS\tAVG LAG\tAVG DURATION\tMAX DURATION")
+	for _, job := range report.Jobs {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%.1f%%\t%s\t%s\t%s\n", job.Token, job.JobName, job.Runs, job.Failures, job.SuccessRate,
+			seconds(job.AvgLag), seconds(job.AvgDuration), seconds(job.MaxDuration))
+	}
+	w.Flush()
+	if len(report.Longest) == 0 {
+		return
+	}
+	fmt.Println("\nLongest runs:")
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TOKEN\tSTART\tDURATION\tRESULT\tRUN ID")
+	for _, record := range report.Longest {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", record.Token, record.Start.Format(time.RFC3339), record.Duration(), record.Result, record.RunID)
+	}
+	w.Flush()
+}
+
+// seconds: the seconds as a duration to print, e.g. 1m30s
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Millisecond)
+}
+
+func nextCommand(args []string) error {
+	flags := flag.NewFlagSet("next", flag.ContinueOnError)
+	token := flags.String("job", "", "token of the job")
+	count := flags.Int("count", 10, "number of run times to show")
+	if errParse := flags.Parse(args); errParse != nil {
+		return errParse
+	}
+	if len(*token) == 0 {
+		return fmt.Errorf("Missing --job")
+	}
+	if errSetup := Setup(); errSetup != nil {
+		return errSetup
+	}
+	store, ok := discoveryAdapter.(JobStore)
+	if !ok {
+		return fmt.Errorf("Discovery does not manage jobs: %s", discoveryAdapter.WhichDiscovery())
+	}
+	job, errFind := FindJob(store, *token)
+	if errFind != nil {
+		return errFind
+	}
+	runTimes, errNext := NextRunTimes(job, util.GetNow(), *count)
+	for _, t := range runTimes {
+		fmt.Println(t.Format(time.RFC3339))
+	}
+	return errNext
+}
+
+func simulateCommand(args []string) error {
+	flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	token := flags.String("job", "", "token of the job, default all jobs")
+	from := flags.String("from", "", "start of the window, RFC3339 format, default now")
+	window := flags.String("window", "24h", "length of the window")
+	firesOnly := flags.Bool("fires", false, "only show when the jobs fire")
+	if errParse := flags.Parse(args); errParse != nil {
+		return errParse
+	}
+	if errSetup := Setup(); errSetup != nil {
+		return errSetup
+	}
+	events, errSim := Simulate(*token, *from, *window)
+	if errSim != nil {
+		return errSim
+	}
+	if *firesOnly {
+		events = sim.FireTimes(events)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "AT\tEVENT\tTOKEN\tNAME\tDETAIL")
+	for _, e := range events {
+		detail := e.Detail
+		if e.Kind == sim.EventStatus {
+			detail = fmt.Sprintf("%q => %q", e.From, e.To)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.At.Format(time.RFC3339), e.Kind, e.Token, e.JobName, detail)
+	}
+	return w.Flush()
+}
+
+// Simulate: replay the schedule of one job (or all when token is empty), from is RFC3339 (default now), window a duration
+func Simulate(token, from, window string) (events []sim.Event, err error) {
+	start := util.GetNow()
+	if len(from) > 0 {
+		if start, err = time.Parse(time.RFC3339, from); err != nil {
+			return
+		}
+	}
+	length := 24 * time.Hour
+	if len(window) > 0 {
+		if length, err = time.ParseDuration(window); err != nil {
+			return
+		}
+	}
+	if length > maxSimulateWindow {
+		err = fmt.Errorf("Window too long, max: %s", maxSimulateWindow)
+		return
+	}
+	jobs, errJobs := ListAllJobs(start.Add(length))
+	if errJobs != nil {
+		err = errJobs
+		return
+	}
+	if len(token) > 0 {
+		selected := []j.Job{}
+		for _, job := range jobs {
+			if job.Token == token {
+				selected = append(selected, job)
+			}
+		}
+		if len(selected) == 0 {
+			err = fmt.Errorf("Job not found: %s", token)
+			return
+		}
+		jobs = selected
+	}
+	return sim.Run(jobs, start, start.Add(length))
+}
+
+func validateCommand() error {
+	if errSetup := Setup(); errSetup != nil {
+		return errSetup
+	}
+	if len(config.ConfigFile) > 0 {
+		fmt.Println("Configuration:", config.ConfigFile)
+	}
+	fmt.Println("Discovery:", discoveryAdapter.WhichDiscovery())
+	fmt.Println("History:", historyAdapter.WhichHistory())
+	jobs, errJobs := ListAllJobs(util.GetNow())
+	if errJobs != nil {
+		return fmt.Errorf("Unable to get jobs: %s", errJobs)
+	}
+	problems := []string{}
+	tokens := map[string]bool{}
+	for _, job := range jobs {
+		if errValid := ValidateJob(job); errValid != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", job.Token, errValid))
+		}
+		if tokens[job.Token] {
+			problems = append(problems, fmt.Sprintf("%s: duplicate token", job.Token))
+		}
+		tokens[job.Token] = true
+	}
+	if _, errGraph := wf.NewGraph(jobs); errGraph != nil {
+		problems = append(problems, errGraph.Error())
+	}
+	for _, job := range jobs {
+		for _, target := range append(append(append([]string{}, job.OnSuccess...), job.OnFailure...), job.Always...) {
+			if !util.IsHookURL(target) && !tokens[target] {
+				problems = append(problems, fmt.Sprintf("%s: hook to unknown job: %s", job.Token, target))
+			}
+		}
+	}
+	fmt.Printf("Jobs: %d\n", len(jobs))
+	if len(problems) > 0 {
+		return fmt.Errorf("Invalid jobs:\n  %s", strings.Join(problems, "\n  "))
+	}
+	fmt.Println("OK")
+	return nil
+}
+
+// NextRunTimes: the next count run times of the job, the way the scheduler will move the job along with its frequency
+func NextRunTimes(job j.Job, now time.Time, count int) (runTimes []time.Time, err error) {
+	truncNow := util.TruncateTimeToMinute(now)
+	for i := 0; i < count && job.Active; i++ {
+		runTime := job.RunTime
+		if runTime.Before(truncNow) {
+			// past due, picked up on the next tick
+			runTime = truncNow.Add(time.Minute)
+		}
+		runTimes = append(runTimes, runTime)
+		if err = fr.UpdateAt(&job, runTime); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func parseJobFlags(args []string) (job j.Job, err error) {
+	flags := flag.NewFlagSet("jobs add", flag.ContinueOnError)
+	fileName := flags.String("file", "", "json file with the job")
+	token := flags.String("token", "", "unique token of the job")
+	name := flags.String("name", "", "name of the job")
+	runTime := flags.String("run-time", "", "first run time, RFC3339 format")
+	urlPath := flags.String("url", "", "url path for the runner")
+	frequency := flags.Int("frequency", fr.Daily, "frequency, see frequency.go")
+	payload := flags.String("payload", "", "json payload")
+	inactive := flags.Bool("inactive", false, "add the job as inactive")
+	dependsOn := flags.String("depends-on", "", "comma separated tokens of the jobs this job runs after")
+	onSuccess := flags.String("on-success", "", "comma separated job tokens or urls triggered when a run succeeds")
+	onFailure := flags.String("on-failure", "", "comma separated job tokens or urls triggered when a run fails")
+	always := flags.String("always", "", "comma separated job tokens or urls triggered after every run")
+	notifyTo := flags.String("notify", "", "comma separated email addresses or webhook urls alerted on failure")
+	startWithin := flags.String("start-within", "", "expect each run to start within this duration of its run time, e.g. 5m")
+	succeedBy := flags.String("succeed-by", "", "expect each run to have succeeded by this time of day, e.g. 06:00")
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+	if len(*fileName) > 0 {
+		bContent, errRead := ioutil.ReadFile(*fileName)
+		if errRead != nil {
+			err = errRead
+			return
+		}
+		if err = json.Unmarshal(bContent, &job); err != nil {
+			return
+		}
+		err = ValidateJob(job)
+		return
+	}
+	job = j.Job{Token: *token, JobName: *name, UrlPath: *urlPath, Frequency: *frequency, Active: !*inactive}
+	job.DependsOn = splitList(*dependsOn)
+	job.OnSuccess = splitList(*onSuccess)
+	job.OnFailure = splitList(*onFailure)
+	job.Always = splitList(*always)
+	job.Notify = splitList(*notifyTo)
+	job.StartWithin = *startWithin
+	job.SucceedBy = *succeedBy
+	if len(*runTime) > 0 {
+		if job.RunTime, err = time.Parse(time.RFC3339, *runTime); err != nil {
+			return
+		}
+	}
+	if len(*payload) > 0 {
+		if !json.Valid([]byte(*payload)) {
+			err = fmt.Errorf("Invalid payload json")
+			return
+		}
+		job.Payload = json.RawMessage(*payload)
+	}
+	err = ValidateJob(job)
+	return
+}
+
+func printJobs(jobs []j.Job) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TOKEN\tNAME\tRUN TIME\tFREQUENCY\tACTIVE\tURL")
+	for _, job := range jobs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%t\t%s\n", job.Token, job.JobName, job.RunTime.Format(time.RFC3339), job.Frequency, job.Active, job.UrlPath)
+	}
+	w.Flush()
+}
+
+// splitList: the non empty items of a comma separated flag
+func splitList(value string) (items []string) {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return
+}
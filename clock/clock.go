@@ -0,0 +1,112 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+/*
+Clock is what the scheduler reads the time from, Real is the wall clock and Fake is moved by hand for tests:
+
+	fake := clock.NewFake(start)
+	util.Clock = fake
+	fake.Add(time.Minute) // tickers fire as the time passes
+*/
+
+type (
+	Clock interface {
+		Now() time.Time
+		NewTicker(time.Duration) Ticker
+	}
+
+	Ticker interface {
+		C() <-chan time.Time
+		Stop()
+	}
+
+	Real struct{}
+
+	realTicker struct {
+		ticker *time.Ticker
+	}
+
+	Fake struct {
+		mu      sync.Mutex
+		now     time.Time
+		tickers []*fakeTicker
+	}
+
+	fakeTicker struct {
+		fake    *Fake
+		c       chan time.Time
+		period  time.Duration
+		next    time.Time
+		stopped bool
+	}
+)
+
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+func (Real) NewTicker(d time.Duration) Ticker {
+	return &realTicker{ticker: time.NewTicker(d)}
+}
+
+func (r *realTicker) C() <-chan time.Time {
+	return r.ticker.C
+}
+
+func (r *realTicker) Stop() {
+	r.ticker.Stop()
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTicker{fake: f, c: make(chan time.Time, 1), period: d, next: f.now.Add(d)}
+	f.tickers = append(f.tickers, t)
+	return t
+}
+
+// Add: move the clock forward, firing the tickers that come due
+func (f *Fake) Add(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// Set: move the clock to the given time, firing the tickers that come due
+// like a real ticker a slow reader only gets the first tick, the others are dropped
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+	for _, t := range f.tickers {
+		for !t.stopped && !t.next.After(now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.fake.mu.Lock()
+	defer t.fake.mu.Unlock()
+	t.stopped = true
+}
@@ -0,0 +1,47 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeNow(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2020-04-23T12:24:00-06:00")
+	fake := NewFake(start)
+	assert.Equal(t, start, fake.Now())
+	fake.Add(time.Hour)
+	assert.Equal(t, start.Add(time.Hour), fake.Now())
+}
+
+func TestFakeTicker(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2020-04-23T12:24:00-06:00")
+	fake := NewFake(start)
+	ticker := fake.NewTicker(time.Minute)
+	fake.Add(30 * time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatal("Ticker should not fire yet")
+	default:
+	}
+	fake.Add(30 * time.Second)
+	assert.Equal(t, start.Add(time.Minute), <-ticker.C())
+	// slow reader only gets the first tick
+	fake.Add(3 * time.Minute)
+	assert.Equal(t, start.Add(2*time.Minute), <-ticker.C())
+	fake.Add(time.Minute)
+	assert.Equal(t, start.Add(5*time.Minute), <-ticker.C())
+}
+
+func TestFakeTickerStop(t *testing.T) {
+	fake := NewFake(time.Now())
+	ticker := fake.NewTicker(time.Minute)
+	ticker.Stop()
+	fake.Add(time.Hour)
+	select {
+	case <-ticker.C():
+		t.Fatal("Stopped ticker should not fire")
+	default:
+	}
+}
@@ -5,16 +5,36 @@ import (
 )
 
 var (
+	// Optional: set to the full path of a yaml (.yaml/.yml), toml (.toml) or json (.json) configuration file, see
+	// file.go, any environment variable set overrides the value from the file (also: axenda --config path)
+	ConfigFile = os.Getenv("SCH_CONFIG_FILE")
 	// Required: set to "true" if you want all your dates to use UTC else it will use the system's location
 	// note: if using db, api or grpc, make sure your dates match in regards to the correct timezone
 	UseUTC = os.Getenv("SCH_USE_UTC")
-	// Optional: set to full path to the file on your local system
+	// Optional: set to an IANA time zone e.g. "America/Denver" instead of the system's location (ignored with SCH_USE_UTC)
+	TimeZone = os.Getenv("SCH_TIME_ZONE")
+	// Optional: how far ahead discovery looks for jobs, e.g. "5m" (defaults to 3m)
+	Lookahead = os.Getenv("SCH_LOOKAHEAD")
+	// Optional: max number of jobs running at once, e.g. "10", due jobs over the limit wait for the next minute
+	// (defaults to no limit)
+	Concurrency = os.Getenv("SCH_CONCURRENCY")
+	// Required: set to "file", "db", "api", "grpc" or "mock", if not set it is inferred from the settings below in
+	// this order: file, db, api and grpc, the mock is never used unless it is set
+	// set a comma separated list e.g. "file,db" to merge the jobs of several discoveries
+	Discovery = os.Getenv("SCH_DISCOVERY")
+	// Optional: set to full path to the file on your local system, .json, .yaml/.yml or .toml, or to a directory of
+	// those files
 	JobFileName = os.Getenv("SCH_JOB_FILE_NAME")
+	// Optional: full path of the file keeping the next run times of a job directory, yaml or toml file
+	// (defaults to SCH_JOB_FILE_NAME + ".state.json")
+	JobStateFileName = os.Getenv("SCH_JOB_STATE_FILE")
 	// Optional: set these to read from a db
 	DBHost = os.Getenv("SCH_DB_HOST")
 	DBUser = os.Getenv("SCH_DB_USER")
 	DBPwd  = os.Getenv("SCH_DB_PWD")
 	DBDB   = os.Getenv("SCH_DB_DB")
+	// Optional: sslmode of the db connection, e.g. "verify-full" (defaults to "disable")
+	DBSSLMode = os.Getenv("SCH_DB_SSL_MODE")
 	// Optional: set these to read from an api endpoint(s)
 	APIGetUrl = os.Getenv("SCH_API_GET_URL")
 	APICmpUrl = os.Getenv("SCH_API_CMP_URL")
@@ -22,7 +42,86 @@ var (
 	GRPCUrl = os.Getenv("SCH_GRPC_URL")
 	// Optional: set to full path to push simple messages to a log file
 	LogFileName = os.Getenv("SCH_LOG_FILE_NAME")
+	// Optional: the lowest level logged: "debug", "info", "warn" or "error" (defaults to "info"), and the format of
+	// the log: "text" or "json" (defaults to "text")
+	LogLevel  = os.Getenv("SCH_LOG_LEVEL")
+	LogFormat = os.Getenv("SCH_LOG_FORMAT")
+	// Optional: rotate the log file once it would pass a size, e.g. "100MB" (B, KB, MB or GB), and/or every duration,
+	// e.g. "24h", keep the newest SCH_LOG_MAX_BACKUPS rotated files (defaults to all), set SCH_LOG_COMPRESS "true"
+	// to gzip them
+	LogMaxSize     = os.Getenv("SCH_LOG_MAX_SIZE")
+	LogRotateEvery = os.Getenv("SCH_LOG_ROTATE_EVERY")
+	LogMaxBackups  = os.Getenv("SCH_LOG_MAX_BACKUPS")
+	LogCompress    = os.Getenv("SCH_LOG_COMPRESS")
+	// Optional: where the log goes: "stdout", "file", "syslog" or "http", if not set it is inferred from the settings
+	// in this order: SCH_LOG_FILE_NAME, SCH_LOG_SYSLOG_ADDR, SCH_LOG_HTTP_URL (defaults to "stdout")
+	LogOutput = os.Getenv("SCH_LOG_OUTPUT")
+	// Optional: the syslog server, "udp://host:514", "tcp://host:601" or "unix:///dev/log", and the app name of the
+	// messages (defaults to "axenda")
+	LogSyslogAddr = os.Getenv("SCH_LOG_SYSLOG_ADDR")
+	LogSyslogApp  = os.Getenv("SCH_LOG_SYSLOG_APP")
+	// Optional: the endpoint the log is shipped to as json lines, with an optional bearer token, the entries per post
+	// (defaults to 100), the entries kept in memory while the endpoint is down (defaults to 10000) and how often the
+	// entries are sent, e.g. "5s" (the default)
+	LogHTTPUrl    = os.Getenv("SCH_LOG_HTTP_URL")
+	LogHTTPToken  = os.Getenv("SCH_LOG_HTTP_TOKEN")
+	LogHTTPBatch  = os.Getenv("SCH_LOG_HTTP_BATCH")
+	LogHTTPBuffer = os.Getenv("SCH_LOG_HTTP_BUFFER")
+	LogHTTPFlush  = os.Getenv("SCH_LOG_HTTP_FLUSH")
+	// Optional: record spans of the ticks, discovery and runs: "otlp" or "stdout" (to test locally), the otlp
+	// collector's grpc endpoint (defaults to "localhost:4317"), set SCH_TRACE_OTLP_INSECURE "true" for no TLS
+	TraceExporter     = os.Getenv("SCH_TRACE_EXPORTER")
+	TraceOTLPEndpoint = os.Getenv("SCH_TRACE_OTLP_ENDPOINT")
+	TraceOTLPInsecure = os.Getenv("SCH_TRACE_OTLP_INSECURE")
+	// Optional: set to full path to keep the run history as JSON lines, or set SCH_HISTORY_USE_DB to "true" to use
+	// the run_history table (uses the SCH_DB_* settings), the failsafe is in memory
+	HistoryFileName = os.Getenv("SCH_HISTORY_FILE_NAME")
+	HistoryUseDB    = os.Getenv("SCH_HISTORY_USE_DB")
+	// Optional: set to full path to keep the audit log of job changes and manual actions as JSON lines (append only),
+	// the failsafe is in memory, and the actor of the jobs commands (defaults to $USER)
+	AuditFileName = os.Getenv("SCH_AUDIT_FILE_NAME")
+	AuditActor    = os.Getenv("SCH_AUDIT_ACTOR")
+	// Optional: retention of the run history, max number of records e.g. "10000" and/or max age e.g. "720h"
+	HistoryMaxRecords = os.Getenv("SCH_HISTORY_MAX_RECORDS")
+	HistoryMaxAge     = os.Getenv("SCH_HISTORY_MAX_AGE")
+	// Optional: set to the address to serve prometheus metrics on /metrics, e.g. ":9090"
+	MetricsAddr = os.Getenv("SCH_METRICS_ADDR")
+	// Optional: set to the address to serve /healthz and /readyz on, e.g. ":8081" (can be the same as the metrics)
+	HealthAddr = os.Getenv("SCH_HEALTH_ADDR")
+	// Optional: set to the address to serve the admin api on, e.g. "127.0.0.1:8082", protect it with a bearer token
+	AdminAddr  = os.Getenv("SCH_ADMIN_ADDR")
+	AdminToken = os.Getenv("SCH_ADMIN_TOKEN")
+	// Optional: set to the address to serve the read only dashboard on, e.g. ":8083"
+	DashboardAddr = os.Getenv("SCH_DASHBOARD_ADDR")
 	// Optional: set either of these "true"
 	UseRunnerAPI  = os.Getenv("SCH_USE_API")
 	UseRunnerGRPC = os.Getenv("SCH_USE_GRPC")
+	// Optional: tls used by the api and grpc runner/discovery, the ca file to verify the server, the cert and key
+	// files for client certificates, grpc uses tls once any of these are set
+	TLSCAFile             = os.Getenv("SCH_TLS_CA_FILE")
+	TLSCertFile           = os.Getenv("SCH_TLS_CERT_FILE")
+	TLSKeyFile            = os.Getenv("SCH_TLS_KEY_FILE")
+	TLSInsecureSkipVerify = os.Getenv("SCH_TLS_INSECURE_SKIP_VERIFY")
+	// Optional: failure notifications (see the notify package), when to alert: a comma separated list of "first"
+	// (first failure), "consecutive" (every SCH_NOTIFY_CONSECUTIVE failures in a row, defaults to 3) and "recovery"
+	// (defaults to "first,recovery")
+	NotifyOn          = os.Getenv("SCH_NOTIFY_ON")
+	NotifyConsecutive = os.Getenv("SCH_NOTIFY_CONSECUTIVE")
+	// Optional: min time between two failure alerts of a job, e.g. "30m" (defaults to 15m), and how long the same
+	// error is not alerted again (defaults to 1h)
+	NotifyThrottle = os.Getenv("SCH_NOTIFY_THROTTLE")
+	NotifyDedup    = os.Getenv("SCH_NOTIFY_DEDUP")
+	// Optional: set any of these to send the alerts, a webhook (json), a Slack compatible incoming webhook and/or
+	// emails through an smtp server (host:port) to the comma separated SCH_SMTP_TO (plus the job's recipients)
+	NotifyWebhookUrl = os.Getenv("SCH_NOTIFY_WEBHOOK_URL")
+	NotifySlackUrl   = os.Getenv("SCH_NOTIFY_SLACK_URL")
+	SMTPAddr         = os.Getenv("SCH_SMTP_ADDR")
+	SMTPUser         = os.Getenv("SCH_SMTP_USER")
+	SMTPPwd          = os.Getenv("SCH_SMTP_PWD")
+	SMTPFrom         = os.Getenv("SCH_SMTP_FROM")
+	SMTPTo           = os.Getenv("SCH_SMTP_TO")
+	// Optional: set the number of consecutive failures before a runner target's circuit opens, e.g. "5"
+	BreakerThreshold = os.Getenv("SCH_BREAKER_THRESHOLD")
+	// Optional: how long a circuit stays open before a probe is let through, e.g. "2m" (defaults to 1m)
+	BreakerCoolDown = os.Getenv("SCH_BREAKER_COOLDOWN")
 )
@@ -0,0 +1,879 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+/*
+Configuration file, every setting can come from the file and/or its environment variable, the environment variable wins.
+The format is chosen by the extension: .yaml/.yml, .toml or .json, unknown keys are an error.
+
+Load reads the environment and the file and validates the result, call it before setting any adapter.
+
+Sample yaml (toml and json use the same keys):
+
+	use_utc: false
+	time_zone: America/Denver
+	lookahead: 3m
+	concurrency: 10
+	discovery:
+	  type: file # or a list e.g. "file,db" to merge the jobs of both
+	  file:
+	    name: /path/to/jobs.json # or jobs.yaml, jobs.toml, or a directory of those
+	    state_file: /var/lib/axenda/jobs.state.json
+	  db:
+	    host: localhost
+	    user: axenda
+	    password: secret
+	    database: axenda
+	    ssl_mode: disable
+	  api:
+	    get_url: http://localhost:12572/jobs
+	    cmp_url: http://localhost:12572/complete
+	  grpc:
+	    url: localhost:12500
+	runner:
+	  type: api
+	  breaker:
+	    threshold: 5
+	    cool_down: 2m
+	tls:
+	  ca_file: /path/to/ca.pem
+	  cert_file: /path/to/cert.pem
+	  key_file: /path/to/key.pem
+	  insecure_skip_verify: false
+	log:
+	  file_name: /var/log/axenda.log
+	  level: info
+	  format: json
+	  max_size: 100MB
+	  rotate_every: 24h
+	  max_backups: 7
+	  compress: true
+	  output: file
+	  syslog:
+	    addr: udp://localhost:514
+	    app: axenda
+	  http:
+	    url: https://logs.example.com/ingest
+	    token: secret
+	    batch: 100
+	    buffer: 10000
+	    flush: 5s
+	trace:
+	  exporter: otlp
+	  otlp_endpoint: collector.example.com:4317
+	  otlp_insecure: false
+	history:
+	  file_name: /var/lib/axenda/history.jsonl
+	  use_db: false
+	  max_records: 10000
+	  max_age: 720h
+	audit:
+	  file_name: /var/lib/axenda/audit.jsonl
+	  actor: ops
+	notify:
+	  on: first,consecutive,recovery
+	  consecutive: 3
+	  throttle: 15m
+	  dedup: 1h
+	  webhook_url: https://alerts.example.com/axenda
+	  slack_url: https://hooks.slack.com/services/T000/B000/XXXX
+	  smtp:
+	    addr: smtp.example.com:587
+	    user: axenda
+	    password: secret
+	    from: axenda@example.com
+	    to: ops@example.com,oncall@example.com
+	http:
+	  metrics_addr: ":9090"
+	  health_addr: ":9090"
+	  admin_addr: 127.0.0.1:8082
+	  admin_token: secret
+	  dashboard_addr: ":8083"
+*/
+
+const (
+	DiscoveryFile = "file"
+	DiscoveryDB   = "db"
+	DiscoveryAPI  = "api"
+	DiscoveryGRPC = "grpc"
+	DiscoveryMock = "mock"
+
+	LogOutputStdOut = "stdout"
+	LogOutputFile   = "file"
+	LogOutputSyslog = "syslog"
+	LogOutputHTTP   = "http"
+
+	TraceExporterOTLP   = "otlp"
+	TraceExporterStdOut = "stdout"
+
+	defaultTraceOTLPEndpoint = "localhost:4317"
+
+	defaultLookahead = 3 * time.Minute
+
+	NotifyOnFirst       = "first"
+	NotifyOnConsecutive = "consecutive"
+	NotifyOnRecovery    = "recovery"
+
+	defaultNotifyOn          = "first,recovery"
+	defaultNotifyConsecutive = 3
+	defaultNotifyThrottle    = 15 * time.Minute
+	defaultNotifyDedup       = time.Hour
+)
+
+type (
+	File struct {
+		UseUTC      bool          `yaml:"use_utc" toml:"use_utc" json:"use_utc"`
+		TimeZone    string        `yaml:"time_zone" toml:"time_zone" json:"time_zone"`
+		Lookahead   string        `yaml:"lookahead" toml:"lookahead" json:"lookahead"`
+		Concurrency int           `yaml:"concurrency" toml:"concurrency" json:"concurrency"`
+		Discovery   FileDiscovery `yaml:"discovery" toml:"discovery" json:"discovery"`
+		Runner      FileRunner    `yaml:"runner" toml:"runner" json:"runner"`
+		TLS         FileTLS       `yaml:"tls" toml:"tls" json:"tls"`
+		Log         FileLog       `yaml:"log" toml:"log" json:"log"`
+		Trace       FileTrace     `yaml:"trace" toml:"trace" json:"trace"`
+		History     FileHistory   `yaml:"history" toml:"history" json:"history"`
+		Audit       FileAudit     `yaml:"audit" toml:"audit" json:"audit"`
+		Notify      FileNotify    `yaml:"notify" toml:"notify" json:"notify"`
+		HTTP        FileHTTP      `yaml:"http" toml:"http" json:"http"`
+	}
+
+	FileDiscovery struct {
+		Type string `yaml:"type" toml:"type" json:"type"`
+		File struct {
+			Name      string `yaml:"name" toml:"name" json:"name"`
+			StateFile string `yaml:"state_file" toml:"state_file" json:"state_file"`
+		} `yaml:"file" toml:"file" json:"file"`
+		DB struct {
+			Host     string `yaml:"host" toml:"host" json:"host"`
+			User     string `yaml:"user" toml:"user" json:"user"`
+			Password string `yaml:"password" toml:"password" json:"password"`
+			Database string `yaml:"database" toml:"database" json:"database"`
+			SSLMode  string `yaml:"ssl_mode" toml:"ssl_mode" json:"ssl_mode"`
+		} `yaml:"db" toml:"db" json:"db"`
+		API struct {
+			GetUrl string `yaml:"get_url" toml:"get_url" json:"get_url"`
+			CmpUrl string `yaml:"cmp_url" toml:"cmp_url" json:"cmp_url"`
+		} `yaml:"api" toml:"api" json:"api"`
+		GRPC struct {
+			URL string `yaml:"url" toml:"url" json:"url"`
+		} `yaml:"grpc" toml:"grpc" json:"grpc"`
+	}
+
+	FileRunner struct {
+		// Type: "api", "grpc" or "mock"
+		Type    string      `yaml:"type" toml:"type" json:"type"`
+		Breaker FileBreaker `yaml:"breaker" toml:"breaker" json:"breaker"`
+	}
+
+	FileBreaker struct {
+		Threshold int    `yaml:"threshold" toml:"threshold" json:"threshold"`
+		CoolDown  string `yaml:"cool_down" toml:"cool_down" json:"cool_down"`
+	}
+
+	FileTLS struct {
+		CAFile             string `yaml:"ca_file" toml:"ca_file" json:"ca_file"`
+		CertFile           string `yaml:"cert_file" toml:"cert_file" json:"cert_file"`
+		KeyFile            string `yaml:"key_file" toml:"key_file" json:"key_file"`
+		InsecureSkipVerify bool   `yaml:"insecure_skip_verify" toml:"insecure_skip_verify" json:"insecure_skip_verify"`
+	}
+
+	FileLog struct {
+		FileName string `yaml:"file_name" toml:"file_name" json:"file_name"`
+		Level    string `yaml:"level" toml:"level" json:"level"`
+		Format   string `yaml:"format" toml:"format" json:"format"`
+		// MaxSize: e.g. "100MB"
+		MaxSize     string `yaml:"max_size" toml:"max_size" json:"max_size"`
+		RotateEvery string `yaml:"rotate_every" toml:"rotate_every" json:"rotate_every"`
+		MaxBackups  int    `yaml:"max_backups" toml:"max_backups" json:"max_backups"`
+		Compress    bool   `yaml:"compress" toml:"compress" json:"compress"`
+		Output      string `yaml:"output" toml:"output" json:"output"`
+		Syslog      struct {
+			Addr string `yaml:"addr" toml:"addr" json:"addr"`
+			App  string `yaml:"app" toml:"app" json:"app"`
+		} `yaml:"syslog" toml:"syslog" json:"syslog"`
+		HTTP struct {
+			URL    string `yaml:"url" toml:"url" json:"url"`
+			Token  string `yaml:"token" toml:"token" json:"token"`
+			Batch  int    `yaml:"batch" toml:"batch" json:"batch"`
+			Buffer int    `yaml:"buffer" toml:"buffer" json:"buffer"`
+			Flush  string `yaml:"flush" toml:"flush" json:"flush"`
+		} `yaml:"http" toml:"http" json:"http"`
+	}
+
+	FileTrace struct {
+		Exporter     string `yaml:"exporter" toml:"exporter" json:"exporter"`
+		OTLPEndpoint string `yaml:"otlp_endpoint" toml:"otlp_endpoint" json:"otlp_endpoint"`
+		OTLPInsecure bool   `yaml:"otlp_insecure" toml:"otlp_insecure" json:"otlp_insecure"`
+	}
+
+	FileHistory struct {
+		FileName   string `yaml:"file_name" toml:"file_name" json:"file_name"`
+		UseDB      bool   `yaml:"use_db" toml:"use_db" json:"use_db"`
+		MaxRecords int    `yaml:"max_records" toml:"max_records" json:"max_records"`
+		MaxAge     string `yaml:"max_age" toml:"max_age" json:"max_age"`
+	}
+
+	FileAudit struct {
+		FileName string `yaml:"file_name" toml:"file_name" json:"file_name"`
+		Actor    string `yaml:"actor" toml:"actor" json:"actor"`
+	}
+
+	FileNotify struct {
+		On          string `yaml:"on" toml:"on" json:"on"`
+		Consecutive int    `yaml:"consecutive" toml:"consecutive" json:"consecutive"`
+		Throttle    string `yaml:"throttle" toml:"throttle" json:"throttle"`
+		Dedup       string `yaml:"dedup" toml:"dedup" json:"dedup"`
+		WebhookUrl  string `yaml:"webhook_url" toml:"webhook_url" json:"webhook_url"`
+		SlackUrl    string `yaml:"slack_url" toml:"slack_url" json:"slack_url"`
+		SMTP        struct {
+			Addr     string `yaml:"addr" toml:"addr" json:"addr"`
+			User     string `yaml:"user" toml:"user" json:"user"`
+			Password string `yaml:"password" toml:"password" json:"password"`
+			From     string `yaml:"from" toml:"from" json:"from"`
+			To       string `yaml:"to" toml:"to" json:"to"`
+		} `yaml:"smtp" toml:"smtp" json:"smtp"`
+	}
+
+	FileHTTP struct {
+		MetricsAddr   string `yaml:"metrics_addr" toml:"metrics_addr" json:"metrics_addr"`
+		HealthAddr    string `yaml:"health_addr" toml:"health_addr" json:"health_addr"`
+		AdminAddr     string `yaml:"admin_addr" toml:"admin_addr" json:"admin_addr"`
+		AdminToken    string `yaml:"admin_token" toml:"admin_token" json:"admin_token"`
+		DashboardAddr string `yaml:"dashboard_addr" toml:"dashboard_addr" json:"dashboard_addr"`
+	}
+
+	setting struct {
+		env   string
+		value *string
+		file  func(*File) string
+	}
+)
+
+// settings: every environment variable, where it is kept and where it comes from in the file
+var settings = []setting{
+	{"SCH_USE_UTC", &UseUTC, func(f *File) string { return boolString(f.UseUTC) }},
+	{"SCH_TIME_ZONE", &TimeZone, func(f *File) string { return f.TimeZone }},
+	{"SCH_LOOKAHEAD", &Lookahead, func(f *File) string { return f.Lookahead }},
+	{"SCH_CONCURRENCY", &Concurrency, func(f *File) string { return intString(f.Concurrency) }},
+	{"SCH_DISCOVERY", &Discovery, func(f *File) string { return f.Discovery.Type }},
+	{"SCH_JOB_FILE_NAME", &JobFileName, func(f *File) string { return f.Discovery.File.Name }},
+	{"SCH_JOB_STATE_FILE", &JobStateFileName, func(f *File) string { return f.Discovery.File.StateFile }},
+	{"SCH_DB_HOST", &DBHost, func(f *File) string { return f.Discovery.DB.Host }},
+	{"SCH_DB_USER", &DBUser, func(f *File) string { return f.Discovery.DB.User }},
+	{"SCH_DB_PWD", &DBPwd, func(f *File) string { return f.Discovery.DB.Password }},
+	{"SCH_DB_DB", &DBDB, func(f *File) string { return f.Discovery.DB.Database }},
+	{"SCH_DB_SSL_MODE", &DBSSLMode, func(f *File) string { return f.Discovery.DB.SSLMode }},
+	{"SCH_API_GET_URL", &APIGetUrl, func(f *File) string { return f.Discovery.API.GetUrl }},
+	{"SCH_API_CMP_URL", &APICmpUrl, func(f *File) string { return f.Discovery.API.CmpUrl }},
+	{"SCH_GRPC_URL", &GRPCUrl, func(f *File) string { return f.Discovery.GRPC.URL }},
+	{"SCH_LOG_FILE_NAME", &LogFileName, func(f *File) string { return f.Log.FileName }},
+	{"SCH_LOG_LEVEL", &LogLevel, func(f *File) string { return f.Log.Level }},
+	{"SCH_LOG_FORMAT", &LogFormat, func(f *File) string { return f.Log.Format }},
+	{"SCH_LOG_MAX_SIZE", &LogMaxSize, func(f *File) string { return f.Log.MaxSize }},
+	{"SCH_LOG_ROTATE_EVERY", &LogRotateEvery, func(f *File) string { return f.Log.RotateEvery }},
+	{"SCH_LOG_MAX_BACKUPS", &LogMaxBackups, func(f *File) string { return intString(f.Log.MaxBackups) }},
+	{"SCH_LOG_COMPRESS", &LogCompress, func(f *File) string { return boolString(f.Log.Compress) }},
+	{"SCH_LOG_OUTPUT", &LogOutput, func(f *File) string { return f.Log.Output }},
+	{"SCH_LOG_SYSLOG_ADDR", &LogSyslogAddr, func(f *File) string { return f.Log.Syslog.Addr }},
+	{"SCH_LOG_SYSLOG_APP", &LogSyslogApp, func(f *File) string { return f.Log.Syslog.App }},
+	{"SCH_LOG_HTTP_URL", &LogHTTPUrl, func(f *File) string { return f.Log.HTTP.URL }},
+	{"SCH_LOG_HTTP_TOKEN", &LogHTTPToken, func(f *File) string { return f.Log.HTTP.Token }},
+	{"SCH_LOG_HTTP_BATCH", &LogHTTPBatch, func(f *File) string { return intString(f.Log.HTTP.Batch) }},
+	{"SCH_LOG_HTTP_BUFFER", &LogHTTPBuffer, func(f *File) string { return intString(f.Log.HTTP.Buffer) }},
+	{"SCH_LOG_HTTP_FLUSH", &LogHTTPFlush, func(f *File) string { return f.Log.HTTP.Flush }},
+	{"SCH_TRACE_EXPORTER", &TraceExporter, func(f *File) string { return f.Trace.Exporter }},
+	{"SCH_TRACE_OTLP_ENDPOINT", &TraceOTLPEndpoint, func(f *File) string { return f.Trace.OTLPEndpoint }},
+	{"SCH_TRACE_OTLP_INSECURE", &TraceOTLPInsecure, func(f *File) string { return boolString(f.Trace.OTLPInsecure) }},
+	{"SCH_HISTORY_FILE_NAME", &HistoryFileName, func(f *File) string { return f.History.FileName }},
+	{"SCH_HISTORY_USE_DB", &HistoryUseDB, func(f *File) string { return boolString(f.History.UseDB) }},
+	{"SCH_HISTORY_MAX_RECORDS", &HistoryMaxRecords, func(f *File) string { return intString(f.History.MaxRecords) }},
+	{"SCH_HISTORY_MAX_AGE", &HistoryMaxAge, func(f *File) string { return f.History.MaxAge }},
+	{"SCH_AUDIT_FILE_NAME", &AuditFileName, func(f *File) string { return f.Audit.FileName }},
+	{"SCH_AUDIT_ACTOR", &AuditActor, func(f *File) string { return f.Audit.Actor }},
+	{"SCH_METRICS_ADDR", &MetricsAddr, func(f *File) string { return f.HTTP.MetricsAddr }},
+	{"SCH_HEALTH_ADDR", &HealthAddr, func(f *File) string { return f.HTTP.HealthAddr }},
+	{"SCH_ADMIN_ADDR", &AdminAddr, func(f *File) string { return f.HTTP.AdminAddr }},
+	{"SCH_ADMIN_TOKEN", &AdminToken, func(f *File) string { return f.HTTP.AdminToken }},
+	{"SCH_DASHBOARD_ADDR", &DashboardAddr, func(f *File) string { return f.HTTP.DashboardAddr }},
+	{"SCH_USE_API", &UseRunnerAPI, func(f *File) string { return boolString(f.Runner.Type == "api") }},
+	{"SCH_USE_GRPC", &UseRunnerGRPC, func(f *File) string { return boolString(f.Runner.Type == "grpc") }},
+	{"SCH_TLS_CA_FILE", &TLSCAFile, func(f *File) string { return f.TLS.CAFile }},
+	{"SCH_TLS_CERT_FILE", &TLSCertFile, func(f *File) string { return f.TLS.CertFile }},
+	{"SCH_TLS_KEY_FILE", &TLSKeyFile, func(f *File) string { return f.TLS.KeyFile }},
+	{"SCH_TLS_INSECURE_SKIP_VERIFY", &TLSInsecureSkipVerify, func(f *File) string { return boolString(f.TLS.InsecureSkipVerify) }},
+	{"SCH_BREAKER_THRESHOLD", &BreakerThreshold, func(f *File) string { return intString(f.Runner.Breaker.Threshold) }},
+	{"SCH_BREAKER_COOLDOWN", &BreakerCoolDown, func(f *File) string { return f.Runner.Breaker.CoolDown }},
+	{"SCH_NOTIFY_ON", &NotifyOn, func(f *File) string { return f.Notify.On }},
+	{"SCH_NOTIFY_CONSECUTIVE", &NotifyConsecutive, func(f *File) string { return intString(f.Notify.Consecutive) }},
+	{"SCH_NOTIFY_THROTTLE", &NotifyThrottle, func(f *File) string { return f.Notify.Throttle }},
+	{"SCH_NOTIFY_DEDUP", &NotifyDedup, func(f *File) string { return f.Notify.Dedup }},
+	{"SCH_NOTIFY_WEBHOOK_URL", &NotifyWebhookUrl, func(f *File) string { return f.Notify.WebhookUrl }},
+	{"SCH_NOTIFY_SLACK_URL", &NotifySlackUrl, func(f *File) string { return f.Notify.SlackUrl }},
+	{"SCH_SMTP_ADDR", &SMTPAddr, func(f *File) string { return f.Notify.SMTP.Addr }},
+	{"SCH_SMTP_USER", &SMTPUser, func(f *File) string { return f.Notify.SMTP.User }},
+	{"SCH_SMTP_PWD", &SMTPPwd, func(f *File) string { return f.Notify.SMTP.Password }},
+	{"SCH_SMTP_FROM", &SMTPFrom, func(f *File) string { return f.Notify.SMTP.From }},
+	{"SCH_SMTP_TO", &SMTPTo, func(f *File) string { return f.Notify.SMTP.To }},
+}
+
+// mu: guards the settings, Load and Restore write them while the http handlers, the running jobs and the loggers
+// read them, the goroutine calling Load (the main loop) reads them directly, anywhere else use Get or a getter
+var mu sync.RWMutex
+
+// Get: the value of a setting read outside of the goroutine calling Load, e.g. config.Get(&config.AdminToken)
+func Get(value *string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return *value
+}
+
+// GetAll: the values of several settings read at once, e.g. the files of the tls config
+func GetAll(values ...*string) []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	all := make([]string, len(values))
+	for i, value := range values {
+		all[i] = *value
+	}
+	return all
+}
+
+// Load: set every setting from the environment, falling back to the file (if fileName is not empty), then validate,
+// the settings are swapped in only once valid, on error the current ones are kept
+func Load(fileName string) error {
+	var file *File
+	if len(fileName) > 0 {
+		f, errRead := ReadFile(fileName)
+		if errRead != nil {
+			return errRead
+		}
+		file = f
+		if len(file.Runner.Type) > 0 && file.Runner.Type != "api" && file.Runner.Type != "grpc" && file.Runner.Type != "mock" {
+			return fmt.Errorf("Invalid configuration file %s: runner type must be api, grpc or mock, got: %q", fileName, file.Runner.Type)
+		}
+	}
+	loaded := make(map[string]string, len(settings))
+	for _, s := range settings {
+		value := os.Getenv(s.env)
+		if len(value) == 0 && file != nil {
+			value = s.file(file)
+		}
+		loaded[s.env] = value
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	before, beforeFile := snapshot(), ConfigFile
+	restore(loaded)
+	ConfigFile = fileName
+	if errValidate := Validate(); errValidate != nil {
+		restore(before)
+		ConfigFile = beforeFile
+		return errValidate
+	}
+	return nil
+}
+
+// ReadFile: decode the configuration file by its extension
+func ReadFile(fileName string) (file *File, err error) {
+	bContent, errRead := ioutil.ReadFile(fileName)
+	if errRead != nil {
+		err = fmt.Errorf("Unable to read configuration file: %s", errRead)
+		return
+	}
+	file = &File{}
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(bContent))
+		dec.KnownFields(true)
+		if errDec := dec.Decode(file); errDec != nil && !errors.Is(errDec, io.EOF) {
+			err = errDec
+		}
+	case ".toml":
+		md, errDec := toml.Decode(string(bContent), file)
+		if errDec != nil {
+			err = errDec
+		} else if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			err = fmt.Errorf("unknown key: %s", undecoded[0])
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(bContent))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(file)
+	default:
+		err = fmt.Errorf("unknown extension, use .yaml, .yml, .toml or .json")
+	}
+	if err != nil {
+		err = fmt.Errorf("Invalid configuration file %s: %s", fileName, err)
+	}
+	return
+}
+
+// Validate: check every setting, all the problems are returned in one error
+func Validate() error {
+	problems := []string{}
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	for _, s := range []setting{
+		{env: "SCH_USE_UTC", value: &UseUTC},
+		{env: "SCH_USE_API", value: &UseRunnerAPI},
+		{env: "SCH_USE_GRPC", value: &UseRunnerGRPC},
+		{env: "SCH_HISTORY_USE_DB", value: &HistoryUseDB},
+		{env: "SCH_TLS_INSECURE_SKIP_VERIFY", value: &TLSInsecureSkipVerify},
+		{env: "SCH_LOG_COMPRESS", value: &LogCompress},
+		{env: "SCH_TRACE_OTLP_INSECURE", value: &TraceOTLPInsecure},
+	} {
+		if len(*s.value) > 0 && *s.value != "true" && *s.value != "false" {
+			add("%s: must be true or false, got: %q", s.env, *s.value)
+		}
+	}
+	for _, s := range []setting{
+		{env: "SCH_LOOKAHEAD", value: &Lookahead},
+		{env: "SCH_HISTORY_MAX_AGE", value: &HistoryMaxAge},
+		{env: "SCH_BREAKER_COOLDOWN", value: &BreakerCoolDown},
+		{env: "SCH_NOTIFY_THROTTLE", value: &NotifyThrottle},
+		{env: "SCH_NOTIFY_DEDUP", value: &NotifyDedup},
+		{env: "SCH_LOG_ROTATE_EVERY", value: &LogRotateEvery},
+		{env: "SCH_LOG_HTTP_FLUSH", value: &LogHTTPFlush},
+	} {
+		if d, errParse := time.ParseDuration(*s.value); len(*s.value) > 0 && (errParse != nil || d <= 0) {
+			add("%s: must be a positive duration e.g. \"5m\", got: %q", s.env, *s.value)
+		}
+	}
+	for _, s := range []setting{
+		{env: "SCH_CONCURRENCY", value: &Concurrency},
+		{env: "SCH_HISTORY_MAX_RECORDS", value: &HistoryMaxRecords},
+		{env: "SCH_BREAKER_THRESHOLD", value: &BreakerThreshold},
+		{env: "SCH_NOTIFY_CONSECUTIVE", value: &NotifyConsecutive},
+		{env: "SCH_LOG_MAX_BACKUPS", value: &LogMaxBackups},
+		{env: "SCH_LOG_HTTP_BATCH", value: &LogHTTPBatch},
+		{env: "SCH_LOG_HTTP_BUFFER", value: &LogHTTPBuffer},
+	} {
+		if n, errAtoi := strconv.Atoi(*s.value); len(*s.value) > 0 && (errAtoi != nil || n < 0) {
+			add("%s: must be a number 0 or above, got: %q", s.env, *s.value)
+		}
+	}
+	if len(TimeZone) > 0 {
+		if _, errLoad := time.LoadLocation(TimeZone); errLoad != nil {
+			add("SCH_TIME_ZONE: unknown time zone: %q", TimeZone)
+		}
+	}
+	if UseRunnerAPI == "true" && UseRunnerGRPC == "true" {
+		add("SCH_USE_API and SCH_USE_GRPC: only one runner can be used")
+	}
+	types := DiscoveryTypes()
+	if len(types) == 0 {
+		add("SCH_DISCOVERY: no discovery configured, set a job file, db, api or grpc url (or \"mock\" to use the mock)")
+	}
+	seen := map[string]bool{}
+	for _, discovery := range types {
+		if seen[discovery] {
+			add("SCH_DISCOVERY: %s is listed more than once", discovery)
+		}
+		seen[discovery] = true
+		switch discovery {
+		case DiscoveryFile:
+			if len(JobFileName) == 0 {
+				add("SCH_JOB_FILE_NAME: required by the file discovery")
+			}
+		case DiscoveryDB:
+			for env, value := range map[string]string{"SCH_DB_HOST": DBHost, "SCH_DB_USER": DBUser, "SCH_DB_DB": DBDB} {
+				if len(value) == 0 {
+					add("%s: required by the db discovery", env)
+				}
+			}
+		case DiscoveryAPI:
+			if len(APIGetUrl) == 0 || len(APICmpUrl) == 0 {
+				add("SCH_API_GET_URL and SCH_API_CMP_URL: required by the api discovery")
+			}
+		case DiscoveryGRPC:
+			if len(GRPCUrl) == 0 {
+				add("SCH_GRPC_URL: required by the grpc discovery")
+			}
+		case DiscoveryMock:
+		default:
+			add("SCH_DISCOVERY: must be file, db, api, grpc or mock, got: %q", discovery)
+		}
+	}
+	if len(AdminAddr) > 0 && len(AdminToken) == 0 && !loopback(AdminAddr) {
+		add("SCH_ADMIN_ADDR: %q is open to other hosts, set SCH_ADMIN_TOKEN or bind to localhost e.g. \"127.0.0.1:8082\"", AdminAddr)
+	}
+	if len(DashboardAddr) > 0 && DashboardAddr == AdminAddr {
+		add("SCH_DASHBOARD_ADDR: both the dashboard and the admin api serve / on %q, use another address", DashboardAddr)
+	}
+	if HistoryUseDB == "true" && len(DBHost) == 0 {
+		add("SCH_HISTORY_USE_DB: requires SCH_DB_HOST")
+	}
+	if (len(TLSCertFile) > 0) != (len(TLSKeyFile) > 0) {
+		add("SCH_TLS_CERT_FILE and SCH_TLS_KEY_FILE: both are required for a client certificate")
+	}
+	for env, value := range map[string]string{"SCH_TLS_CA_FILE": TLSCAFile, "SCH_TLS_CERT_FILE": TLSCertFile, "SCH_TLS_KEY_FILE": TLSKeyFile} {
+		if len(value) > 0 {
+			if _, errStat := os.Stat(value); errStat != nil {
+				add("%s: %s", env, errStat)
+			}
+		}
+	}
+	switch strings.ToLower(LogLevel) {
+	case "", "debug", "info", "warn", "warning", "error":
+	default:
+		add("SCH_LOG_LEVEL: must be debug, info, warn or error, got: %q", LogLevel)
+	}
+	if _, errSize := ParseSize(LogMaxSize); errSize != nil {
+		add("SCH_LOG_MAX_SIZE: %s", errSize)
+	}
+	switch strings.ToLower(LogFormat) {
+	case "", "text", "json":
+	default:
+		add("SCH_LOG_FORMAT: must be text or json, got: %q", LogFormat)
+	}
+	switch LogOutputType() {
+	case LogOutputStdOut:
+	case LogOutputFile:
+		if len(LogFileName) == 0 {
+			add("SCH_LOG_FILE_NAME: required by the file log output")
+		}
+	case LogOutputSyslog:
+		u, errParse := url.Parse(LogSyslogAddr)
+		switch {
+		case len(LogSyslogAddr) == 0:
+			add("SCH_LOG_SYSLOG_ADDR: required by the syslog log output")
+		case errParse != nil || (u.Scheme != "udp" && u.Scheme != "tcp" && u.Scheme != "unix"):
+			add("SCH_LOG_SYSLOG_ADDR: must be udp://host:port, tcp://host:port or unix:///path, got: %q", LogSyslogAddr)
+		}
+	case LogOutputHTTP:
+		if len(LogHTTPUrl) == 0 {
+			add("SCH_LOG_HTTP_URL: required by the http log output")
+		}
+	default:
+		add("SCH_LOG_OUTPUT: must be stdout, file, syslog or http, got: %q", LogOutput)
+	}
+	switch TraceExporter {
+	case "", TraceExporterOTLP, TraceExporterStdOut:
+	default:
+		add("SCH_TRACE_EXPORTER: must be otlp or stdout, got: %q", TraceExporter)
+	}
+	for _, rule := range strings.Split(NotifyOn, ",") {
+		switch strings.TrimSpace(rule) {
+		case "", NotifyOnFirst, NotifyOnConsecutive, NotifyOnRecovery:
+		default:
+			add("SCH_NOTIFY_ON: must be first, consecutive and/or recovery, got: %q", rule)
+		}
+	}
+	if len(SMTPAddr) > 0 {
+		if _, _, errSplit := net.SplitHostPort(SMTPAddr); errSplit != nil {
+			add("SCH_SMTP_ADDR: must be host:port, got: %q", SMTPAddr)
+		}
+		if len(SMTPFrom) == 0 {
+			add("SCH_SMTP_FROM: required by the smtp notifier")
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	// map iteration, keep the output stable
+	sort.Strings(problems)
+	return fmt.Errorf("Invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+}
+
+// DiscoveryTypes: the discoveries listed in SCH_DISCOVERY (comma separated, e.g. "file,db" to merge the jobs of
+// both), or the one of DiscoveryType when not set
+func DiscoveryTypes() (types []string) {
+	for _, discovery := range strings.Split(Discovery, ",") {
+		if discovery = strings.ToLower(strings.TrimSpace(discovery)); len(discovery) > 0 {
+			types = append(types, discovery)
+		}
+	}
+	if len(types) == 0 {
+		if discovery := DiscoveryType(); len(discovery) > 0 {
+			types = []string{discovery}
+		}
+	}
+	return
+}
+
+// DiscoveryType: SCH_DISCOVERY or the first discovery with settings: file, db, api and then grpc
+func DiscoveryType() string {
+	if len(Discovery) > 0 {
+		return strings.ToLower(Discovery)
+	}
+	switch {
+	case len(JobFileName) > 0:
+		return DiscoveryFile
+	case len(DBHost) > 0:
+		return DiscoveryDB
+	case len(APIGetUrl) > 0:
+		return DiscoveryAPI
+	case len(GRPCUrl) > 0:
+		return DiscoveryGRPC
+	}
+	return ""
+}
+
+// GetLookahead: how far ahead discovery looks for jobs
+func GetLookahead() time.Duration {
+	if d, errParse := time.ParseDuration(Get(&Lookahead)); errParse == nil && d > 0 {
+		return d
+	}
+	return defaultLookahead
+}
+
+// GetConcurrency: max number of jobs running at once, 0 is no limit
+func GetConcurrency() int {
+	n, errAtoi := strconv.Atoi(Concurrency)
+	if errAtoi != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// GetDBSSLMode: sslmode of the db connection
+func GetDBSSLMode() string {
+	if len(DBSSLMode) > 0 {
+		return DBSSLMode
+	}
+	return "disable"
+}
+
+// ParseSize: a size in bytes e.g. "1048576", "512KB", "100MB" or "1GB", empty is 0
+func ParseSize(value string) (int64, error) {
+	size := strings.ToUpper(strings.TrimSpace(value))
+	if len(size) == 0 {
+		return 0, nil
+	}
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"B", 1}} {
+		if strings.HasSuffix(size, unit.suffix) {
+			size = strings.TrimSpace(strings.TrimSuffix(size, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	n, errParse := strconv.ParseInt(size, 10, 64)
+	if errParse != nil || n < 0 {
+		return 0, fmt.Errorf("must be a size e.g. \"100MB\", got: %q", value)
+	}
+	return n * multiplier, nil
+}
+
+// GetLogMaxSize: the size the log file is rotated at, 0 is never
+func GetLogMaxSize() int64 {
+	size, _ := ParseSize(LogMaxSize)
+	return size
+}
+
+// GetLogRotateEvery: how often the log file is rotated, 0 is never
+func GetLogRotateEvery() time.Duration {
+	d, _ := time.ParseDuration(LogRotateEvery)
+	return d
+}
+
+// GetLogMaxBackups: the rotated log files kept, 0 is all
+func GetLogMaxBackups() int {
+	n, _ := strconv.Atoi(LogMaxBackups)
+	return n
+}
+
+// LogOutputType: SCH_LOG_OUTPUT or the first log output with settings: file, syslog, http and then stdout
+func LogOutputType() string {
+	if len(LogOutput) > 0 {
+		return strings.ToLower(LogOutput)
+	}
+	switch {
+	case len(LogFileName) > 0:
+		return LogOutputFile
+	case len(LogSyslogAddr) > 0:
+		return LogOutputSyslog
+	case len(LogHTTPUrl) > 0:
+		return LogOutputHTTP
+	}
+	return LogOutputStdOut
+}
+
+// GetLogHTTPBatch: the entries per post of the http log output, 0 is the default
+func GetLogHTTPBatch() int {
+	n, _ := strconv.Atoi(LogHTTPBatch)
+	return n
+}
+
+// GetLogHTTPBuffer: the entries kept in memory by the http log output, 0 is the default
+func GetLogHTTPBuffer() int {
+	n, _ := strconv.Atoi(LogHTTPBuffer)
+	return n
+}
+
+// GetLogHTTPFlush: how often the http log output sends, 0 is the default
+func GetLogHTTPFlush() time.Duration {
+	d, _ := time.ParseDuration(LogHTTPFlush)
+	return d
+}
+
+// GetTraceOTLPEndpoint: the otlp collector's endpoint
+func GetTraceOTLPEndpoint() string {
+	if len(TraceOTLPEndpoint) == 0 {
+		return defaultTraceOTLPEndpoint
+	}
+	return TraceOTLPEndpoint
+}
+
+// GetNotifyOn: the rules of SCH_NOTIFY_ON
+func GetNotifyOn() (rules []string) {
+	on := NotifyOn
+	if len(on) == 0 {
+		on = defaultNotifyOn
+	}
+	for _, rule := range strings.Split(on, ",") {
+		if rule = strings.TrimSpace(rule); len(rule) > 0 {
+			rules = append(rules, rule)
+		}
+	}
+	return
+}
+
+// GetNotifyConsecutive: the failures in a row of the "consecutive" rule
+func GetNotifyConsecutive() int {
+	if n, errAtoi := strconv.Atoi(NotifyConsecutive); errAtoi == nil && n > 0 {
+		return n
+	}
+	return defaultNotifyConsecutive
+}
+
+// GetNotifyThrottle: min time between two failure alerts of a job
+func GetNotifyThrottle() time.Duration {
+	if d, errParse := time.ParseDuration(NotifyThrottle); errParse == nil && d > 0 {
+		return d
+	}
+	return defaultNotifyThrottle
+}
+
+// GetNotifyDedup: how long the same error of a job is not alerted again
+func GetNotifyDedup() time.Duration {
+	if d, errParse := time.ParseDuration(NotifyDedup); errParse == nil && d > 0 {
+		return d
+	}
+	return defaultNotifyDedup
+}
+
+var location = struct {
+	sync.Mutex
+	name string
+	loc  *time.Location
+}{}
+
+// GetLocation: the location of SCH_TIME_ZONE, nil if not set (or unknown) to keep the system's location
+func GetLocation() *time.Location {
+	timeZone := Get(&TimeZone)
+	location.Lock()
+	defer location.Unlock()
+	if location.name != timeZone {
+		location.name = timeZone
+		location.loc = nil
+		if len(timeZone) > 0 {
+			location.loc, _ = time.LoadLocation(timeZone)
+		}
+	}
+	return location.loc
+}
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+	return ""
+}
+
+func intString(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// secrets are never shown by Diff
+var secrets = map[string]bool{"SCH_DB_PWD": true, "SCH_ADMIN_TOKEN": true, "SCH_SMTP_PWD": true, "SCH_LOG_HTTP_TOKEN": true}
+
+// Snapshot: the current value of every setting by its environment variable
+func Snapshot() map[string]string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return snapshot()
+}
+
+// Restore: put back the settings of a Snapshot, e.g. when the adapters of a reload could not be built
+func Restore(snapshot map[string]string) {
+	mu.Lock()
+	defer mu.Unlock()
+	restore(snapshot)
+}
+
+func snapshot() map[string]string {
+	values := make(map[string]string, len(settings))
+	for _, s := range settings {
+		values[s.env] = *s.value
+	}
+	return values
+}
+
+func restore(values map[string]string) {
+	for _, s := range settings {
+		*s.value = values[s.env]
+	}
+}
+
+// Diff: one line per setting changed between two snapshots, sorted, secrets are masked
+func Diff(before, after map[string]string) (changes []string) {
+	for _, s := range settings {
+		from, to := before[s.env], after[s.env]
+		if from == to {
+			continue
+		}
+		if secrets[s.env] {
+			from, to = mask(from), mask(to)
+		}
+		changes = append(changes, fmt.Sprintf("%s: %q => %q", s.env, from, to))
+	}
+	sort.Strings(changes)
+	return
+}
+
+func mask(value string) string {
+	if len(value) == 0 {
+		return value
+	}
+	return "***"
+}
+
+// loopback: the address only listens on the local host
+func loopback(addr string) bool {
+	host, _, errSplit := net.SplitHostPort(addr)
+	if errSplit != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	fileName := "/tmp/" + name
+	if errWrite := ioutil.WriteFile(fileName, []byte(content), 0644); errWrite != nil {
+		t.Fatal(errWrite)
+	}
+	return fileName
+}
+
+func TestLoadYAMLSuccess(t *testing.T) {
+	fileName := writeConfig(t, "config_test.yaml", `
+time_zone: America/Denver
+lookahead: 5m
+concurrency: 4
+discovery:
+  file:
+    name: /tmp/jobs.json
+runner:
+  type: api
+  breaker:
+    threshold: 3
+`)
+	defer os.Remove(fileName)
+	defer Load("")
+	err := Load(fileName)
+	assert.Nil(t, err)
+	assert.Equal(t, DiscoveryFile, DiscoveryType())
+	assert.Equal(t, "/tmp/jobs.json", JobFileName)
+	assert.Equal(t, "true", UseRunnerAPI)
+	assert.Equal(t, "3", BreakerThreshold)
+	assert.Equal(t, 5*time.Minute, GetLookahead())
+	assert.Equal(t, 4, GetConcurrency())
+	assert.Equal(t, "America/Denver", GetLocation().String())
+}
+
+func TestLoadEnvOverridesFile(t *testing.T) {
+	fileName := writeConfig(t, "config_test.toml", `
+[discovery]
+type = "mock"
+[history]
+max_age = "24h"
+`)
+	defer os.Remove(fileName)
+	os.Setenv("SCH_HISTORY_MAX_AGE", "48h")
+	defer os.Unsetenv("SCH_HISTORY_MAX_AGE")
+	defer Load("")
+	err := Load(fileName)
+	assert.Nil(t, err)
+	assert.Equal(t, DiscoveryMock, DiscoveryType())
+	assert.Equal(t, "48h", HistoryMaxAge)
+}
+
+func TestLoadUnknownKeyFailure(t *testing.T) {
+	fileName := writeConfig(t, "config_test.json", `{"discovery": {"type": "mock"}, "lookahed": "5m"}`)
+	defer os.Remove(fileName)
+	defer Load("")
+	err := Load(fileName)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "lookahed")
+}
+
+func TestValidateFailure(t *testing.T) {
+	fileName := writeConfig(t, "config_test.yml", `
+lookahead: soon
+time_zone: Nowhere/Town
+discovery:
+  type: db
+  db:
+    host: localhost
+tls:
+  cert_file: /tmp/cert.pem
+`)
+	defer os.Remove(fileName)
+	defer Load("")
+	err := Load(fileName)
+	assert.NotNil(t, err)
+	for _, expected := range []string{"SCH_LOOKAHEAD", "SCH_TIME_ZONE", "SCH_DB_USER", "SCH_DB_DB", "SCH_TLS_KEY_FILE"} {
+		assert.Contains(t, err.Error(), expected)
+	}
+}
+
+func TestValidateAdminAddr(t *testing.T) {
+	fileName := writeConfig(t, "config_test_admin.yml", `
+discovery:
+  type: mock
+http:
+  admin_addr: ":8082"
+`)
+	defer os.Remove(fileName)
+	defer Load("")
+	err := Load(fileName)
+	assert.NotNil(t, err, "Expected an admin api open to other hosts without a token to fail")
+	assert.Contains(t, err.Error(), "SCH_ADMIN_ADDR")
+	for _, addr := range []string{"127.0.0.1:8082", "localhost:8082", "[::1]:8082"} {
+		writeConfig(t, "config_test_admin.yml", "discovery:\n  type: mock\nhttp:\n  admin_addr: \""+addr+"\"\n")
+		assert.Nil(t, Load(fileName), "Expected %s to be allowed without a token", addr)
+	}
+	writeConfig(t, "config_test_admin.yml", "discovery:\n  type: mock\nhttp:\n  admin_addr: \":8082\"\n  admin_token: secret\n")
+	assert.Nil(t, Load(fileName), "Expected a token to allow any address")
+	writeConfig(t, "config_test_admin.yml", "discovery:\n  type: mock\nhttp:\n  admin_addr: \"127.0.0.1:8082\"\n  dashboard_addr: \"127.0.0.1:8082\"\n")
+	err = Load(fileName)
+	assert.NotNil(t, err, "Expected the dashboard and admin api on one address to fail")
+	assert.Contains(t, err.Error(), "SCH_DASHBOARD_ADDR")
+}
+
+func TestValidateNoDiscoveryFailure(t *testing.T) {
+	err := Load("")
+	assert.NotNil(t, err, "Expected an error instead of falling back to the mock")
+	assert.Contains(t, err.Error(), "SCH_DISCOVERY")
+}
+
+func TestDiffMasksSecrets(t *testing.T) {
+	before := map[string]string{"SCH_LOOKAHEAD": "3m", "SCH_ADMIN_TOKEN": "old"}
+	after := map[string]string{"SCH_LOOKAHEAD": "5m", "SCH_ADMIN_TOKEN": "new"}
+	changes := Diff(before, after)
+	assert.Equal(t, []string{`SCH_ADMIN_TOKEN: "***" => "***"`, `SCH_LOOKAHEAD: "3m" => "5m"`}, changes)
+}
+
+func TestDiscoveryTypesList(t *testing.T) {
+	os.Setenv("SCH_DISCOVERY", "file, mock,file")
+	os.Setenv("SCH_JOB_FILE_NAME", "/tmp/jobs.json")
+	defer os.Unsetenv("SCH_DISCOVERY")
+	defer os.Unsetenv("SCH_JOB_FILE_NAME")
+	defer Load("")
+	before := Discovery
+	err := Load("")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "file is listed more than once")
+	assert.Equal(t, before, Discovery, "Expected the invalid settings not to be swapped in")
+	Discovery = "file, mock,file"
+	assert.Equal(t, []string{DiscoveryFile, DiscoveryMock, DiscoveryFile}, DiscoveryTypes())
+	Discovery = before
+}
+
+func TestValidateNotifyFailure(t *testing.T) {
+	fileName := writeConfig(t, "config_test_notify.yaml", `
+discovery:
+  type: mock
+notify:
+  on: first,always
+  throttle: soon
+  smtp:
+    addr: smtp.example.com
+`)
+	defer os.Remove(fileName)
+	defer Load("")
+	err := Load(fileName)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `SCH_NOTIFY_ON: must be first, consecutive and/or recovery, got: "always"`)
+	assert.Contains(t, err.Error(), "SCH_NOTIFY_THROTTLE")
+	assert.Contains(t, err.Error(), "SCH_SMTP_ADDR")
+	assert.Contains(t, err.Error(), "SCH_SMTP_FROM")
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	h "github.com/keenfury/axenda/history"
+	j "github.com/keenfury/axenda/job"
+	"github.com/keenfury/axenda/util"
+)
+
+/*
+Read only dashboard, served when SCH_DASHBOARD_ADDR is set
+- jobs known by discovery with their next run time
+- upcoming runs for the next 24 hours
+- what's in flight (the scheduler's array of Job)
+- recent failures from the run history
+Built once a minute for all the viewers, behind AdminAuth when SCH_ADMIN_TOKEN is set
+*/
+
+const (
+	dashboardWindow      = 24 * time.Hour
+	dashboardMaxUpcoming = 200
+	dashboardMaxFailures = 50
+)
+
+type (
+	DashboardData struct {
+		Now       time.Time
+		Discovery string
+		Jobs      []DashboardJob
+		Upcoming  []DashboardRun
+		InFlight  []j.Job
+		Failures  []h.Record
+		Errors    []string
+	}
+
+	DashboardJob struct {
+		j.Job
+		NextRun time.Time
+	}
+
+	DashboardRun struct {
+		RunTime time.Time
+		Token   string
+		JobName string
+	}
+)
+
+// dashboardCache: the dashboard of the current minute, the page refreshes every minute for every viewer and the
+// discovery would be asked for its jobs each time
+var dashboardCache struct {
+	sync.Mutex
+	minute time.Time
+	data   DashboardData
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"fmtTime": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Format("2006-01-02 15:04 MST")
+	},
+}).Parse(dashboardHTML))
+
+// DashboardAuthHandler: the dashboard behind the admin token
+func DashboardAuthHandler() http.Handler {
+	return AdminAuth(http.HandlerFunc(DashboardHandler))
+}
+
+func DashboardHandler(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.NotFound(w, req)
+		return
+	}
+	data := CachedDashboard(util.GetNow())
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if errExec := dashboardTemplate.Execute(w, data); errExec != nil {
+		logAdapter.Error("DashboardHandler: unable to render", "error", errExec)
+	}
+}
+
+// CachedDashboard: the dashboard of now's minute, built by its first viewer
+func CachedDashboard(now time.Time) DashboardData {
+	minute := util.TruncateTimeToMinute(now)
+	dashboardCache.Lock()
+	defer dashboardCache.Unlock()
+	if !dashboardCache.minute.Equal(minute) {
+		dashboardCache.data = BuildDashboard(now)
+		dashboardCache.minute = minute
+	}
+	return dashboardCache.data
+}
+
+// BuildDashboard: gather the jobs, upcoming runs, in flight jobs and failures as of now
+func BuildDashboard(now time.Time) (data DashboardData) {
+	data.Now = now
+	data.Discovery = CurrentDiscovery().WhichDiscovery()
+	jobs, errJobs := ListAllJobs(now.Add(dashboardWindow))
+	if errJobs != nil {
+		data.Errors = append(data.Errors, fmt.Sprintf("Jobs: %s", errJobs))
+	}
+	end := now.Add(dashboardWindow)
+	for _, job := range jobs {
+		dj := DashboardJob{Job: job}
+		runTimes, errNext := NextRunTimes(job, now, dashboardMaxUpcoming)
+		if errNext != nil {
+			data.Errors = append(data.Errors, fmt.Sprintf("%s: %s", job.Token, errNext))
+		}
+		if len(runTimes) > 0 {
+			dj.NextRun = runTimes[0]
+		}
+		for _, t := range runTimes {
+			if t.After(end) {
+				break
+			}
+			data.Upcoming = append(data.Upcoming, DashboardRun{RunTime: t, Token: job.Token, JobName: job.JobName})
+		}
+		data.Jobs = append(data.Jobs, dj)
+	}
+	sort.Slice(data.Upcoming, func(a, b int) bool { return data.Upcoming[a].RunTime.Before(data.Upcoming[b].RunTime) })
+	if len(data.Upcoming) > dashboardMaxUpcoming {
+		data.Upcoming = data.Upcoming[:dashboardMaxUpcoming]
+	}
+	data.InFlight = CurrentJobs()
+	records, errList := CurrentHistory().List("", now.Add(-7*dashboardWindow), time.Time{})
+	if errList != nil {
+		data.Errors = append(data.Errors, fmt.Sprintf("History: %s", errList))
+	}
+	for i := len(records) - 1; i >= 0 && len(data.Failures) < dashboardMaxFailures; i-- {
+		if records[i].Result == h.ResultError {
+			data.Failures = append(data.Failures, records[i])
+		}
+	}
+	return
+}
+
+const dashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="60">
+<title>Axenda</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; width: 100%; }
+th, td { border-bottom: 1px solid #ddd; padding: 4px 8px; text-align: left; font-size: 0.9em; }
+th { background: #f4f4f4; }
+.error { color: #b00; }
+.muted { color: #888; }
+</style>
+</head>
+<body>
+<h1>Axenda</h1>
+<p class="muted">{{.Discovery}} &middot; {{fmtTime .Now}}</p>
+{{range .Errors}}<p class="error">{{.}}</p>{{end}}
+
+<h2>In Flight</h2>
+<table>
+<tr><th>Token</th><th>Name</th><th>Run Time</th><th>Status</th></tr>
+{{range .InFlight}}<tr><td>{{.Token}}</td><td>{{.JobName}}</td><td>{{fmtTime .RunTime}}</td><td>{{.Status}}</td></tr>
+{{else}}<tr><td colspan="4" class="muted">Nothing in flight</td></tr>{{end}}
+</table>
+
+<h2>Upcoming (next 24 hours)</h2>
+<table>
+<tr><th>Run Time</th><th>Token</th><th>Name</th></tr>
+{{range .Upcoming}}<tr><td>{{fmtTime .RunTime}}</td><td>{{.Token}}</td><td>{{.JobName}}</td></tr>
+{{else}}<tr><td colspan="3" class="muted">Nothing scheduled</td></tr>{{end}}
+</table>
+
+<h2>Recent Failures</h2>
+<table>
+<tr><th>Start</th><th>Token</th><th>Name</th><th>Runner</th><th>Error</th></tr>
+{{range .Failures}}<tr><td>{{fmtTime .Start}}</td><td>{{.Token}}</td><td>{{.JobName}}</td><td>{{.Runner}}</td><td class="error">{{.Error}}</td></tr>
+{{else}}<tr><td colspan="5" class="muted">No failures</td></tr>{{end}}
+</table>
+
+<h2>Jobs</h2>
+<table>
+<tr><th>Token</th><th>Name</th><th>Next Run</th><th>Frequency</th><th>Active</th><th>Url</th></tr>
+{{range .Jobs}}<tr><td>{{.Token}}</td><td>{{.JobName}}</td><td>{{fmtTime .NextRun}}</td><td>{{.Frequency}}</td><td>{{.Active}}</td><td>{{.UrlPath}}</td></tr>
+{{else}}<tr><td colspan="6" class="muted">No jobs</td></tr>{{end}}
+</table>
+</body>
+</html>
+`
@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/keenfury/axenda/clock"
 	"github.com/keenfury/axenda/config"
 	f "github.com/keenfury/axenda/frequency"
 	j "github.com/keenfury/axenda/job"
+	"github.com/keenfury/axenda/metrics"
 	r "github.com/keenfury/axenda/runner"
 	"github.com/keenfury/axenda/util"
 )
@@ -14,6 +16,7 @@ import (
 type (
 	API struct {
 		Runner r.RunnerAdapter
+		Clock  clock.Clock
 	}
 )
 
@@ -22,31 +25,32 @@ func (a *API) WhichDiscovery() string {
 }
 
 func (a *API) GetJobs(t time.Time) (jobs []j.Job, err error) {
+	defer func() { metrics.ObserveDiscovery("API", len(jobs), err) }()
 	if t.IsZero() {
 		err = fmt.Errorf("Zero time")
 		return
 	}
-	newRunTime := t.Add(3 * time.Minute)
-	url := fmt.Sprintf("%s/%d", config.APIGetUrl, newRunTime.Unix())
+	newRunTime := t.Add(config.GetLookahead())
+	url := fmt.Sprintf("%s/%d", config.Get(&config.APIGetUrl), newRunTime.Unix())
 	err = util.SimpleRequest("GET", url, nil, &jobs, 200, nil)
 	return
 }
 
 func (a *API) StartJob(job j.Job, updateCh chan<- j.Job) (err error) {
-	job.Status = "In Process"
+	job.Status = j.StatusInProcess
 	updateCh <- job
 	a.Runner.RunJob(&job)
 	return
 }
 
 func (a *API) CompleteJob(job j.Job, updateCh chan<- j.Job) (err error) {
-	job.Status = "Done"
+	job.Status = j.StatusDone
 	updateCh <- job
-	errUpdate := f.Update(&job)
+	errUpdate := f.UpdateAt(&job, util.NowFrom(a.Clock))
 	if errUpdate != nil {
 		return errUpdate
 	}
-	url := fmt.Sprintf("%s", config.APICmpUrl)
+	url := config.Get(&config.APICmpUrl)
 	hdrs := make(map[string]string, 1)
 	hdrs["Content-Type"] = "application/json"
 	return util.SimpleRequest("POST", url, &job, nil, 200, hdrs)
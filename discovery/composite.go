@@ -0,0 +1,298 @@
+package adapters
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	j "github.com/keenfury/axenda/job"
+	"github.com/keenfury/axenda/util"
+)
+
+/*
+Composite merges the jobs of several discoveries, e.g. legacy jobs in a file and new ones in a db.
+
+Each source has a name and the tokens it hands out are namespaced with it: "file:TOKEN1", so two sources can use
+the same token.  StartJob and CompleteJob are routed back to the source owning the job with the token it knows.
+A source failing GetJobs doesn't stop the others, all the errors are returned together.
+The JobStore functions are routed by the namespace too, adding a job needs the token with the source e.g. "db:TOKEN1".
+The jobs a job refers to (depends_on and the hook targets other than urls) are namespaced the same way, a job can
+only refer to the jobs of its own source.
+*/
+
+const CompositeSeparator = ":"
+
+type (
+	// Discovery: what a source of the Composite has to do, same as the DiscoveryAdapter in main.go
+	Discovery interface {
+		WhichDiscovery() string
+		GetJobs(time.Time) ([]j.Job, error)
+		StartJob(j.Job, chan<- j.Job) error
+		CompleteJob(j.Job, chan<- j.Job) error
+	}
+
+	Source struct {
+		Name      string
+		Discovery Discovery
+	}
+
+	Composite struct {
+		Sources []Source
+	}
+
+	jobStore interface {
+		ListJobs() ([]j.Job, error)
+		AddJob(j.Job) error
+		UpdateJob(j.Job) error
+		DeleteJob(string) error
+	}
+
+	pinger interface {
+		Ping() error
+	}
+
+	watcher interface {
+		Watch(chan<- struct{}) (func(), error)
+	}
+)
+
+// CompositeToken: the token namespaced by the source
+func CompositeToken(source, token string) string {
+	return source + CompositeSeparator + token
+}
+
+// SplitToken: the source and the token of the source from a namespaced token
+func SplitToken(token string) (source, sourceToken string) {
+	parts := strings.SplitN(token, CompositeSeparator, 2)
+	if len(parts) != 2 {
+		return "", token
+	}
+	return parts[0], parts[1]
+}
+
+func (c *Composite) WhichDiscovery() string {
+	names := []string{}
+	for _, s := range c.Sources {
+		names = append(names, fmt.Sprintf("%s (%s)", s.Name, s.Discovery.WhichDiscovery()))
+	}
+	return fmt.Sprintf("Composite: %s", strings.Join(names, ", "))
+}
+
+func (c *Composite) GetJobs(t time.Time) (jobs []j.Job, err error) {
+	errs := []string{}
+	for _, s := range c.Sources {
+		sourceJobs, errGet := s.Discovery.GetJobs(t)
+		if errGet != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", s.Name, errGet))
+		}
+		for _, job := range sourceJobs {
+			jobs = append(jobs, namespaceJob(s.Name, job))
+		}
+	}
+	if len(errs) > 0 {
+		err = fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return
+}
+
+func (c *Composite) StartJob(job j.Job, updateCh chan<- j.Job) error {
+	source, sourceJob, errSource := c.route(job)
+	if errSource != nil {
+		return errSource
+	}
+	return forward(source, updateCh, func(ch chan<- j.Job) error { return source.Discovery.StartJob(sourceJob, ch) })
+}
+
+func (c *Composite) CompleteJob(job j.Job, updateCh chan<- j.Job) error {
+	source, sourceJob, errSource := c.route(job)
+	if errSource != nil {
+		return errSource
+	}
+	return forward(source, updateCh, func(ch chan<- j.Job) error { return source.Discovery.CompleteJob(sourceJob, ch) })
+}
+
+// Ping: every source that can be pinged
+func (c *Composite) Ping() error {
+	for _, s := range c.Sources {
+		if p, ok := s.Discovery.(pinger); ok {
+			if errPing := p.Ping(); errPing != nil {
+				return fmt.Errorf("%s: %s", s.Name, errPing)
+			}
+		}
+	}
+	return nil
+}
+
+// Watch: watch every source that can be watched
+func (c *Composite) Watch(changeCh chan<- struct{}) (stop func(), err error) {
+	stops := []func(){}
+	stop = func() {
+		for _, s := range stops {
+			s()
+		}
+	}
+	for _, s := range c.Sources {
+		if w, ok := s.Discovery.(watcher); ok {
+			sourceStop, errWatch := w.Watch(changeCh)
+			if errWatch != nil {
+				stop()
+				err = fmt.Errorf("%s: %s", s.Name, errWatch)
+				return
+			}
+			stops = append(stops, sourceStop)
+		}
+	}
+	return
+}
+
+// Close: close every source that can be closed
+func (c *Composite) Close() (err error) {
+	for _, s := range c.Sources {
+		if closer, ok := s.Discovery.(io.Closer); ok {
+			if errClose := closer.Close(); errClose != nil && err == nil {
+				err = errClose
+			}
+		}
+	}
+	return
+}
+
+// ListJobs: the jobs of every source managing its jobs
+func (c *Composite) ListJobs() (jobs []j.Job, err error) {
+	for _, s := range c.Sources {
+		store, ok := s.Discovery.(jobStore)
+		if !ok {
+			continue
+		}
+		sourceJobs, errList := store.ListJobs()
+		if errList != nil {
+			err = fmt.Errorf("%s: %s", s.Name, errList)
+			return
+		}
+		for _, job := range sourceJobs {
+			jobs = append(jobs, namespaceJob(s.Name, job))
+		}
+	}
+	return
+}
+
+func (c *Composite) AddJob(job j.Job) error {
+	store, sourceJob, errStore := c.store(job)
+	if errStore != nil {
+		return errStore
+	}
+	return store.AddJob(sourceJob)
+}
+
+func (c *Composite) UpdateJob(job j.Job) error {
+	store, sourceJob, errStore := c.store(job)
+	if errStore != nil {
+		return errStore
+	}
+	return store.UpdateJob(sourceJob)
+}
+
+func (c *Composite) DeleteJob(token string) error {
+	store, sourceJob, errStore := c.store(j.Job{Token: token})
+	if errStore != nil {
+		return errStore
+	}
+	return store.DeleteJob(sourceJob.Token)
+}
+
+// route: the source owning the job and the job with the token of the source
+func (c *Composite) route(job j.Job) (source Source, sourceJob j.Job, err error) {
+	name, token := SplitToken(job.Token)
+	for _, s := range c.Sources {
+		if s.Name == name {
+			sourceJob = stripJob(s.Name, job)
+			sourceJob.Token = token
+			source = s
+			return
+		}
+	}
+	err = fmt.Errorf("No discovery source for token: %s", job.Token)
+	return
+}
+
+// SourceJob: the job as its source knows it, for the runs going to the runner without StartJob (manual, hooks...)
+func (c *Composite) SourceJob(job j.Job) (j.Job, error) {
+	_, sourceJob, errRoute := c.route(job)
+	return sourceJob, errRoute
+}
+
+func (c *Composite) store(job j.Job) (store jobStore, sourceJob j.Job, err error) {
+	source, sourceJob, errRoute := c.route(job)
+	if errRoute != nil {
+		err = errRoute
+		return
+	}
+	store, ok := source.Discovery.(jobStore)
+	if !ok {
+		err = fmt.Errorf("Discovery does not manage jobs: %s", source.Name)
+	}
+	return
+}
+
+// forward: call the source with its own update channel, the updates are sent on with the namespaced token
+func forward(source Source, updateCh chan<- j.Job, call func(chan<- j.Job) error) error {
+	sourceCh := make(chan j.Job)
+	done := make(chan struct{})
+	go func() {
+		for job := range sourceCh {
+			updateCh <- namespaceJob(source.Name, job)
+		}
+		close(done)
+	}()
+	err := call(sourceCh)
+	close(sourceCh)
+	<-done
+	return err
+}
+
+// namespaceJob: the job with its token and the tokens it refers to namespaced by the source
+func namespaceJob(source string, job j.Job) j.Job {
+	job.Token = CompositeToken(source, job.Token)
+	job.DependsOn = mapRefs(job.DependsOn, func(ref string) string { return CompositeToken(source, ref) })
+	namespaceTarget := func(ref string) string {
+		if util.IsHookURL(ref) {
+			return ref
+		}
+		return CompositeToken(source, ref)
+	}
+	job.OnSuccess = mapRefs(job.OnSuccess, namespaceTarget)
+	job.OnFailure = mapRefs(job.OnFailure, namespaceTarget)
+	job.Always = mapRefs(job.Always, namespaceTarget)
+	return job
+}
+
+// stripJob: the tokens the job refers to as the source knows them, the job's own token is left to route
+func stripJob(source string, job j.Job) j.Job {
+	strip := func(ref string) string {
+		if util.IsHookURL(ref) {
+			return ref
+		}
+		if name, token := SplitToken(ref); name == source {
+			return token
+		}
+		return ref
+	}
+	job.DependsOn = mapRefs(job.DependsOn, strip)
+	job.OnSuccess = mapRefs(job.OnSuccess, strip)
+	job.OnFailure = mapRefs(job.OnFailure, strip)
+	job.Always = mapRefs(job.Always, strip)
+	return job
+}
+
+// mapRefs: a new slice, the source's job is never changed in place
+func mapRefs(refs []string, fn func(string) string) []string {
+	if refs == nil {
+		return nil
+	}
+	mapped := make([]string, len(refs))
+	for i, ref := range refs {
+		mapped[i] = fn(ref)
+	}
+	return mapped
+}
@@ -0,0 +1,107 @@
+package adapters
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	j "github.com/keenfury/axenda/job"
+	r "github.com/keenfury/axenda/runner"
+	wf "github.com/keenfury/axenda/workflow"
+	"github.com/stretchr/testify/assert"
+)
+
+func compositeFile(fileName string) *Composite {
+	content := []byte(`[{"token":"TOKENFILE","active":true,"run_time":"2020-01-01T00:00:00-06:00","frequency":2}]`)
+	ioutil.WriteFile(fileName, content, 0644)
+	runner := &r.Mock{}
+	return &Composite{Sources: []Source{
+		{Name: "file", Discovery: &File{FileName: fileName, Runner: runner}},
+		{Name: "mock", Discovery: &Mock{Runner: runner}},
+	}}
+}
+
+func TestCompositeGetJobsSuccess(t *testing.T) {
+	fileName := "/tmp/composite_test_get"
+	composite := compositeFile(fileName)
+	defer os.Remove(fileName)
+	jobs, err := composite.GetJobs(time.Now())
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 2, len(jobs), "Expected a job from each source")
+	assert.Equal(t, "file:TOKENFILE", jobs[0].Token)
+	assert.Equal(t, "mock:MOCKTOKEN", jobs[1].Token)
+}
+
+func TestCompositeGetJobsPartialFailure(t *testing.T) {
+	composite := Composite{Sources: []Source{
+		{Name: "file", Discovery: &File{FileName: "/tmp/composite_test_missing"}},
+		{Name: "mock", Discovery: &Mock{}},
+	}}
+	jobs, err := composite.GetJobs(time.Now())
+	assert.NotNil(t, err, "Expected the error of the file source")
+	assert.Equal(t, 1, len(jobs), "Expected the jobs of the other source")
+}
+
+func TestCompositeStartCompleteJobSuccess(t *testing.T) {
+	fileName := "/tmp/composite_test_route"
+	composite := compositeFile(fileName)
+	defer os.Remove(fileName)
+	tm, _ := time.Parse(time.RFC3339, "2020-01-01T00:00:00-06:00")
+	job := j.Job{Token: "file:TOKENFILE", RunTime: tm, Frequency: 2}
+	ch := make(chan j.Job)
+	errCh := make(chan error, 2)
+	go func() {
+		errCh <- composite.StartJob(job, ch)
+		errCh <- composite.CompleteJob(job, ch)
+	}()
+	update := <-ch
+	assert.Equal(t, "file:TOKENFILE", update.Token, "Expected the update with the namespaced token")
+	assert.Equal(t, j.StatusInProcess, update.Status)
+	assert.Nil(t, <-errCh)
+	update = <-ch
+	assert.Equal(t, j.StatusDone, update.Status)
+	assert.Nil(t, <-errCh)
+	jobs, _ := composite.Sources[0].Discovery.(*File).ListJobs()
+	assert.True(t, jobs[0].RunTime.After(tm), "Expected the file source to move the run time")
+}
+
+func TestCompositeRouteFailure(t *testing.T) {
+	composite := Composite{Sources: []Source{{Name: "mock", Discovery: &Mock{}}}}
+	err := composite.StartJob(j.Job{Token: "db:TOKEN1"}, make(chan j.Job))
+	assert.NotNil(t, err)
+	assert.Equal(t, "No discovery source for token: db:TOKEN1", err.Error())
+	assert.NotNil(t, composite.AddJob(j.Job{Token: "mock:TOKEN1"}), "Expected the mock not to manage jobs")
+}
+
+func TestCompositeJobStoreSuccess(t *testing.T) {
+	fileName := "/tmp/composite_test_store"
+	composite := compositeFile(fileName)
+	defer os.Remove(fileName)
+	assert.Nil(t, composite.AddJob(j.Job{Token: "file:TOKENNEW", Active: true}))
+	jobs, err := composite.ListJobs()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(jobs))
+	assert.Equal(t, "file:TOKENNEW", jobs[1].Token)
+	assert.Nil(t, composite.DeleteJob("file:TOKENNEW"))
+}
+
+func TestCompositeWorkflowRefs(t *testing.T) {
+	fileName := "/tmp/composite_test_refs"
+	ioutil.WriteFile(fileName, []byte(`[{"token":"EXTRACT","active":true,"on_failure":["CLEANUP","http://localhost/alert"]},
+		{"token":"LOAD","active":true,"depends_on":["EXTRACT"]},
+		{"token":"CLEANUP","active":true}]`), 0644)
+	defer os.Remove(fileName)
+	composite := &Composite{Sources: []Source{{Name: "file", Discovery: &File{FileName: fileName, Runner: &r.Mock{}}}}}
+	jobs, err := composite.ListJobs()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"file:CLEANUP", "http://localhost/alert"}, jobs[0].OnFailure, "Expected the urls left as is")
+	assert.Equal(t, []string{"file:EXTRACT"}, jobs[1].DependsOn)
+	graph, errGraph := wf.NewGraph(jobs)
+	assert.Nil(t, errGraph, "Expected the dependencies to resolve within the composite")
+	assert.True(t, graph.IsRoot("file:EXTRACT"))
+
+	assert.Nil(t, composite.AddJob(j.Job{Token: "file:REPORT", Active: true, DependsOn: []string{"file:LOAD"}}))
+	stored, _ := composite.Sources[0].Discovery.(*File).ListJobs()
+	assert.Equal(t, []string{"LOAD"}, stored[3].DependsOn, "Expected the source to keep its own tokens")
+}
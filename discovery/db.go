@@ -1,15 +1,19 @@
 package adapters
 
 import (
+	"database/sql"
+	"encoding/json"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/keenfury/axenda/clock"
 	"github.com/keenfury/axenda/config"
 	f "github.com/keenfury/axenda/frequency"
 	j "github.com/keenfury/axenda/job"
+	"github.com/keenfury/axenda/metrics"
 	r "github.com/keenfury/axenda/runner"
+	"github.com/keenfury/axenda/util"
 	_ "github.com/lib/pq"
 )
 
@@ -33,30 +37,42 @@ type (
 	DB struct {
 		DB     *sqlx.DB
 		Runner r.RunnerAdapter
+		Clock  clock.Clock
 	}
 )
 
 func (d *DB) Connect() (err error) {
-	connectionStr := fmt.Sprintf("user=%s password=%s dbname=%s host=%s sslmode=disable", config.DBUser, config.DBPwd, config.DBDB, config.DBHost)
+	connectionStr := fmt.Sprintf("user=%s password=%s dbname=%s host=%s sslmode=%s", config.DBUser, config.DBPwd, config.DBDB, config.DBHost, config.GetDBSSLMode())
 
 	d.DB, err = sqlx.Connect("postgres", connectionStr)
 	if err != nil {
-		log.Panicln("Could not connect with connection string:", connectionStr) // TODO: log and determine if we panic
+		err = fmt.Errorf("Could not connect to db: %s on host: %s: %s", config.DBDB, config.DBHost, err)
 	}
 	return
 }
 
+// Close: close the connection to the DB
+func (d *DB) Close() error {
+	return d.DB.Close()
+}
+
+// Ping: check the connection to the DB
+func (d *DB) Ping() error {
+	return d.DB.Ping()
+}
+
 func (d *DB) WhichDiscovery() string {
 	return fmt.Sprintf("DB with runner: %s", d.Runner.WhichRunner())
 }
 
 func (d *DB) GetJobs(t time.Time) (jobs []j.Job, err error) {
+	defer func() { metrics.ObserveDiscovery("DB", len(jobs), err) }()
 	if t.IsZero() {
 		err = fmt.Errorf("Zero time")
 		return
 	}
 	sqlSelect := "select token, run_time, url_path, frequency from schedule where run_time < $1 and active = true"
-	runTimeEnd := t.Add(3 * time.Minute)
+	runTimeEnd := t.Add(config.GetLookahead())
 	jobsDB := []j.Job{}
 	errSelect := d.DB.Select(&jobsDB, sqlSelect, runTimeEnd)
 	if errSelect != nil {
@@ -68,15 +84,15 @@ func (d *DB) GetJobs(t time.Time) (jobs []j.Job, err error) {
 }
 
 func (d *DB) StartJob(job j.Job, updateCh chan<- j.Job) error {
-	job.Status = "In Process"
+	job.Status = j.StatusInProcess
 	updateCh <- job
 	return d.Runner.RunJob(&job)
 }
 
 func (d *DB) CompleteJob(job j.Job, updateCh chan<- j.Job) (err error) {
-	job.Status = "Done"
+	job.Status = j.StatusDone
 	updateCh <- job
-	errUpdate := f.Update(&job)
+	errUpdate := f.UpdateAt(&job, util.NowFrom(d.Clock))
 	if errUpdate != nil {
 		return errUpdate
 	}
@@ -88,6 +104,59 @@ func (d *DB) CompleteJob(job j.Job, updateCh chan<- j.Job) (err error) {
 	return
 }
 
+// ListJobs: all the jobs in the schedule table, active or not
+func (d *DB) ListJobs() (jobs []j.Job, err error) {
+	sqlSelect := "select token, coalesce(job_name, '') as job_name, run_time, url_path, frequency, coalesce(payload, 'null') as payload, active from schedule order by run_time"
+	jobs = []j.Job{}
+	err = d.DB.Select(&jobs, sqlSelect)
+	return
+}
+
+func (d *DB) AddJob(job j.Job) error {
+	var count int
+	if errGet := d.DB.Get(&count, "select count(*) from schedule where token = $1", job.Token); errGet != nil {
+		return errGet
+	}
+	if count > 0 {
+		return ErrJobExists
+	}
+	sqlInsert := "insert into schedule (token, job_name, run_time, url_path, frequency, payload, active) values ($1, $2, $3, $4, $5, $6, $7)"
+	_, errExec := d.DB.Exec(sqlInsert, job.Token, job.JobName, job.RunTime, job.UrlPath, job.Frequency, nullPayload(job.Payload), job.Active)
+	return errExec
+}
+
+func (d *DB) UpdateJob(job j.Job) error {
+	sqlUpdate := "update schedule set job_name = $1, run_time = $2, url_path = $3, frequency = $4, payload = $5, active = $6 where token = $7"
+	result, errExec := d.DB.Exec(sqlUpdate, job.JobName, job.RunTime, job.UrlPath, job.Frequency, nullPayload(job.Payload), job.Active, job.Token)
+	return checkAffected(result, errExec)
+}
+
+func (d *DB) DeleteJob(token string) error {
+	result, errExec := d.DB.Exec("delete from schedule where token = $1", token)
+	return checkAffected(result, errExec)
+}
+
+func checkAffected(result sql.Result, errExec error) error {
+	if errExec != nil {
+		return errExec
+	}
+	affected, errAffected := result.RowsAffected()
+	if errAffected != nil {
+		return errAffected
+	}
+	if affected == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+func nullPayload(payload json.RawMessage) interface{} {
+	if len(payload) == 0 {
+		return nil
+	}
+	return []byte(payload)
+}
+
 /*
 This table syntax will help you set a table for this adapter to be used correctly (though you can change what you want, you will need to change the
 struct in job.go)
@@ -107,3 +107,43 @@ func TestDBWhich(t *testing.T) {
 	msg := db.WhichDiscovery()
 	assert.Equal(t, "DB with runner: Mock", msg)
 }
+
+func TestDBAddJobSuccess(t *testing.T) {
+	mockDB, mock, _ := sqlmock.New()
+	defer mockDB.Close()
+	mock.ExpectQuery("select count").WillReturnRows(mock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("insert into schedule").WillReturnResult(sqlmock.NewResult(1, 1))
+	db := DB{DB: sqlx.NewDb(mockDB, "sqlmock")}
+	err := db.AddJob(j.Job{Token: "TOKENDB", Frequency: 4})
+	assert.Nil(t, err, "No error expected")
+}
+
+func TestDBAddJobExistsFailure(t *testing.T) {
+	mockDB, mock, _ := sqlmock.New()
+	defer mockDB.Close()
+	mock.ExpectQuery("select count").WillReturnRows(mock.NewRows([]string{"count"}).AddRow(1))
+	db := DB{DB: sqlx.NewDb(mockDB, "sqlmock")}
+	err := db.AddJob(j.Job{Token: "TOKENDB", Frequency: 4})
+	assert.Equal(t, ErrJobExists, err)
+}
+
+func TestDBDeleteJobNotFoundFailure(t *testing.T) {
+	mockDB, mock, _ := sqlmock.New()
+	defer mockDB.Close()
+	mock.ExpectExec("delete from schedule").WillReturnResult(sqlmock.NewResult(0, 0))
+	db := DB{DB: sqlx.NewDb(mockDB, "sqlmock")}
+	err := db.DeleteJob("TOKENDB")
+	assert.Equal(t, ErrJobNotFound, err)
+}
+
+func TestDBListJobsSuccess(t *testing.T) {
+	mockDB, mock, _ := sqlmock.New()
+	defer mockDB.Close()
+	tm, _ := time.Parse(time.RFC3339, "2020-01-01T00:00:00-06:00")
+	rows := mock.NewRows([]string{"token", "job_name", "run_time", "url_path", "frequency", "payload", "active"}).AddRow("TOKENDB", "Job", tm, "", 4, []byte(`null`), false)
+	mock.ExpectQuery("select (.+) from schedule order by").WillReturnRows(rows)
+	db := DB{DB: sqlx.NewDb(mockDB, "sqlmock")}
+	jobs, err := db.ListJobs()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 1, len(jobs), "Expected jobs count to be 1")
+}
@@ -1,37 +1,90 @@
 package adapters
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
+	"github.com/keenfury/axenda/clock"
+	"github.com/keenfury/axenda/config"
 	fr "github.com/keenfury/axenda/frequency"
 	j "github.com/keenfury/axenda/job"
+	"github.com/keenfury/axenda/metrics"
 	r "github.com/keenfury/axenda/runner"
+	"github.com/keenfury/axenda/util"
 )
 
+/*
+SCH_JOB_FILE_NAME is a job file or a directory of job files merged into one set of jobs, the format of each file is
+chosen by its extension (see file_format.go), a token has to be unique across the files.
+
+Next run times: a single json file is updated in place as before.  For a directory, yaml or toml the next run times
+are kept in a separate state file (SCH_JOB_STATE_FILE, defaults to the file/directory name + ".state.json", next to it) so the job
+definitions, e.g. under version control, are not rewritten on every run.  Changing a job's run_time in its
+definition wins over the state.  Adding, updating and deleting jobs (CLI, admin api) still writes the definitions, a
+new job in a directory gets its own file named after its token.
+
+Files are written atomically (temp file, fsync and rename), json is pretty printed with the indent already used in
+the file.  Edits made to the files while the scheduler updates them are not overwritten: the files are read again and
+the update applied on top of the edit.  Watch tells the scheduler right away when a file is edited.
+*/
+
+// how many times an update is applied again when the files keep changing under it
+const fileRetries = 3
+
 type (
 	File struct {
 		FileName string
-		Runner   r.RunnerAdapter
+		// StateFileName: optional, see StateFile
+		StateFileName string
+		Runner        r.RunnerAdapter
+		Clock         clock.Clock
+
+		// written: the content last written by file name (nil once deleted), so Watch can skip the scheduler's own writes
+		written map[string][]byte
+	}
+
+	// jobFile: one job file as read
+	jobFile struct {
+		name    string
+		format  string
+		single  bool
+		content []byte
+		jobs    []j.Job
+	}
+
+	// jobState: next run time of a job, valid while its definition still has the run time it was moved from
+	jobState struct {
+		RunTime time.Time `json:"run_time"`
+		Defined time.Time `json:"defined_run_time"`
 	}
 )
 
-var FileRead = sync.Mutex{}
+var (
+	FileRead = sync.Mutex{}
+	// ErrFileChanged: the file was edited every time an update was about to be written
+	ErrFileChanged = errors.New("Job file changed while updating it")
+)
 
 func (f *File) WhichDiscovery() string {
 	return fmt.Sprintf("File with runner: %s", f.Runner.WhichRunner())
 }
 
 func (f *File) GetJobs(t time.Time) (jobs []j.Job, err error) {
+	defer func() { metrics.ObserveDiscovery("File", len(jobs), err) }()
 	if t.IsZero() {
 		err = fmt.Errorf("Zero time")
 		return
 	}
-	// fmt.Println(t)
-	newRunTime := t.Add(3 * time.Minute)
+	newRunTime := t.Add(config.GetLookahead())
 	jobsFile, errFile := f.OpenFile()
 	if errFile != nil {
 		err = errFile
@@ -46,52 +99,449 @@ func (f *File) GetJobs(t time.Time) (jobs []j.Job, err error) {
 }
 
 func (f *File) StartJob(job j.Job, updateCh chan<- j.Job) (err error) {
-	job.Status = "In Process"
+	job.Status = j.StatusInProcess
 	updateCh <- job
 	return f.Runner.RunJob(&job)
 }
 
 func (f *File) CompleteJob(job j.Job, updateCh chan<- j.Job) error {
-	job.Status = "Done"
+	job.Status = j.StatusDone
 	updateCh <- job
 	FileRead.Lock()
 	defer FileRead.Unlock()
-	jobs, errFile := f.OpenFile()
-	if errFile != nil {
-		return errFile
-	}
-	for i, j := range jobs {
-		if j.Token == job.Token {
-			// update job frequency
-			errUpdate := fr.Update(&jobs[i])
-			if errUpdate != nil {
-				return errUpdate
+	if stateFile := f.StateFile(); len(stateFile) > 0 {
+		return f.completeState(job, stateFile)
+	}
+	return f.modify(func(jobs []j.Job) ([]j.Job, error) {
+		for i := range jobs {
+			if jobs[i].Token == job.Token {
+				// update job frequency
+				if errUpdate := fr.UpdateAt(&jobs[i], util.NowFrom(f.Clock)); errUpdate != nil {
+					return nil, errUpdate
+				}
+			}
+		}
+		return jobs, nil
+	})
+}
+
+// ListJobs: all the jobs, active or not
+func (f *File) ListJobs() ([]j.Job, error) {
+	FileRead.Lock()
+	defer FileRead.Unlock()
+	return f.OpenFile()
+}
+
+func (f *File) AddJob(job j.Job) error {
+	FileRead.Lock()
+	defer FileRead.Unlock()
+	return f.modify(func(jobs []j.Job) ([]j.Job, error) {
+		for _, js := range jobs {
+			if js.Token == job.Token {
+				return nil, ErrJobExists
 			}
 		}
+		return append(jobs, job), nil
+	})
+}
+
+func (f *File) UpdateJob(job j.Job) error {
+	FileRead.Lock()
+	defer FileRead.Unlock()
+	state, errState := f.readState()
+	if errState != nil {
+		return errState
 	}
-	bJobs, errM := json.Marshal(jobs)
-	if errM != nil {
-		return errM
+	return f.modify(func(jobs []j.Job) ([]j.Job, error) {
+		for i := range jobs {
+			if jobs[i].Token == job.Token {
+				// the run time came from the state, keep the definition's
+				if s, ok := state[job.Token]; ok && s.Defined.Equal(jobs[i].RunTime) && s.RunTime.Equal(job.RunTime) {
+					job.RunTime = jobs[i].RunTime
+				}
+				jobs[i] = job
+				return jobs, nil
+			}
+		}
+		return nil, ErrJobNotFound
+	})
+}
+
+func (f *File) DeleteJob(token string) error {
+	FileRead.Lock()
+	defer FileRead.Unlock()
+	return f.modify(func(jobs []j.Job) ([]j.Job, error) {
+		for i := range jobs {
+			if jobs[i].Token == token {
+				return append(jobs[:i], jobs[i+1:]...), nil
+			}
+		}
+		return nil, ErrJobNotFound
+	})
+}
+
+// SaveFile: write out all the job definitions, callers hold FileRead
+func (f *File) SaveFile(jobs []j.Job) error {
+	files, errRead := f.readDefinitions()
+	if errRead != nil && !os.IsNotExist(errRead) {
+		return errRead
+	}
+	if len(files) == 0 && !f.isDir() {
+		files = []jobFile{{name: f.FileName, format: FileFormat(f.FileName)}}
+	}
+	return f.writeDefinitions(files, jobs)
+}
+
+// Ping: check the file (or directory) is there and readable
+func (f *File) Ping() error {
+	file, errOpen := os.Open(f.FileName)
+	if errOpen != nil {
+		return errOpen
 	}
-	err := ioutil.WriteFile(f.FileName, bJobs, 0644)
-	return err
+	return file.Close()
 }
 
+// OpenFile: all the jobs of the file(s) with their next run time from the state file
 func (f *File) OpenFile() (jobs []j.Job, err error) {
-	bContent, errRead := ioutil.ReadFile(f.FileName)
+	files, errRead := f.readDefinitions()
 	if errRead != nil {
 		err = errRead
 		return
 	}
-	if err = json.Unmarshal(bContent, &jobs); err != nil {
+	if jobs, err = flatten(files); err != nil {
+		return
+	}
+	state, errState := f.readState()
+	if errState != nil {
+		err = errState
+		return
+	}
+	for i := range jobs {
+		if s, ok := state[jobs[i].Token]; ok && s.Defined.Equal(jobs[i].RunTime) {
+			jobs[i].RunTime = s.RunTime
+		}
+	}
+	return
+}
+
+// StateFile: where the next run times are kept, empty when they are written in the job file (a single json file)
+func (f *File) StateFile() string {
+	if len(f.StateFileName) > 0 {
+		return f.StateFileName
+	}
+	if f.isDir() || FileFormat(f.FileName) != FormatJSON {
+		return filepath.Clean(f.FileName) + ".state.json"
+	}
+	return ""
+}
+
+// Watch: signal on changeCh when a job file is edited, the scheduler's own writes are skipped, stop ends the watch
+func (f *File) Watch(changeCh chan<- struct{}) (stop func(), err error) {
+	watcher, errWatcher := fsnotify.NewWatcher()
+	if errWatcher != nil {
+		err = errWatcher
+		return
+	}
+	fileName := filepath.Clean(f.FileName)
+	isDir := f.isDir()
+	watched := fileName
+	if !isDir {
+		// watch the directory, editors replace the file instead of writing it
+		watched = filepath.Dir(fileName)
+	}
+	if err = watcher.Add(watched); err != nil {
+		watcher.Close()
 		return
 	}
+	go func() {
+		for event := range watcher.Events {
+			name := filepath.Clean(event.Name)
+			if (isDir && !IsJobFile(name)) || (!isDir && name != fileName) {
+				continue
+			}
+			content, errRead := ioutil.ReadFile(name)
+			FileRead.Lock()
+			written, known := f.written[name]
+			FileRead.Unlock()
+			if known && ((errRead == nil && bytes.Equal(content, written)) || (os.IsNotExist(errRead) && written == nil)) {
+				continue
+			}
+			select {
+			case changeCh <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	stop = func() { watcher.Close() }
 	return
 }
 
+// modify: read the job definitions, change them and write them back unless a file was edited in between, each file
+// is compared to what was read right before it is replaced, an outside edit is read again and the change applied on
+// top of it (optimistic concurrency), callers hold FileRead
+func (f *File) modify(change func([]j.Job) ([]j.Job, error)) error {
+	for i := 0; i < fileRetries; i++ {
+		files, errRead := f.readDefinitions()
+		if errRead != nil {
+			return errRead
+		}
+		jobs, errFlatten := flatten(files)
+		if errFlatten != nil {
+			return errFlatten
+		}
+		changed, errChange := change(jobs)
+		if errChange != nil {
+			return errChange
+		}
+		errWrite := f.writeDefinitions(files, changed)
+		if errWrite == ErrFileChanged {
+			continue
+		}
+		return errWrite
+	}
+	return ErrFileChanged
+}
+
+// completeState: move the job along with its frequency in the state file, callers hold FileRead
+func (f *File) completeState(job j.Job, stateFile string) error {
+	files, errRead := f.readDefinitions()
+	if errRead != nil {
+		return errRead
+	}
+	jobs, errFlatten := flatten(files)
+	if errFlatten != nil {
+		return errFlatten
+	}
+	state, errState := f.readState()
+	if errState != nil {
+		return errState
+	}
+	defined := map[string]bool{}
+	for _, def := range jobs {
+		defined[def.Token] = true
+		if def.Token != job.Token {
+			continue
+		}
+		next := def
+		if s, ok := state[def.Token]; ok && s.Defined.Equal(def.RunTime) {
+			next.RunTime = s.RunTime
+		}
+		if errUpdate := fr.UpdateAt(&next, util.NowFrom(f.Clock)); errUpdate != nil {
+			return errUpdate
+		}
+		state[def.Token] = jobState{RunTime: next.RunTime, Defined: def.RunTime}
+	}
+	// forget the jobs no longer defined
+	for token := range state {
+		if !defined[token] {
+			delete(state, token)
+		}
+	}
+	bState, errM := json.MarshalIndent(state, "", "\t")
+	if errM != nil {
+		return errM
+	}
+	return util.WriteFileAtomic(stateFile, append(bState, '\n'), 0644)
+}
+
+func (f *File) readState() (state map[string]jobState, err error) {
+	state = map[string]jobState{}
+	stateFile := f.StateFile()
+	if len(stateFile) == 0 {
+		return
+	}
+	bContent, errRead := ioutil.ReadFile(stateFile)
+	if errRead != nil {
+		if !os.IsNotExist(errRead) {
+			err = errRead
+		}
+		return
+	}
+	err = json.Unmarshal(bContent, &state)
+	return
+}
+
+func (f *File) isDir() bool {
+	info, errStat := os.Stat(f.FileName)
+	return errStat == nil && info.IsDir()
+}
+
+// readDefinitions: the job file, or every job file of the directory sorted by name, the state file is skipped when
+// it is in the directory
+func (f *File) readDefinitions() (files []jobFile, err error) {
+	names := []string{f.FileName}
+	isDir := f.isDir()
+	if isDir {
+		entries, errDir := ioutil.ReadDir(f.FileName)
+		if errDir != nil {
+			err = errDir
+			return
+		}
+		stateFile, _ := filepath.Abs(f.StateFile())
+		names = []string{}
+		for _, entry := range entries {
+			name := filepath.Join(f.FileName, entry.Name())
+			if abs, _ := filepath.Abs(name); entry.IsDir() || !IsJobFile(entry.Name()) || abs == stateFile {
+				continue
+			}
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		content, errRead := ioutil.ReadFile(name)
+		if errRead != nil {
+			err = errRead
+			return
+		}
+		file := jobFile{name: filepath.Clean(name), format: FileFormat(name), content: content}
+		if file.jobs, file.single, err = DecodeJobs(content, file.format); err != nil {
+			if isDir {
+				// which file of the directory
+				err = fmt.Errorf("%s: %s", name, err)
+			}
+			return
+		}
+		files = append(files, file)
+	}
+	return
+}
+
+// writeDefinitions: put the jobs back in the files defining them and write the files that changed, a new job goes
+// to the file, or to its own file in a directory, callers hold FileRead
+func (f *File) writeDefinitions(files []jobFile, jobs []j.Job) error {
+	byToken := make(map[string]j.Job, len(jobs))
+	for _, job := range jobs {
+		byToken[job.Token] = job
+	}
+	isDir := f.isDir()
+	placed := map[string]bool{}
+	for _, file := range files {
+		kept := []j.Job{}
+		for _, old := range file.jobs {
+			if job, ok := byToken[old.Token]; ok {
+				kept = append(kept, job)
+				placed[job.Token] = true
+			}
+		}
+		if !isDir {
+			for _, job := range jobs {
+				if !placed[job.Token] {
+					kept = append(kept, job)
+					placed[job.Token] = true
+				}
+			}
+		}
+		if sameJobs(file.jobs, kept) && len(file.content) > 0 {
+			continue
+		}
+		if isDir && len(kept) == 0 {
+			if errCheck := unchanged(file.name, file.content)(); errCheck != nil {
+				return errCheck
+			}
+			if errRemove := os.Remove(file.name); errRemove != nil {
+				return errRemove
+			}
+			f.setWritten(file.name, nil)
+			continue
+		}
+		if errWrite := f.writeJobFile(file.name, kept, file.single, file.format, file.content); errWrite != nil {
+			return errWrite
+		}
+	}
+	if !isDir {
+		return nil
+	}
+	ext := ".json"
+	if len(files) > 0 {
+		ext = filepath.Ext(files[0].name)
+	}
+	for _, job := range jobs {
+		if placed[job.Token] {
+			continue
+		}
+		name := filepath.Join(filepath.Clean(f.FileName), fileSafe(job.Token)+ext)
+		if _, errStat := os.Stat(name); errStat == nil {
+			return fmt.Errorf("Job file already exists: %s", name)
+		}
+		if errWrite := f.writeJobFile(name, []j.Job{job}, true, FileFormat(name), nil); errWrite != nil {
+			return errWrite
+		}
+	}
+	return nil
+}
+
+func (f *File) writeJobFile(name string, jobs []j.Job, single bool, format string, like []byte) error {
+	content, errEncode := EncodeJobs(jobs, single, format, like)
+	if errEncode != nil {
+		return errEncode
+	}
+	if errWrite := util.WriteFileAtomicCheck(name, content, 0644, unchanged(name, like)); errWrite != nil {
+		return errWrite
+	}
+	f.setWritten(name, content)
+	return nil
+}
+
+// beforeReplace: called as a file is checked before it is replaced, tests edit the file there
+var beforeReplace = func(name string) {}
+
+// unchanged: a check the file on disk still holds what was read (nothing for a new file), ErrFileChanged otherwise
+func unchanged(name string, content []byte) func() error {
+	return func() error {
+		beforeReplace(name)
+		current, errRead := ioutil.ReadFile(name)
+		if errRead != nil && !os.IsNotExist(errRead) {
+			return errRead
+		}
+		if !bytes.Equal(current, content) {
+			return ErrFileChanged
+		}
+		return nil
+	}
+}
+
+func (f *File) setWritten(name string, content []byte) {
+	if f.written == nil {
+		f.written = map[string][]byte{}
+	}
+	f.written[filepath.Clean(name)] = content
+}
+
+// flatten: the jobs of all the files, a token has to be unique
+func flatten(files []jobFile) (jobs []j.Job, err error) {
+	seen := map[string]string{}
+	for _, file := range files {
+		for _, job := range file.jobs {
+			if other, ok := seen[job.Token]; ok {
+				err = fmt.Errorf("Duplicate token %s in %s and %s", job.Token, other, file.name)
+				return
+			}
+			seen[job.Token] = file.name
+			jobs = append(jobs, job)
+		}
+	}
+	return
+}
+
+func sameJobs(a, b []j.Job) bool {
+	bA, errA := json.Marshal(a)
+	bB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && len(a) == len(b) && bytes.Equal(bA, bB)
+}
+
+// fileSafe: the token as a file name
+func fileSafe(token string) string {
+	return strings.Map(func(c rune) rune {
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' {
+			return c
+		}
+		return '_'
+	}, token)
+}
+
 /*
 
-Sample File content format
+Sample File content format (see file_format.go for yaml and toml)
 [
 	{
 		"token":"unique_id_like_uuid",
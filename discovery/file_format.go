@@ -0,0 +1,176 @@
+package adapters
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	j "github.com/keenfury/axenda/job"
+	"gopkg.in/yaml.v3"
+)
+
+/*
+Job file formats, chosen by the extension: .yaml/.yml, .toml and .json (any other extension is read as json).
+
+A file holds a list of jobs or a single job, e.g. one job per file in a directory:
+
+	# jobs/nightly.yaml
+	token: NIGHTLY
+	job_name: Nightly report
+	run_time: 2020-04-23T02:00:00-06:00
+	url_path: http://localhost:12572/run_job
+	frequency: 4
+	active: true
+	payload:
+	  report: daily
+
+toml has no top level list, use an array of tables for a list:
+
+	[[jobs]]
+	token = "NIGHTLY"
+	run_time = 2020-04-23T02:00:00-06:00
+
+yaml and toml go through json, the keys are the json ones of job.Job and the payload can be any structure.
+*/
+
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// FileFormat: the format of the job file by its extension
+func FileFormat(fileName string) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	}
+	return FormatJSON
+}
+
+// IsJobFile: the file has one of the known extensions, used to pick the files of a directory
+func IsJobFile(fileName string) bool {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".json", ".yaml", ".yml", ".toml":
+		return !strings.HasPrefix(filepath.Base(fileName), ".")
+	}
+	return false
+}
+
+// DecodeJobs: the jobs of the content, single is true when the content is one job instead of a list
+func DecodeJobs(content []byte, format string) (jobs []j.Job, single bool, err error) {
+	bJSON := content
+	if format != FormatJSON {
+		var value interface{}
+		switch format {
+		case FormatYAML:
+			err = yaml.Unmarshal(content, &value)
+		case FormatTOML:
+			tableValue := map[string]interface{}{}
+			_, err = toml.Decode(string(content), &tableValue)
+			value = tableValue
+			if list, ok := tableValue["jobs"]; ok && len(tableValue) == 1 {
+				value = list
+			}
+		}
+		if err != nil {
+			return
+		}
+		if bJSON, err = json.Marshal(value); err != nil {
+			return
+		}
+	}
+	trimmed := bytes.TrimSpace(bJSON)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return
+	}
+	if trimmed[0] == '{' {
+		job := j.Job{}
+		err = json.Unmarshal(trimmed, &job)
+		jobs = []j.Job{job}
+		single = true
+		return
+	}
+	err = json.Unmarshal(trimmed, &jobs)
+	return
+}
+
+// EncodeJobs: the jobs in the format, json is pretty printed with the indent of like (a tab if it has none)
+func EncodeJobs(jobs []j.Job, single bool, format string, like []byte) (content []byte, err error) {
+	if single && len(jobs) != 1 {
+		single = false
+	}
+	if jobs == nil {
+		jobs = []j.Job{}
+	}
+	var value interface{} = jobs
+	if single {
+		value = jobs[0]
+	}
+	if format == FormatJSON {
+		if content, err = json.MarshalIndent(value, "", fileIndent(like)); err == nil {
+			content = append(content, '\n')
+		}
+		return
+	}
+	// through json so the keys and the payload are the same in every format
+	bJSON, errM := json.Marshal(value)
+	if errM != nil {
+		err = errM
+		return
+	}
+	var generic interface{}
+	if err = json.Unmarshal(bJSON, &generic); err != nil {
+		return
+	}
+	generic = dropNulls(generic)
+	switch format {
+	case FormatYAML:
+		return yaml.Marshal(generic)
+	case FormatTOML:
+		if !single {
+			generic = map[string]interface{}{"jobs": generic}
+		}
+		var buf bytes.Buffer
+		err = toml.NewEncoder(&buf).Encode(generic)
+		content = buf.Bytes()
+		return
+	}
+	err = fmt.Errorf("Unknown job file format: %s", format)
+	return
+}
+
+// dropNulls: toml has no null, an empty payload is left out of yaml too
+func dropNulls(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			if item == nil {
+				delete(v, k)
+				continue
+			}
+			v[k] = dropNulls(item)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = dropNulls(v[i])
+		}
+	}
+	return value
+}
+
+// fileIndent: the indent of the first indented line of content
+func fileIndent(content []byte) string {
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		trimmed := bytes.TrimLeft(line, " \t")
+		if len(trimmed) > 0 && len(trimmed) < len(line) {
+			return string(line[:len(line)-len(trimmed)])
+		}
+	}
+	return "\t"
+}
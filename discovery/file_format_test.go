@@ -0,0 +1,119 @@
+package adapters
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/keenfury/axenda/clock"
+	j "github.com/keenfury/axenda/job"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeJobsYAMLSuccess(t *testing.T) {
+	content := []byte("token: TOKENYAML\nrun_time: 2020-04-23T12:24:00-06:00\nfrequency: 4\nactive: true\npayload:\n  report: daily\n")
+	jobs, single, err := DecodeJobs(content, FormatYAML)
+	assert.Nil(t, err, "No error expected")
+	assert.True(t, single)
+	assert.Equal(t, "TOKENYAML", jobs[0].Token)
+	assert.Equal(t, 4, jobs[0].Frequency)
+	assert.JSONEq(t, `{"report":"daily"}`, string(jobs[0].Payload))
+}
+
+func TestDecodeJobsTOMLSuccess(t *testing.T) {
+	content := []byte("[[jobs]]\ntoken = \"TOKEN1\"\nrun_time = 2020-04-23T12:24:00-06:00\n\n[[jobs]]\ntoken = \"TOKEN2\"\nrun_time = 2020-04-23T12:25:00-06:00\n")
+	jobs, single, err := DecodeJobs(content, FormatTOML)
+	assert.Nil(t, err, "No error expected")
+	assert.False(t, single)
+	assert.Equal(t, 2, len(jobs))
+	expected, _ := time.Parse(time.RFC3339, "2020-04-23T12:25:00-06:00")
+	assert.True(t, expected.Equal(jobs[1].RunTime))
+}
+
+func TestEncodeJobsRoundTrip(t *testing.T) {
+	tm, _ := time.Parse(time.RFC3339, "2020-04-23T12:24:00-06:00")
+	jobs := []j.Job{{Token: "TOKEN1", RunTime: tm, Active: true, Payload: json.RawMessage(`{"a":1}`)}, {Token: "TOKEN2", RunTime: tm}}
+	for _, format := range []string{FormatJSON, FormatYAML, FormatTOML} {
+		content, err := EncodeJobs(jobs, false, format, nil)
+		assert.Nil(t, err, format)
+		decoded, single, errDecode := DecodeJobs(content, format)
+		assert.Nil(t, errDecode, format)
+		assert.False(t, single, format)
+		assert.Equal(t, 2, len(decoded), format)
+		assert.True(t, tm.Equal(decoded[1].RunTime), format)
+		assert.JSONEq(t, `{"a":1}`, string(decoded[0].Payload), format)
+	}
+}
+
+func TestFileDirectoryWithStateSuccess(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "file_test_dir")
+	defer os.RemoveAll(dir)
+	defer os.Remove(dir + ".state.json")
+	definition := []byte("token: TOKENDIR1\nrun_time: 2020-04-23T12:24:00-06:00\nfrequency: 4\nactive: true\n")
+	ioutil.WriteFile(dir+"/one.yaml", definition, 0644)
+	ioutil.WriteFile(dir+"/two.toml", []byte("token = \"TOKENDIR2\"\nrun_time = 2020-04-23T12:24:00-06:00\nactive = true\n"), 0644)
+	ioutil.WriteFile(dir+"/README.md", []byte("not a job"), 0644)
+	tm, _ := time.Parse(time.RFC3339, "2020-04-23T12:24:00-06:00")
+	file := File{FileName: dir, Clock: clock.NewFake(tm)}
+	jobs, err := file.ListJobs()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 2, len(jobs), "Expected the jobs of both files")
+
+	ch := make(chan j.Job, 1)
+	assert.Nil(t, file.CompleteJob(j.Job{Token: "TOKENDIR1", RunTime: tm}, ch))
+	bDefinition, _ := ioutil.ReadFile(dir + "/one.yaml")
+	assert.Equal(t, definition, bDefinition, "Expected the definition not to be rewritten")
+	jobs, _ = file.ListJobs()
+	expected, _ := time.Parse(time.RFC3339, "2020-04-24T12:24:00-06:00")
+	assert.True(t, expected.Equal(jobs[0].RunTime), "Expected the next run time from the state")
+
+	// changing the definition's run time wins over the state
+	ioutil.WriteFile(dir+"/one.yaml", []byte("token: TOKENDIR1\nrun_time: 2020-05-01T00:00:00-06:00\nfrequency: 4\nactive: true\n"), 0644)
+	jobs, _ = file.ListJobs()
+	expected, _ = time.Parse(time.RFC3339, "2020-05-01T00:00:00-06:00")
+	assert.True(t, expected.Equal(jobs[0].RunTime))
+}
+
+func TestFileDirectoryStateInsideSuccess(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "file_test_dir")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(dir+"/one.yaml", []byte("token: TOKENDIR1\nrun_time: 2020-04-23T12:24:00-06:00\nfrequency: 4\nactive: true\n"), 0644)
+	tm, _ := time.Parse(time.RFC3339, "2020-04-23T12:24:00-06:00")
+	file := File{FileName: dir, StateFileName: dir + "/state.json", Clock: clock.NewFake(tm)}
+	ch := make(chan j.Job, 1)
+	assert.Nil(t, file.CompleteJob(j.Job{Token: "TOKENDIR1", RunTime: tm}, ch))
+	_, errStat := os.Stat(dir + "/state.json")
+	assert.Nil(t, errStat, "Expected the state file in the job directory")
+	jobs, err := file.ListJobs()
+	assert.Nil(t, err, "Expected the state file not to be read as jobs")
+	assert.Equal(t, 1, len(jobs))
+}
+
+func TestFileDirectoryManageSuccess(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "file_test_dir")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(dir+"/one.yaml", []byte("token: TOKENDIR1\nactive: true\n"), 0644)
+	file := File{FileName: dir}
+	assert.Nil(t, file.AddJob(j.Job{Token: "NEW/TOKEN", Active: true}))
+	_, errStat := os.Stat(dir + "/NEW_TOKEN.yaml")
+	assert.Nil(t, errStat, "Expected the new job in its own file")
+	assert.Nil(t, file.UpdateJob(j.Job{Token: "TOKENDIR1"}))
+	jobs, _ := file.ListJobs()
+	assert.Equal(t, 2, len(jobs))
+	assert.False(t, jobs[1].Active, "Expected the job to be updated")
+	assert.Nil(t, file.DeleteJob("NEW/TOKEN"))
+	_, errStat = os.Stat(dir + "/NEW_TOKEN.yaml")
+	assert.True(t, os.IsNotExist(errStat), "Expected the file of the deleted job to be removed")
+}
+
+func TestFileDirectoryDuplicateFailure(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "file_test_dir")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(dir+"/one.yaml", []byte("token: TOKENDUP\n"), 0644)
+	ioutil.WriteFile(dir+"/two.json", []byte(`{"token":"TOKENDUP"}`), 0644)
+	file := File{FileName: dir}
+	_, err := file.ListJobs()
+	assert.NotNil(t, err, "Expected the duplicate token to fail")
+}
@@ -1,13 +1,17 @@
 package adapters
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	j "github.com/keenfury/axenda/job"
+	"github.com/keenfury/axenda/metrics"
 	r "github.com/keenfury/axenda/runner"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -30,6 +34,20 @@ func TestFileGetJobsMissingFileFailure(t *testing.T) {
 	assert.NotNil(t, err, "Error expected")
 }
 
+func TestFileGetJobsMetrics(t *testing.T) {
+	fileName := "/tmp/file_test_get_metrics"
+	ioutil.WriteFile(fileName, []byte(`[{"token":"TOKENFILE","active":true,"run_time":"2020-01-01T00:00:00-06:00","frequency":2}]`), 0644)
+	defer os.Remove(fileName)
+	total := testutil.ToFloat64(metrics.JobsDiscoveredTotal)
+	errors := testutil.ToFloat64(metrics.DiscoveryErrors.WithLabelValues("File"))
+	_, err := (&File{FileName: fileName}).GetJobs(time.Now())
+	assert.Nil(t, err, "No error expected")
+	_, err = (&File{FileName: "/tmp/file_test_get_metrics_missing"}).GetJobs(time.Now())
+	assert.NotNil(t, err, "Error expected")
+	assert.Equal(t, total+1, testutil.ToFloat64(metrics.JobsDiscoveredTotal))
+	assert.Equal(t, errors+1, testutil.ToFloat64(metrics.DiscoveryErrors.WithLabelValues("File")))
+}
+
 func TestFileGetJobsFailure(t *testing.T) {
 	file := File{}
 	timeNow := time.Time{}
@@ -131,3 +149,131 @@ func TestFileCompleteJobFrequencyFailure(t *testing.T) {
 	}(fileName)
 	<-ch
 }
+
+func TestFilePingSuccess(t *testing.T) {
+	fileName := "/tmp/file_test_ping"
+	ioutil.WriteFile(fileName, []byte(`[]`), 0644)
+	defer os.Remove(fileName)
+	file := File{FileName: fileName}
+	assert.Nil(t, file.Ping(), "No error expected")
+}
+
+func TestFilePingFailure(t *testing.T) {
+	file := File{FileName: "/tmp/file_test_ping_missing"}
+	assert.NotNil(t, file.Ping(), "Error expected")
+}
+
+func TestFileManageJobsSuccess(t *testing.T) {
+	fileName := "/tmp/file_test_manage"
+	ioutil.WriteFile(fileName, []byte(`[{"token":"TOKENFILE","active":true,"run_time":"2020-01-01T00:00:00-06:00","frequency":2}]`), 0644)
+	defer os.Remove(fileName)
+	file := File{FileName: fileName}
+	tm, _ := time.Parse(time.RFC3339, "2020-01-01T00:00:00-06:00")
+	assert.Nil(t, file.AddJob(j.Job{Token: "NEWTOKEN", RunTime: tm, Frequency: 4, Active: true}), "No error expected")
+	assert.Equal(t, ErrJobExists, file.AddJob(j.Job{Token: "NEWTOKEN"}))
+	assert.Nil(t, file.UpdateJob(j.Job{Token: "TOKENFILE", RunTime: tm, Frequency: 2}), "No error expected")
+	jobs, err := file.ListJobs()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 2, len(jobs), "Expected jobs count to be 2")
+	assert.Equal(t, false, jobs[0].Active, "Expected job to be updated")
+	assert.Nil(t, file.DeleteJob("TOKENFILE"), "No error expected")
+	assert.Equal(t, ErrJobNotFound, file.DeleteJob("TOKENFILE"))
+	assert.Equal(t, ErrJobNotFound, file.UpdateJob(j.Job{Token: "TOKENFILE"}))
+	jobs, _ = file.ListJobs()
+	assert.Equal(t, 1, len(jobs), "Expected jobs count to be 1")
+}
+
+func TestFileSaveKeepsIndent(t *testing.T) {
+	fileName := "/tmp/file_test_indent"
+	ioutil.WriteFile(fileName, []byte("[\n  {\n    \"token\": \"TOKENFILE\",\n    \"active\": true\n  }\n]\n"), 0644)
+	defer os.Remove(fileName)
+	file := File{FileName: fileName}
+	assert.Nil(t, file.AddJob(j.Job{Token: "NEWTOKEN"}), "No error expected")
+	bContent, _ := ioutil.ReadFile(fileName)
+	assert.Contains(t, string(bContent), "\n  {\n    \"token\": \"NEWTOKEN\",", "Expected the two space indent to be kept")
+	matches, _ := filepath.Glob("/tmp/.file_test_indent.tmp*")
+	assert.Equal(t, 0, len(matches), "Expected no temp file left")
+}
+
+func TestFileOutsideEditKept(t *testing.T) {
+	fileName := "/tmp/file_test_outside"
+	ioutil.WriteFile(fileName, []byte(`[{"token":"TOKENFILE","active":true}]`), 0644)
+	defer os.Remove(fileName)
+	file := File{FileName: fileName}
+	edited := false
+	errModify := file.modify(func(jobs []j.Job) ([]j.Job, error) {
+		if !edited {
+			// someone edits the file while the update is made
+			edited = true
+			ioutil.WriteFile(fileName, []byte(`[{"token":"TOKENFILE","active":true},{"token":"TOKENEDIT","active":true}]`), 0644)
+		}
+		return append(jobs, j.Job{Token: "NEWTOKEN"}), nil
+	})
+	assert.Nil(t, errModify, "No error expected")
+	jobs, _ := file.ListJobs()
+	assert.Equal(t, 3, len(jobs), "Expected the outside edit to be kept")
+	assert.Equal(t, "TOKENEDIT", jobs[1].Token)
+}
+
+func TestFileEditBeforeReplaceKept(t *testing.T) {
+	fileName := "/tmp/file_test_before_replace"
+	ioutil.WriteFile(fileName, []byte(`[{"token":"TOKENFILE","active":true}]`), 0644)
+	defer os.Remove(fileName)
+	edited := false
+	beforeReplace = func(name string) {
+		if !edited {
+			// someone edits the file after the update was made, just before it is written
+			edited = true
+			ioutil.WriteFile(name, []byte(`[{"token":"TOKENFILE","active":true},{"token":"TOKENEDIT","active":true}]`), 0644)
+		}
+	}
+	defer func() { beforeReplace = func(name string) {} }()
+	file := File{FileName: fileName}
+	assert.Nil(t, file.AddJob(j.Job{Token: "NEWTOKEN"}), "No error expected")
+	jobs, _ := file.ListJobs()
+	assert.Equal(t, 3, len(jobs), "Expected the outside edit to be kept")
+	assert.Equal(t, "TOKENEDIT", jobs[1].Token)
+	matches, _ := filepath.Glob("/tmp/.file_test_before_replace.tmp*")
+	assert.Equal(t, 0, len(matches), "Expected no temp file left")
+}
+
+func TestFileOutsideEditFailure(t *testing.T) {
+	fileName := "/tmp/file_test_outside_fail"
+	ioutil.WriteFile(fileName, []byte(`[]`), 0644)
+	defer os.Remove(fileName)
+	file := File{FileName: fileName}
+	count := 0
+	errModify := file.modify(func(jobs []j.Job) ([]j.Job, error) {
+		count++
+		ioutil.WriteFile(fileName, []byte(fmt.Sprintf(`[{"token":"TOKEN%d"}]`, count)), 0644)
+		return append(jobs, j.Job{Token: "NEWTOKEN"}), nil
+	})
+	assert.Equal(t, ErrFileChanged, errModify)
+	assert.Equal(t, fileRetries, count)
+}
+
+func TestFileWatchSuccess(t *testing.T) {
+	fileName := "/tmp/file_test_watch"
+	ioutil.WriteFile(fileName, []byte(`[]`), 0644)
+	defer os.Remove(fileName)
+	file := File{FileName: fileName}
+	changeCh := make(chan struct{}, 1)
+	stop, err := file.Watch(changeCh)
+	assert.Nil(t, err, "No error expected")
+	defer stop()
+
+	// the scheduler's own write
+	assert.Nil(t, file.AddJob(j.Job{Token: "NEWTOKEN"}))
+	select {
+	case <-changeCh:
+		t.Error("Own write should not be signaled")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	ioutil.WriteFile(fileName, []byte(`[{"token":"TOKENEDIT"}]`), 0644)
+	select {
+	case <-changeCh:
+	case <-time.After(2 * time.Second):
+		t.Error("Expected the edit to be signaled")
+	}
+}
@@ -5,10 +5,15 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/keenfury/axenda/clock"
+	"github.com/keenfury/axenda/config"
 	"github.com/keenfury/axenda/discovery/proto"
 	f "github.com/keenfury/axenda/frequency"
 	j "github.com/keenfury/axenda/job"
+	l "github.com/keenfury/axenda/logger"
+	"github.com/keenfury/axenda/metrics"
 	r "github.com/keenfury/axenda/runner"
+	"github.com/keenfury/axenda/util"
 	"google.golang.org/grpc"
 )
 
@@ -16,6 +21,7 @@ type (
 	GRPC struct {
 		URL    string
 		Runner r.RunnerAdapter
+		Clock  clock.Clock
 	}
 )
 
@@ -24,13 +30,18 @@ func (g *GRPC) WhichDiscovery() string {
 }
 
 func (g *GRPC) GetJobs(t time.Time) (jobs []j.Job, err error) {
-	newRunTime := t.Add(3 * time.Minute)
+	defer func() { metrics.ObserveDiscovery("GRPC", len(jobs), err) }()
+	newRunTime := t.Add(config.GetLookahead())
 	if t.IsZero() {
 		err = fmt.Errorf("Zero time")
 		return
 	}
 	jg := proto.JobGetRequest{Runtime: newRunTime.Format(time.RFC3339)}
-	opts := grpc.WithInsecure()
+	opts, errOpts := util.GRPCDialOption()
+	if errOpts != nil {
+		err = errOpts
+		return
+	}
 	srv, errDial := grpc.Dial(g.URL, opts)
 	if errDial != nil {
 		err = errDial
@@ -46,7 +57,7 @@ func (g *GRPC) GetJobs(t time.Time) (jobs []j.Job, err error) {
 	for _, r := range resp.Jobs {
 		timeParse, errParse := time.Parse(time.RFC3339, r.Runtime)
 		if errParse != nil {
-			fmt.Println("error in parsing time") // TODO: log
+			l.Warn("GRPC: job skipped, unable to parse its run time", "token", r.Token, "run_time", r.Runtime, "error", errParse)
 			continue
 		}
 		jobs = append(jobs, j.Job{Token: r.Token, RunTime: timeParse, Frequency: int(r.Frequency)})
@@ -55,22 +66,25 @@ func (g *GRPC) GetJobs(t time.Time) (jobs []j.Job, err error) {
 }
 
 func (g *GRPC) StartJob(job j.Job, updateCh chan<- j.Job) (err error) {
-	job.Status = "In Process"
+	job.Status = j.StatusInProcess
 	updateCh <- job
 	return g.Runner.RunJob(&job)
 }
 
 func (g *GRPC) CompleteJob(job j.Job, updateCh chan<- j.Job) error {
-	job.Status = "Done"
+	job.Status = j.StatusDone
 	updateCh <- job
-	errUpdate := f.Update(&job)
+	errUpdate := f.UpdateAt(&job, util.NowFrom(g.Clock))
 	if errUpdate != nil {
 		return errUpdate
 	}
 	// set up GRPC job struct
 	runTimeStr := job.RunTime.Format(time.RFC3339)
 	pj := proto.Job{Token: job.Token, Runtime: runTimeStr, Frequency: int32(job.Frequency), Active: job.Active}
-	opts := grpc.WithInsecure()
+	opts, errOpts := util.GRPCDialOption()
+	if errOpts != nil {
+		return errOpts
+	}
 	srv, errDial := grpc.Dial(g.URL, opts)
 	if errDial != nil {
 		return errDial
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	j "github.com/keenfury/axenda/job"
+	l "github.com/keenfury/axenda/logger"
+	"github.com/keenfury/axenda/metrics"
 	r "github.com/keenfury/axenda/runner"
 )
 
@@ -19,7 +21,8 @@ func (m *Mock) WhichDiscovery() string {
 }
 
 func (m *Mock) GetJobs(t time.Time) (jobs []j.Job, err error) {
-	fmt.Println("Mock: GetJob")
+	defer func() { metrics.ObserveDiscovery("Mock", len(jobs), err) }()
+	l.Debug("Mock: GetJobs", "time", t)
 	if t.IsZero() {
 		err = fmt.Errorf("Zero time")
 		return
@@ -30,15 +33,15 @@ func (m *Mock) GetJobs(t time.Time) (jobs []j.Job, err error) {
 }
 
 func (m *Mock) StartJob(job j.Job, updateCh chan<- j.Job) (err error) {
-	fmt.Println("Mock: StartJob")
-	job.Status = "In Process"
+	l.Debug("Mock: StartJob", "token", job.Token)
+	job.Status = j.StatusInProcess
 	updateCh <- job
 	return m.Runner.RunJob(&job)
 }
 
 func (m *Mock) CompleteJob(job j.Job, updateCh chan<- j.Job) (err error) {
-	fmt.Println("Mock: CompleteJob")
-	job.Status = "Done"
+	l.Debug("Mock: CompleteJob", "token", job.Token)
+	job.Status = j.StatusDone
 	updateCh <- job
 	return
 }
@@ -0,0 +1,18 @@
+package adapters
+
+import "errors"
+
+/*
+Adapters that own their jobs (File and DB) can also manage them, see the JobStore interface in main.go:
+- ListJobs: all jobs, active or not
+- AddJob: ErrJobExists if the token is already used
+- UpdateJob: replace the job with the same token, ErrJobNotFound if missing
+- DeleteJob: ErrJobNotFound if missing
+
+API and GRPC only know how to get and complete jobs, manage those jobs at their source.
+*/
+
+var (
+	ErrJobNotFound = errors.New("Job not found")
+	ErrJobExists   = errors.New("Job already exists")
+)
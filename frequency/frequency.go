@@ -18,9 +18,19 @@ const (
 	Quarterly
 )
 
+// Valid: true if the frequency is one of the above
+func Valid(frequency int) bool {
+	return frequency >= Once && frequency <= Quarterly
+}
+
 func Update(job *j.Job) (err error) {
+	return UpdateAt(job, util.GetNow())
+}
+
+// UpdateAt: same as Update as if it was called at the given time, used to simulate the schedule
+func UpdateAt(job *j.Job, now time.Time) (err error) {
 	// if the time is in the past, bring it to the current date, keeping the same hour/minute
-	truncNow := util.TruncateTimeToMinute(util.GetNow())
+	truncNow := util.TruncateTimeToMinute(now)
 	truncTime := util.TruncateTimeToMinute(job.RunTime)
 	if truncNow.Sub(truncTime) > 0 {
 		if job.Frequency == Minute {
@@ -45,7 +55,7 @@ func Update(job *j.Job) (err error) {
 	case Monthly:
 		job.RunTime = job.RunTime.AddDate(0, 1, 0)
 	case Quarterly:
-		job.RunTime = job.RunTime.AddDate(3, 0, 0)
+		job.RunTime = job.RunTime.AddDate(0, 3, 0)
 	default:
 		err = fmt.Errorf("Invalid frequency number")
 	}
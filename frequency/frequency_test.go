@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/keenfury/axenda/clock"
 	j "github.com/keenfury/axenda/job"
 	"github.com/keenfury/axenda/util"
 	"github.com/stretchr/testify/assert"
@@ -64,7 +65,7 @@ func TestFrequencyMonthly(t *testing.T) {
 
 func TestFrequencyQuarterly(t *testing.T) {
 	now := util.GetNow()
-	expecting := now.AddDate(3, 0, 0)
+	expecting := now.AddDate(0, 3, 0)
 	job := j.Job{RunTime: now, Frequency: 7}
 	err := Update(&job)
 	assert.Nil(t, err, "Expect no error")
@@ -114,3 +115,25 @@ func TestFrequencyInvaildFrequency(t *testing.T) {
 	err := Update(&job)
 	assert.NotNil(t, "Invalid frequency number", err.Error(), "Expecting Error")
 }
+
+func TestFrequencyUpdateAtDailyPast(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2020-04-23T15:00:00-06:00")
+	runTime, _ := time.Parse(time.RFC3339, "2020-04-01T12:24:00-06:00")
+	expecting, _ := time.Parse(time.RFC3339, "2020-04-24T12:24:00-06:00")
+	job := j.Job{RunTime: runTime, Frequency: 4}
+	err := UpdateAt(&job, now)
+	assert.Nil(t, err, "Expect no error")
+	assert.True(t, expecting.Equal(job.RunTime))
+}
+
+func TestFrequencyHourlyPastFakeClock(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2020-04-23T15:40:00-06:00")
+	util.Clock = clock.NewFake(now)
+	defer func() { util.Clock = clock.Real{} }()
+	runTime, _ := time.Parse(time.RFC3339, "2020-04-20T09:15:00-06:00")
+	expecting, _ := time.Parse(time.RFC3339, "2020-04-23T16:15:00-06:00")
+	job := j.Job{RunTime: runTime, Frequency: 3}
+	err := Update(&job)
+	assert.Nil(t, err, "Expect no error")
+	assert.True(t, expecting.Equal(job.RunTime))
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/keenfury/axenda/util"
+)
+
+/*
+Health endpoints, served when SCH_HEALTH_ADDR is set
+- /healthz: the minute loop ticked recently
+- /readyz: the last GetJobs succeeded and the discovery backend can be reached (if the adapter is a Pinger)
+*/
+
+// how long without a tick before the loop is considered dead
+const healthTickWindow = 2 * time.Minute
+
+type (
+	Pinger interface {
+		Ping() error
+	}
+
+	HealthCheck struct {
+		OK     bool   `json:"ok"`
+		Detail string `json:"detail"`
+	}
+
+	HealthResponse struct {
+		Status string                 `json:"status"`
+		Checks map[string]HealthCheck `json:"checks"`
+	}
+
+	healthState struct {
+		sync.Mutex
+		started      time.Time
+		lastTick     time.Time
+		lastGet      time.Time
+		lastGetError error
+	}
+)
+
+var health = healthState{started: util.GetNow()}
+
+// RecordTick: called on every tick of the minute loop
+func RecordTick(t time.Time) {
+	health.Lock()
+	defer health.Unlock()
+	health.lastTick = t
+}
+
+// RecordDiscovery: called with the result of every GetJobs
+func RecordDiscovery(t time.Time, err error) {
+	health.Lock()
+	defer health.Unlock()
+	health.lastGet = t
+	health.lastGetError = err
+}
+
+func HealthzHandler(w http.ResponseWriter, req *http.Request) {
+	health.Lock()
+	lastTick := health.lastTick
+	since := health.started
+	health.Unlock()
+	check := HealthCheck{OK: true, Detail: fmt.Sprintf("last tick: %s", lastTick.Format(time.RFC3339))}
+	if lastTick.IsZero() {
+		check.Detail = fmt.Sprintf("no tick yet, started: %s", since.Format(time.RFC3339))
+	} else {
+		since = lastTick
+	}
+	if util.GetNow().Sub(since) > healthTickWindow {
+		check.OK = false
+	}
+	writeHealth(w, map[string]HealthCheck{"tick": check})
+}
+
+func ReadyzHandler(w http.ResponseWriter, req *http.Request) {
+	health.Lock()
+	lastGet := health.lastGet
+	lastGetError := health.lastGetError
+	health.Unlock()
+	checks := map[string]HealthCheck{}
+	discovery := HealthCheck{OK: true, Detail: "no discovery yet"}
+	if !lastGet.IsZero() {
+		discovery.Detail = fmt.Sprintf("last discovery: %s", lastGet.Format(time.RFC3339))
+	}
+	if lastGetError != nil {
+		discovery = HealthCheck{OK: false, Detail: lastGetError.Error()}
+	}
+	checks["discovery"] = discovery
+	if pinger, ok := CurrentDiscovery().(Pinger); ok {
+		backend := HealthCheck{OK: true, Detail: CurrentDiscovery().WhichDiscovery()}
+		if errPing := pinger.Ping(); errPing != nil {
+			backend = HealthCheck{OK: false, Detail: errPing.Error()}
+		}
+		checks["backend"] = backend
+	}
+	writeHealth(w, checks)
+}
+
+func writeHealth(w http.ResponseWriter, checks map[string]HealthCheck) {
+	resp := HealthResponse{Status: "ok", Checks: checks}
+	code := http.StatusOK
+	for _, c := range checks {
+		if !c.OK {
+			resp.Status = "fail"
+			code = http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,96 @@
+package history
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/keenfury/axenda/config"
+	"github.com/keenfury/axenda/util"
+	_ "github.com/lib/pq"
+)
+
+/*
+This store will save the run history in the run_history table using the same config parameters as the DB discovery adapter
+- DBHost
+- DBUser
+- DBPwd
+- DBDB
+
+see the table syntax at the end of this file
+*/
+
+type (
+	DB struct {
+		DB        *sqlx.DB
+		Retention Retention
+	}
+)
+
+func (d *DB) Connect() (err error) {
+	connectionStr := fmt.Sprintf("user=%s password=%s dbname=%s host=%s sslmode=%s", config.DBUser, config.DBPwd, config.DBDB, config.DBHost, config.GetDBSSLMode())
+	d.DB, err = sqlx.Connect("postgres", connectionStr)
+	return
+}
+
+// Close: close the connection to the DB
+func (d *DB) Close() error {
+	return d.DB.Close()
+}
+
+func (d *DB) WhichHistory() string {
+	return "DB"
+}
+
+func (d *DB) Save(record Record) error {
+	sqlInsert := `insert into run_history (run_id, token, job_name, scheduled_time, start_time, end_time, attempt, runner, result, error, response, manual, workflow_run, triggered_by)
+		values (:run_id, :token, :job_name, :scheduled_time, :start_time, :end_time, :attempt, :runner, :result, :error, :response, :manual, :workflow_run, :triggered_by)`
+	if _, errExec := d.DB.NamedExec(sqlInsert, record); errExec != nil {
+		return errExec
+	}
+	if d.Retention.MaxAge > 0 {
+		sqlDelete := "delete from run_history where start_time < $1"
+		if _, errExec := d.DB.Exec(sqlDelete, util.GetNow().Add(-d.Retention.MaxAge)); errExec != nil {
+			return errExec
+		}
+	}
+	if d.Retention.MaxRecords > 0 {
+		sqlDelete := "delete from run_history where run_id not in (select run_id from run_history order by start_time desc limit $1)"
+		if _, errExec := d.DB.Exec(sqlDelete, d.Retention.MaxRecords); errExec != nil {
+			return errExec
+		}
+	}
+	return nil
+}
+
+func (d *DB) List(token string, from, to time.Time) (records []Record, err error) {
+	if to.IsZero() {
+		to = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+	}
+	sqlSelect := `select run_id, token, job_name, scheduled_time, start_time, end_time, attempt, runner, result, error, response, manual, workflow_run, triggered_by from run_history
+		where ($1 = '' or token = $1) and start_time >= $2 and start_time <= $3 order by start_time`
+	records = []Record{}
+	err = d.DB.Select(&records, sqlSelect, token, from, to)
+	return
+}
+
+/*
+create table run_history (
+	run_id varchar(32) not null primary key,
+	token text not null,
+	job_name text not null default '',
+	scheduled_time timestamp not null,
+	start_time timestamp not null,
+	end_time timestamp not null,
+	attempt int not null default 1,
+	runner text not null default '',
+	result varchar(10) not null,
+	error text not null default '',
+	response varchar(1024) not null default '',
+	manual boolean not null default false,
+	workflow_run varchar(32) not null default '',
+	triggered_by text not null default ''
+);
+
+create index run_history_token_start on run_history (token, start_time);
+*/
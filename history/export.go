@@ -0,0 +1,60 @@
+package history
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+/*
+Export of the records, oldest first:
+- csv: a header line then one line per record, times are RFC3339, duration_ms is end - start
+- jsonl: one json record per line, the format of the File store
+*/
+
+const (
+	FormatCSV       = "csv"
+	FormatJSONLines = "jsonl"
+)
+
+var csvHeader = []string{"run_id", "token", "job_name", "scheduled_time", "start_time", "end_time", "duration_ms", "attempt", "runner", "result", "error", "response", "manual", "workflow_run", "triggered_by"}
+
+// Export: write the records in the format
+func Export(w io.Writer, records []Record, format string) error {
+	switch format {
+	case FormatCSV:
+		return ExportCSV(w, records)
+	case FormatJSONLines, "":
+		return ExportJSONLines(w, records)
+	}
+	return fmt.Errorf("Unknown export format: %q, use csv or jsonl", format)
+}
+
+func ExportCSV(w io.Writer, records []Record) error {
+	writer := csv.NewWriter(w)
+	if errWrite := writer.Write(csvHeader); errWrite != nil {
+		return errWrite
+	}
+	for _, r := range records {
+		row := []string{r.RunID, r.Token, r.JobName, r.Scheduled.Format(time.RFC3339), r.Start.Format(time.RFC3339), r.End.Format(time.RFC3339),
+			strconv.FormatInt(r.Duration().Milliseconds(), 10), strconv.Itoa(r.Attempt), r.Runner, r.Result, r.Error, r.Response, strconv.FormatBool(r.Manual), r.Workflow, r.Trigger}
+		if errWrite := writer.Write(row); errWrite != nil {
+			return errWrite
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func ExportJSONLines(w io.Writer, records []Record) error {
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if errEncode := enc.Encode(r); errEncode != nil {
+			return errEncode
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/keenfury/axenda/util"
+)
+
+type (
+	File struct {
+		FileName  string
+		Retention Retention
+
+		mu sync.Mutex
+	}
+)
+
+func (f *File) WhichHistory() string {
+	return "File"
+}
+
+func (f *File) Save(record Record) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Retention.MaxRecords == 0 && f.Retention.MaxAge == 0 {
+		return f.appendRecord(record)
+	}
+	records, errRead := f.readRecords()
+	if errRead != nil {
+		return errRead
+	}
+	records = append(records, record)
+	return f.writeRecords(f.Retention.Prune(records, util.GetNow()))
+}
+
+func (f *File) List(token string, from, to time.Time) (records []Record, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	all, errRead := f.readRecords()
+	if errRead != nil {
+		err = errRead
+		return
+	}
+	for _, r := range all {
+		if r.Match(token, from, to) {
+			records = append(records, r)
+		}
+	}
+	return
+}
+
+func (f *File) appendRecord(record Record) error {
+	bRecord, errM := json.Marshal(record)
+	if errM != nil {
+		return errM
+	}
+	file, errOpen := os.OpenFile(f.FileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if errOpen != nil {
+		return errOpen
+	}
+	defer file.Close()
+	_, errWrite := file.Write(append(bRecord, '\n'))
+	return errWrite
+}
+
+func (f *File) readRecords() (records []Record, err error) {
+	bContent, errRead := ioutil.ReadFile(f.FileName)
+	if errRead != nil {
+		if os.IsNotExist(errRead) {
+			return
+		}
+		err = errRead
+		return
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(bContent))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		r := Record{}
+		if err = json.Unmarshal(line, &r); err != nil {
+			return
+		}
+		records = append(records, r)
+	}
+	err = scanner.Err()
+	return
+}
+
+func (f *File) writeRecords(records []Record) error {
+	var buf bytes.Buffer
+	for _, r := range records {
+		bRecord, errM := json.Marshal(r)
+		if errM != nil {
+			return errM
+		}
+		buf.Write(bRecord)
+		buf.WriteByte('\n')
+	}
+	return util.WriteFileAtomic(f.FileName, buf.Bytes(), 0644)
+}
+
+/*
+
+Sample File content format, one record per line
+{"run_id":"...","token":"FILETOKEN1","job_name":"Test Job From File","scheduled_time":"2020-04-23T12:24:00-06:00","start_time":"2020-04-23T12:24:00-06:00","end_time":"2020-04-23T12:24:01-06:00","attempt":1,"runner":"API","result":"Success","response":"queued"}
+*/
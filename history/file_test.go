@@ -0,0 +1,95 @@
+package history
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	j "github.com/keenfury/axenda/job"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSaveListSuccess(t *testing.T) {
+	fileName := "/tmp/history_test_save"
+	os.Remove(fileName)
+	defer os.Remove(fileName)
+	file := File{FileName: fileName}
+	now := time.Now()
+	record := NewRecord(j.Job{Token: "TOKENFILE", RunTime: now}, "Mock", now)
+	record.Finish(now, nil)
+	assert.Nil(t, file.Save(record), "No error expected")
+	other := NewRecord(j.Job{Token: "OTHERTOKEN", RunTime: now}, "Mock", now)
+	other.Finish(now, fmt.Errorf("Runner failure"))
+	assert.Nil(t, file.Save(other), "No error expected")
+	records, err := file.List("TOKENFILE", now.Add(-time.Hour), now.Add(time.Hour))
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 1, len(records), "Expected records count to be 1")
+	assert.Equal(t, ResultSuccess, records[0].Result)
+	records, err = file.List("", time.Time{}, time.Time{})
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 2, len(records), "Expected records count to be 2")
+	assert.Equal(t, "Runner failure", records[1].Error)
+}
+
+func TestRecordSetResult(t *testing.T) {
+	fileName := "/tmp/history_test_result"
+	os.Remove(fileName)
+	defer os.Remove(fileName)
+	file := File{FileName: fileName}
+	now := time.Now()
+	record := NewRecord(j.Job{Token: "TOKENFILE", RunTime: now}, "API", now)
+	record.Finish(now, nil)
+	record.SetResult(&j.RunResult{Attempts: 0, Response: strings.Repeat("x", j.MaxResponse+1)})
+	assert.Nil(t, file.Save(record), "No error expected")
+	records, _ := file.List("TOKENFILE", time.Time{}, time.Time{})
+	assert.Equal(t, 1, len(records), "Expected records count to be 1")
+	assert.Equal(t, 0, records[0].Attempt, "Expected the attempts reported by the runner")
+	assert.Equal(t, j.MaxResponse, len(records[0].Response), "Expected the response capped")
+}
+
+func TestFileSaveRetentionSuccess(t *testing.T) {
+	fileName := "/tmp/history_test_retention"
+	os.Remove(fileName)
+	defer os.Remove(fileName)
+	file := File{FileName: fileName, Retention: Retention{MaxRecords: 2, MaxAge: 24 * time.Hour}}
+	now := time.Now()
+	old := NewRecord(j.Job{Token: "OLD"}, "Mock", now.AddDate(0, 0, -2))
+	assert.Nil(t, file.Save(old))
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, file.Save(NewRecord(j.Job{Token: fmt.Sprintf("TOKEN%d", i)}, "Mock", now)))
+	}
+	records, err := file.List("", time.Time{}, time.Time{})
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 2, len(records), "Expected records count to be 2")
+	assert.Equal(t, "TOKEN1", records[0].Token)
+}
+
+func TestFileListMissingFileSuccess(t *testing.T) {
+	file := File{FileName: "/tmp/history_test_missing"}
+	records, err := file.List("", time.Time{}, time.Time{})
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 0, len(records))
+}
+
+func TestFileListFailure(t *testing.T) {
+	fileName := "/tmp/history_test_fail"
+	ioutil.WriteFile(fileName, []byte(`{"run_id":"1"`), 0644)
+	defer os.Remove(fileName)
+	file := File{FileName: fileName}
+	_, err := file.List("", time.Time{}, time.Time{})
+	assert.NotNil(t, err, "Error expected")
+}
+
+func TestMemorySaveListSuccess(t *testing.T) {
+	memory := Memory{Retention: Retention{MaxRecords: 1}}
+	now := time.Now()
+	memory.Save(NewRecord(j.Job{Token: "FIRST"}, "Mock", now))
+	memory.Save(NewRecord(j.Job{Token: "SECOND"}, "Mock", now))
+	records, err := memory.List("", time.Time{}, time.Time{})
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 1, len(records), "Expected records count to be 1")
+	assert.Equal(t, "SECOND", records[0].Token)
+}
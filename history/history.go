@@ -0,0 +1,113 @@
+package history
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	j "github.com/keenfury/axenda/job"
+)
+
+/*
+A Record is kept for every execution of a job, saved through one of the history stores, Attempt and Response are what
+the runner reported (the calls made to the target and the start of its response, see j.RunResult):
+- File: JSON lines
+- DB: run_history table (see db.go)
+- Memory: the failsafe, lost on restart
+
+MaxRecords and MaxAge are the retention limits for each store, zero means keep forever.
+*/
+
+const (
+	ResultSuccess = "Success"
+	ResultError   = "Error"
+)
+
+type (
+	Record struct {
+		RunID     string    `db:"run_id" json:"run_id"`
+		Token     string    `db:"token" json:"token"`
+		JobName   string    `db:"job_name" json:"job_name"`
+		Scheduled time.Time `db:"scheduled_time" json:"scheduled_time"`
+		Start     time.Time `db:"start_time" json:"start_time"`
+		End       time.Time `db:"end_time" json:"end_time"`
+		Attempt   int       `db:"attempt" json:"attempt"`
+		Runner    string    `db:"runner" json:"runner"`
+		Result    string    `db:"result" json:"result"`
+		Error     string    `db:"error" json:"error,omitempty"`
+		Response  string    `db:"response" json:"response,omitempty"`
+		Manual    bool      `db:"manual" json:"manual"`
+		Workflow  string    `db:"workflow_run" json:"workflow_run,omitempty"`
+		Trigger   string    `db:"triggered_by" json:"triggered_by,omitempty"`
+	}
+
+	Retention struct {
+		MaxRecords int
+		MaxAge     time.Duration
+	}
+)
+
+// NewRecord: start a record for the job at the given start time
+func NewRecord(job j.Job, runner string, start time.Time) Record {
+	return Record{RunID: NewRunID(), Token: job.Token, JobName: job.JobName, Scheduled: job.RunTime, Start: start, Attempt: 1, Runner: runner}
+}
+
+// Finish: set the end time and result of the record, err is the error of the run if any
+func (r *Record) Finish(end time.Time, err error) {
+	r.End = end
+	r.Result = ResultSuccess
+	if err != nil {
+		r.Result = ResultError
+		r.Error = err.Error()
+	}
+}
+
+// SetResult: the attempts and the response the runner reported, the response is capped at j.MaxResponse
+func (r *Record) SetResult(result *j.RunResult) {
+	if result == nil {
+		return
+	}
+	r.Attempt = result.Attempts
+	r.Response = result.Response
+	if len(r.Response) > j.MaxResponse {
+		r.Response = r.Response[:j.MaxResponse]
+	}
+}
+
+// Match: true if the record is for the token (empty matches all) and started within from/to (zero is open ended)
+func (r Record) Match(token string, from, to time.Time) bool {
+	if len(token) > 0 && r.Token != token {
+		return false
+	}
+	if !from.IsZero() && r.Start.Before(from) {
+		return false
+	}
+	if !to.IsZero() && r.Start.After(to) {
+		return false
+	}
+	return true
+}
+
+// Prune: apply the retention limits, records are expected oldest first
+func (rt Retention) Prune(records []Record, now time.Time) []Record {
+	if rt.MaxAge > 0 {
+		cutOff := now.Add(-rt.MaxAge)
+		keep := records[:0]
+		for _, r := range records {
+			if !r.Start.Before(cutOff) {
+				keep = append(keep, r)
+			}
+		}
+		records = keep
+	}
+	if rt.MaxRecords > 0 && len(records) > rt.MaxRecords {
+		records = records[len(records)-rt.MaxRecords:]
+	}
+	return records
+}
+
+func NewRunID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,40 @@
+package history
+
+import (
+	"sync"
+	"time"
+
+	"github.com/keenfury/axenda/util"
+)
+
+type (
+	Memory struct {
+		Retention Retention
+
+		mu      sync.Mutex
+		records []Record
+	}
+)
+
+func (m *Memory) WhichHistory() string {
+	return "Memory"
+}
+
+func (m *Memory) Save(record Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, record)
+	m.records = m.Retention.Prune(m.records, util.GetNow())
+	return nil
+}
+
+func (m *Memory) List(token string, from, to time.Time) (records []Record, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range m.records {
+		if r.Match(token, from, to) {
+			records = append(records, r)
+		}
+	}
+	return
+}
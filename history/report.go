@@ -0,0 +1,118 @@
+package history
+
+import (
+	"sort"
+	"time"
+)
+
+/*
+Report: a summary of the records of a time range
+- per job: runs, successes and failures, the success rate, the average schedule lag and the average/longest duration
+- the longest runs overall
+
+The schedule lag (start - scheduled time) only counts the runs started by the schedule, manual and hook runs and
+jobs without a run time (downstream of a workflow) have no schedule to be late for.
+*/
+
+type (
+	Report struct {
+		From        time.Time    `json:"from,omitempty"`
+		To          time.Time    `json:"to,omitempty"`
+		Runs        int          `json:"runs"`
+		Failures    int          `json:"failures"`
+		SuccessRate float64      `json:"success_rate"`
+		Jobs        []JobSummary `json:"jobs"`
+		Longest     []Record     `json:"longest"`
+	}
+
+	JobSummary struct {
+		Token       string  `json:"token"`
+		JobName     string  `json:"job_name"`
+		Runs        int     `json:"runs"`
+		Successes   int     `json:"successes"`
+		Failures    int     `json:"failures"`
+		SuccessRate float64 `json:"success_rate"`
+		// durations in seconds
+		AvgLag      float64 `json:"avg_lag_seconds"`
+		AvgDuration float64 `json:"avg_duration_seconds"`
+		MaxDuration float64 `json:"max_duration_seconds"`
+
+		lagTotal  time.Duration
+		lagRuns   int
+		durations time.Duration
+	}
+)
+
+// Duration: how long the run took
+func (r Record) Duration() time.Duration {
+	if r.End.Before(r.Start) {
+		return 0
+	}
+	return r.End.Sub(r.Start)
+}
+
+// scheduled: the run was started by the schedule
+func (r Record) scheduled() bool {
+	return !r.Manual && len(r.Trigger) == 0 && !r.Scheduled.IsZero()
+}
+
+// Summarize: the report of the records, the jobs sorted by token, the top longest runs (0 is none)
+func Summarize(records []Record, from, to time.Time, top int) Report {
+	report := Report{From: from, To: to, Jobs: []JobSummary{}, Longest: []Record{}}
+	byToken := map[string]*JobSummary{}
+	for _, r := range records {
+		summary, ok := byToken[r.Token]
+		if !ok {
+			summary = &JobSummary{Token: r.Token}
+			byToken[r.Token] = summary
+		}
+		// the latest name
+		if len(r.JobName) > 0 {
+			summary.JobName = r.JobName
+		}
+		summary.Runs++
+		report.Runs++
+		if r.Result == ResultSuccess {
+			summary.Successes++
+		} else {
+			summary.Failures++
+			report.Failures++
+		}
+		duration := r.Duration()
+		summary.durations += duration
+		if seconds := duration.Seconds(); seconds > summary.MaxDuration {
+			summary.MaxDuration = seconds
+		}
+		if r.scheduled() {
+			summary.lagTotal += r.Start.Sub(r.Scheduled)
+			summary.lagRuns++
+		}
+	}
+	report.SuccessRate = rate(report.Runs-report.Failures, report.Runs)
+	for _, summary := range byToken {
+		summary.SuccessRate = rate(summary.Successes, summary.Runs)
+		summary.AvgDuration = (summary.durations / time.Duration(summary.Runs)).Seconds()
+		if summary.lagRuns > 0 {
+			summary.AvgLag = (summary.lagTotal / time.Duration(summary.lagRuns)).Seconds()
+		}
+		report.Jobs = append(report.Jobs, *summary)
+	}
+	sort.Slice(report.Jobs, func(i, j int) bool { return report.Jobs[i].Token < report.Jobs[j].Token })
+	if top > 0 {
+		longest := append([]Record{}, records...)
+		sort.SliceStable(longest, func(i, j int) bool { return longest[i].Duration() > longest[j].Duration() })
+		if len(longest) > top {
+			longest = longest[:top]
+		}
+		report.Longest = longest
+	}
+	return report
+}
+
+// rate: part of total as a percentage, 0 when there is nothing
+func rate(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(total)
+}
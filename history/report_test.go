@@ -0,0 +1,60 @@
+package history
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	j "github.com/keenfury/axenda/job"
+	"github.com/stretchr/testify/assert"
+)
+
+func reportRecords() []Record {
+	scheduled, _ := time.Parse(time.RFC3339, "2020-04-23T02:00:00-06:00")
+	nightly := j.Job{Token: "NIGHTLY", JobName: "Nightly report", RunTime: scheduled}
+	first := NewRecord(nightly, "API", scheduled.Add(10*time.Second))
+	first.Finish(first.Start.Add(time.Minute), nil)
+	second := NewRecord(nightly, "API", scheduled.Add(30*time.Second))
+	second.Finish(second.Start.Add(3*time.Minute), fmt.Errorf("Unexpected code: 500"))
+	manual := NewRecord(nightly, "API", scheduled.Add(time.Hour))
+	manual.Manual = true
+	manual.Finish(manual.Start.Add(2*time.Minute), nil)
+	other := NewRecord(j.Job{Token: "HOURLY", RunTime: scheduled}, "API", scheduled)
+	other.Finish(other.Start.Add(time.Second), nil)
+	return []Record{first, second, manual, other}
+}
+
+func TestSummarize(t *testing.T) {
+	report := Summarize(reportRecords(), time.Time{}, time.Time{}, 2)
+	assert.Equal(t, 4, report.Runs)
+	assert.Equal(t, 1, report.Failures)
+	assert.Equal(t, 75.0, report.SuccessRate)
+	assert.Equal(t, 2, len(report.Jobs))
+	nightly := report.Jobs[1]
+	assert.Equal(t, "NIGHTLY", nightly.Token)
+	assert.Equal(t, 3, nightly.Runs)
+	assert.InDelta(t, 66.67, nightly.SuccessRate, 0.01)
+	assert.Equal(t, 20.0, nightly.AvgLag, "Expected the manual run out of the lag")
+	assert.Equal(t, 120.0, nightly.AvgDuration)
+	assert.Equal(t, 180.0, nightly.MaxDuration)
+	assert.Equal(t, 2, len(report.Longest))
+	assert.Equal(t, ResultError, report.Longest[0].Result, "Expected the 3 minutes run first")
+}
+
+func TestExportCSV(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, Export(&buf, reportRecords(), FormatCSV))
+	rows, err := csv.NewReader(&buf).ReadAll()
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(rows), "Expected the header and a row per record")
+	assert.Equal(t, "duration_ms", rows[0][6])
+	assert.Equal(t, "180000", rows[2][6])
+	assert.Equal(t, "Unexpected code: 500", rows[2][10])
+	buf.Reset()
+	assert.Nil(t, Export(&buf, reportRecords(), FormatJSONLines))
+	assert.Equal(t, 4, strings.Count(buf.String(), "\n"))
+	assert.NotNil(t, Export(&buf, nil, "xml"))
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"strings"
+
+	d "github.com/keenfury/axenda/discovery"
+	h "github.com/keenfury/axenda/history"
+	j "github.com/keenfury/axenda/job"
+	"github.com/keenfury/axenda/metrics"
+	r "github.com/keenfury/axenda/runner"
+	"github.com/keenfury/axenda/util"
+)
+
+/*
+Hooks chain jobs without a workflow, each job can name what to trigger after it ran:
+- on_success: when the run succeeded
+- on_failure: when the run failed
+- always: either way
+
+	{"token":"EXPORT", ..., "on_failure":["CLEANUP", "https://hooks.example.com/export_failed"], "always":["NOTIFY"]}
+
+An entry is the token of a job, run now without changing its schedule, or a runner target (a url) called through the
+runner with the job that triggered it.  Jobs run by a hook trigger their own hooks, a token already in the chain is
+left out so hooks can't loop.  The history record of a job run by a hook has the token that triggered it.
+*/
+
+// HookTargets: the entries triggered by the result of the run, always last
+func HookTargets(job j.Job, errRun error) []string {
+	targets := []string{}
+	if errRun == nil {
+		targets = append(targets, job.OnSuccess...)
+	} else {
+		targets = append(targets, job.OnFailure...)
+	}
+	return append(targets, job.Always...)
+}
+
+// RunHooks: trigger the hooks of the job after its run, chain is the tokens that led to this run (the job included)
+func RunHooks(job j.Job, errRun error, chain []string) {
+	targets := HookTargets(job, errRun)
+	if len(targets) == 0 {
+		return
+	}
+	if len(chain) == 0 {
+		chain = []string{job.Token}
+	}
+	var jobs []j.Job
+	var errJobs error
+	for _, target := range targets {
+		if util.IsHookURL(target) {
+			hookURL := target
+			goInFlight(func() { RunHookURL(job, hookURL) })
+			continue
+		}
+		if inChain(chain, target) {
+			logAdapter.Warn("RunHooks: job already ran in the chain, skipped", "token", target, "chain", strings.Join(chain, " => "))
+			continue
+		}
+		if jobs == nil && errJobs == nil {
+			jobs, errJobs = ListAllJobs(util.GetNow())
+		}
+		hookJob, errFind := findIn(jobs, target, errJobs)
+		if errFind != nil {
+			logAdapter.Error("RunHooks: unable to trigger job", "token", job.Token, "target", target, "error", errFind)
+			continue
+		}
+		hookChain := append(append([]string{}, chain...), target)
+		goInFlight(func() { RunHookJob(hookJob, hookChain) })
+	}
+}
+
+// RunHookJob: run the job triggered by the last token of chain but one, like a manual run its schedule is left alone
+func RunHookJob(job j.Job, chain []string) {
+	runner, history, done := acquireAdapters()
+	defer done()
+	job.Result = &j.RunResult{}
+	record := h.NewRecord(job, runner.WhichRunner(), util.GetNow())
+	if len(chain) > 1 {
+		record.Trigger = chain[len(chain)-2]
+	}
+	errRun := RunnerRun(runner, job)
+	if errRun != nil {
+		logAdapter.Error("RunHookJob: run failed", "token", job.Token, "job_name", job.JobName, "runner", record.Runner, "run_id", record.RunID, "triggered_by", record.Trigger, "error", errRun)
+	}
+	record.Finish(util.GetNow(), errRun)
+	record.SetResult(job.Result)
+	metrics.ObserveDispatch(record.Runner, record.End.Sub(record.Start), 0)
+	if errSave := history.Save(record); errSave != nil {
+		logAdapter.Error("RunHookJob: unable to save history", "token", job.Token, "run_id", record.RunID, "error", errSave)
+	}
+	NotifyRun(job, errRun)
+	RunHooks(job, errRun, chain)
+}
+
+// RunHookURL: call the runner target with the job
+func RunHookURL(job j.Job, target string) {
+	runner, _, done := acquireAdapters()
+	defer done()
+	job.UrlPath = target
+	if errRun := RunnerRun(runner, job); errRun != nil {
+		logAdapter.Error("RunHookURL: call failed", "token", job.Token, "target", target, "error", errRun)
+	}
+}
+
+func inChain(chain []string, token string) bool {
+	for _, t := range chain {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+// RunnerRun: run the job through the runner with the token the target issued, a Composite namespaces the tokens
+// and only StartJob/CompleteJob route them back to their source
+func RunnerRun(runner r.RunnerAdapter, job j.Job) error {
+	if composite, ok := CurrentDiscovery().(*d.Composite); ok {
+		sourceJob, errSource := composite.SourceJob(job)
+		if errSource != nil {
+			return errSource
+		}
+		job = sourceJob
+	}
+	return runner.RunJob(&job)
+}
@@ -2,9 +2,35 @@ package jobs
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
+/*
+Status of a Job while the scheduler knows about it, the allowed transitions are:
+
+	(new) => Received => In Process => Done
+	Received or In Process => Error
+
+Done and Error are terminal, the Job is removed from the scheduler's list and will come back through discovery when it is due again.
+*/
+
+const (
+	StatusNone      Status = ""
+	StatusReceived  Status = "Received"
+	StatusInProcess Status = "In Process"
+	StatusDone      Status = "Done"
+	StatusError     Status = "Error"
+)
+
+var transitions = map[Status][]Status{
+	StatusNone:      {StatusReceived},
+	StatusReceived:  {StatusInProcess, StatusError},
+	StatusInProcess: {StatusDone, StatusError},
+}
+
 type (
 	Job struct {
 		Token     string          `db:"token" json:"token"`
@@ -14,6 +40,86 @@ type (
 		Frequency int             `db:"frequency" json:"frequency"`
 		Active    bool            `db:"active" json:"active"`
 		Payload   json.RawMessage `db:"payload" json:"payload"`
-		Status    string          `json:"-"`
+		// DependsOn: tokens of the jobs this job runs after, see the workflow package
+		DependsOn []string `db:"-" json:"depends_on,omitempty"`
+		// OnSuccess, OnFailure, Always: job tokens or runner targets (urls) triggered after a run, see hooks.go
+		OnSuccess []string `db:"-" json:"on_success,omitempty"`
+		OnFailure []string `db:"-" json:"on_failure,omitempty"`
+		Always    []string `db:"-" json:"always,omitempty"`
+		// StartWithin, SucceedBy: expectations checked by the watchdog e.g. "5m" and "06:00", see the sla package
+		StartWithin string `db:"-" json:"start_within,omitempty"`
+		SucceedBy   string `db:"-" json:"succeed_by,omitempty"`
+		// Notify: email addresses or webhook urls ("slack:" url for Slack) alerted on failure, see the notify package
+		Notify      []string     `db:"-" json:"notify,omitempty"`
+		Status      Status       `json:"-"`
+		Error       string       `json:"-"`
+		Transitions []Transition `json:"-"`
+		// TraceParent: the W3C trace context of the run, sent on to the target by the runner, see the tracing package
+		TraceParent string `db:"-" json:"-"`
+		// Result: set by the caller before the run, the runner reports on it (the job is copied on its way there)
+		Result *RunResult `db:"-" json:"-"`
+	}
+
+	// RunResult: what the runner reports of a run, kept in the run history
+	RunResult struct {
+		// Attempts: the calls made to the target, 0 when the run failed before reaching it (e.g. an open circuit)
+		Attempts int
+		// Response: the start of the target's response, at most MaxResponse bytes
+		Response string
+	}
+
+	Status string
+
+	Transition struct {
+		From Status    `json:"from"`
+		To   Status    `json:"to"`
+		At   time.Time `json:"at"`
 	}
 )
+
+// MaxResponse: the bytes of a target's response kept for the run history
+const MaxResponse = 1024
+
+// Called: the runner called the target, response is its answer if any, kept as valid UTF-8 (without NUL, refused by
+// Postgres) and cut at a rune boundary
+func (r *RunResult) Called(response []byte) {
+	if r == nil {
+		return
+	}
+	r.Attempts++
+	text := strings.ToValidUTF8(strings.ReplaceAll(string(response), "\x00", ""), "\uFFFD")
+	if len(text) > MaxResponse {
+		cut := MaxResponse
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut]
+	}
+	r.Response = text
+}
+
+func (s Status) IsTerminal() bool {
+	return s == StatusDone || s == StatusError
+}
+
+func (s Status) CanTransition(to Status) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// SetStatus: move the Job to the new status, recording when it happened, errMsg is only kept for StatusError
+func (j *Job) SetStatus(to Status, at time.Time, errMsg string) error {
+	if !j.Status.CanTransition(to) {
+		return fmt.Errorf("Invalid status transition for %s: %q => %q", j.Token, j.Status, to)
+	}
+	j.Transitions = append(j.Transitions, Transition{From: j.Status, To: to, At: at})
+	j.Status = to
+	if to == StatusError {
+		j.Error = errMsg
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package jobs
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetStatusSuccess(t *testing.T) {
+	job := Job{Token: "TOKENJOB"}
+	now := time.Now()
+	assert.Nil(t, job.SetStatus(StatusReceived, now, ""))
+	assert.Nil(t, job.SetStatus(StatusInProcess, now, ""))
+	assert.Nil(t, job.SetStatus(StatusDone, now, ""))
+	assert.Equal(t, StatusDone, job.Status)
+	assert.Equal(t, 3, len(job.Transitions), "Expected a transition per status")
+	assert.Equal(t, StatusInProcess, job.Transitions[2].From)
+}
+
+func TestSetStatusErrorSuccess(t *testing.T) {
+	job := Job{Token: "TOKENJOB", Status: StatusReceived}
+	assert.Nil(t, job.SetStatus(StatusError, time.Now(), "Runner failure"))
+	assert.Equal(t, "Runner failure", job.Error)
+	assert.True(t, job.Status.IsTerminal())
+}
+
+func TestSetStatusFailure(t *testing.T) {
+	job := Job{Token: "TOKENJOB", Status: StatusError}
+	err := job.SetStatus(StatusDone, time.Now(), "")
+	assert.NotNil(t, err, "Error expected")
+	assert.Equal(t, `Invalid status transition for TOKENJOB: "Error" => "Done"`, err.Error())
+	assert.Equal(t, StatusError, job.Status, "Status should not change")
+}
+
+func TestSetStatusSkipFailure(t *testing.T) {
+	job := Job{Token: "TOKENJOB"}
+	assert.NotNil(t, job.SetStatus(StatusDone, time.Now(), ""), "Error expected")
+}
+
+func TestRunResultCalledUTF8(t *testing.T) {
+	result := &RunResult{}
+	// a 2 byte rune across the cut
+	result.Called([]byte(strings.Repeat("a", MaxResponse-1) + "é"))
+	assert.Equal(t, 1, result.Attempts)
+	assert.Equal(t, strings.Repeat("a", MaxResponse-1), result.Response, "Expected the cut before the rune")
+	result.Called([]byte{'o', 'k', 0xff, 0x00, 0xfe})
+	assert.Equal(t, 2, result.Attempts)
+	assert.True(t, utf8.ValidString(result.Response), "Expected valid UTF-8")
+	assert.Equal(t, "ok\uFFFD", result.Response)
+}
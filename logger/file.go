@@ -1,21 +1,305 @@
 package loggers
 
 import (
+	"bufio"
+	"compress/gzip"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/keenfury/axenda/config"
+	"github.com/keenfury/axenda/util"
 )
 
-type File struct{}
+/*
+File: a long lived, buffered log file (SCH_LOG_FILE_NAME)
+- the buffer is flushed every FlushInterval (defaults to 1s) and on Close
+- rotated once MaxSize bytes would be passed (SCH_LOG_MAX_SIZE) and/or every RotateEvery (SCH_LOG_ROTATE_EVERY), the
+  rotated file is renamed with the time e.g. axenda.log.2020-04-23T12-22-00 and gzipped if Compress (SCH_LOG_COMPRESS)
+- only the MaxBackups newest rotated files are kept (SCH_LOG_MAX_BACKUPS), zero keeps them all
+- Reopen closes and opens the file again, called on SIGHUP for an external logrotate that moved the file away
 
-func (f *File) SetMessage(msg string) {
-	file, errOpen := os.OpenFile(config.LogFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+It never panics and keeps every entry: a write that cannot open the file returns the error and the Logger writes the
+entry to stderr instead, what the open file does not take is written from the buffer to stderr, the file is opened
+again on the next write (e.g. once the disk has room again).  A rotation unable to rename the file keeps appending to
+it and tries again once the next rotation is due.
+*/
+
+const (
+	defaultFlushInterval = time.Second
+	bufferSize           = 64 * 1024
+	rotateTimeFormat     = "2006-01-02T15-04-05"
+)
+
+// stderr: where the entries go when the file fails
+var stderr io.Writer = os.Stderr
+
+// rename: moves the file away on a rotation
+var rename = os.Rename
+
+type File struct {
+	FileName      string
+	MaxSize       int64
+	RotateEvery   time.Duration
+	MaxBackups    int
+	Compress      bool
+	FlushInterval time.Duration
+
+	mu     sync.Mutex
+	file   *os.File
+	out    *fileOut
+	buf    *bufio.Writer
+	size   int64
+	opened time.Time
+	closed bool
+	stopCh chan struct{}
+	// rotating: the compress/prune of the rotated files, waited for by Close
+	rotating sync.WaitGroup
+}
+
+// fileOut: the file under the buffer, what the file does not take goes to stderr instead of being lost, the error is
+// kept for the File to start over with the file
+type fileOut struct {
+	file *os.File
+	err  error
+}
+
+func (o *fileOut) Write(p []byte) (n int, err error) {
+	if o.err == nil {
+		if n, o.err = o.file.Write(p); o.err == nil {
+			return
+		}
+		fmt.Fprintf(stderr, "Unable to write the log file %s, writing to stderr: %s\n", o.file.Name(), o.err)
+	}
+	stderr.Write(p[n:])
+	return len(p), nil
+}
+
+func (f *File) Write(p []byte) (n int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0, fmt.Errorf("Log file closed: %s", f.FileName)
+	}
+	if f.file == nil {
+		if err = f.open(); err != nil {
+			return
+		}
+	}
+	if f.rotateDue(int64(len(p))) {
+		if err = f.rotate(); err != nil {
+			return
+		}
+	}
+	n, err = f.buf.Write(p)
+	f.size += int64(n)
+	if f.out.err != nil {
+		// the buffer goes to stderr, start over with the file on the next write
+		f.flush()
+	}
+	return
+}
+
+// Flush: write the buffer to the file
+func (f *File) Flush() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.flush()
+}
+
+// Reopen: close and open the file again on the next write, e.g. after logrotate moved it
+func (f *File) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	errFlush := f.flush()
+	f.drop()
+	return errFlush
+}
+
+// Close: flush and close the file, waits for the rotated files to be compressed, writes fail afterwards
+func (f *File) Close() error {
+	f.mu.Lock()
+	errFlush := f.flush()
+	f.drop()
+	f.closed = true
+	f.mu.Unlock()
+	f.rotating.Wait()
+	return errFlush
+}
+
+func (f *File) open() error {
+	file, errOpen := os.OpenFile(f.FileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if errOpen != nil {
+		return errOpen
+	}
+	info, errStat := file.Stat()
+	if errStat != nil {
+		file.Close()
+		return errStat
+	}
+	f.file = file
+	f.out = &fileOut{file: file}
+	f.buf = bufio.NewWriterSize(f.out, bufferSize)
+	f.size = info.Size()
+	f.opened = util.GetNow()
+	f.stopCh = make(chan struct{})
+	go f.flushLoop(f.stopCh)
+	return nil
+}
+
+// drop: close the file, flushed or not
+func (f *File) drop() {
+	if f.file == nil {
+		return
+	}
+	close(f.stopCh)
+	f.file.Close()
+	f.file = nil
+	f.out = nil
+	f.buf = nil
+}
+
+// flush: write the buffer, what the file does not take goes to stderr and the file is dropped
+func (f *File) flush() error {
+	if f.buf == nil {
+		return nil
+	}
+	f.buf.Flush()
+	if errWrite := f.out.err; errWrite != nil {
+		f.drop()
+		return errWrite
+	}
+	return nil
+}
+
+func (f *File) flushLoop(stopCh chan struct{}) {
+	interval := f.FlushInterval
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			f.Flush()
+		}
+	}
+}
+
+func (f *File) rotateDue(next int64) bool {
+	if f.MaxSize > 0 && f.size > 0 && f.size+next > f.MaxSize {
+		return true
+	}
+	return f.RotateEvery > 0 && util.GetNow().Sub(f.opened) >= f.RotateEvery
+}
+
+// rotate: rename the file with the time and start a new one, compress and prune in the background
+func (f *File) rotate() error {
+	if errFlush := f.flush(); errFlush != nil {
+		return errFlush
+	}
+	f.drop()
+	rotated := fmt.Sprintf("%s.%s", f.FileName, util.GetNow().Format(rotateTimeFormat))
+	for i := 1; exists(rotated) || exists(rotated+".gz"); i++ {
+		rotated = fmt.Sprintf("%s.%s.%d", f.FileName, util.GetNow().Format(rotateTimeFormat), i)
+	}
+	if errRename := rename(f.FileName, rotated); errRename != nil && !os.IsNotExist(errRename) {
+		fmt.Fprintf(stderr, "Unable to rotate the log file %s, appending to it: %s\n", f.FileName, errRename)
+		if errOpen := f.open(); errOpen != nil {
+			return errOpen
+		}
+		// rotate again once it is due from here
+		f.size = 0
+		return nil
+	}
+	f.rotating.Add(1)
+	go func() {
+		defer f.rotating.Done()
+		if f.Compress {
+			if errCompress := compress(rotated); errCompress != nil {
+				fmt.Fprintf(os.Stderr, "Unable to compress the log file %s: %s\n", rotated, errCompress)
+			}
+		}
+		f.prune()
+	}()
+	return f.open()
+}
+
+// Backups: the rotated files, oldest first
+func (f *File) Backups() []string {
+	matches, _ := filepath.Glob(f.FileName + ".*")
+	backups := []string{}
+	for _, match := range matches {
+		suffix := strings.TrimPrefix(match, f.FileName+".")
+		if len(suffix) < len(rotateTimeFormat) || strings.HasSuffix(match, ".tmp") {
+			continue
+		}
+		if _, errParse := time.Parse(rotateTimeFormat, suffix[:len(rotateTimeFormat)]); errParse == nil {
+			backups = append(backups, match)
+		}
+	}
+	// the time in the name sorts them
+	sort.Strings(backups)
+	return backups
+}
+
+// prune: keep the MaxBackups newest rotated files
+func (f *File) prune() {
+	if f.MaxBackups <= 0 {
+		return
+	}
+	backups := f.Backups()
+	for len(backups) > f.MaxBackups {
+		if errRemove := os.Remove(backups[0]); errRemove != nil {
+			fmt.Fprintf(os.Stderr, "Unable to remove the log file %s: %s\n", backups[0], errRemove)
+		}
+		backups = backups[1:]
+	}
+}
+
+// compress: gzip the file to fileName.gz and remove it
+func compress(fileName string) (err error) {
+	in, errOpen := os.Open(fileName)
 	if errOpen != nil {
-		panic(fmt.Sprintf("Unable to open file for writing: %s", errOpen))
+		return errOpen
+	}
+	defer in.Close()
+	tmpName := fileName + ".gz.tmp"
+	out, errCreate := os.Create(tmpName)
+	if errCreate != nil {
+		return errCreate
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmpName)
+		}
+	}()
+	zw := gzip.NewWriter(out)
+	if _, err = io.Copy(zw, in); err != nil {
+		out.Close()
+		return
+	}
+	if err = zw.Close(); err != nil {
+		out.Close()
+		return
 	}
-	defer file.Close()
-	if _, errWrite := file.WriteString(fmt.Sprintf("%s\n", msg)); errWrite != nil {
-		panic(fmt.Sprintf("Unable to write to file: %s", errWrite))
+	if err = out.Close(); err != nil {
+		return
 	}
+	if err = os.Rename(tmpName, fileName+".gz"); err != nil {
+		return
+	}
+	return os.Remove(fileName)
+}
+
+func exists(fileName string) bool {
+	_, errStat := os.Stat(fileName)
+	return errStat == nil
 }
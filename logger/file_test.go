@@ -0,0 +1,134 @@
+package loggers
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keenfury/axenda/clock"
+	"github.com/keenfury/axenda/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileRotateSize(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2020-04-23T12:22:00-06:00")
+	fake := clock.NewFake(start)
+	util.Clock = fake
+	defer func() { util.Clock = clock.Real{} }()
+	dir, _ := ioutil.TempDir("", "logger_test")
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "axenda.log")
+	f := &File{FileName: fileName, MaxSize: 20, MaxBackups: 2, Compress: true}
+	for i := 0; i < 4; i++ {
+		_, err := f.Write([]byte("0123456789abcdef\n"))
+		assert.Nil(t, err)
+		fake.Add(time.Second)
+	}
+	assert.Nil(t, f.Close())
+	backups := f.Backups()
+	assert.Equal(t, 2, len(backups), "Expected the oldest backup to be removed")
+	for _, backup := range backups {
+		assert.True(t, strings.HasSuffix(backup, ".gz"), "Expected the backups to be compressed")
+	}
+	assert.True(t, strings.HasSuffix(backups[1], "2020-04-23T12-22-03.gz"))
+	content, _ := ioutil.ReadFile(fileName)
+	assert.Equal(t, "0123456789abcdef\n", string(content), "Expected the last entry in the current file")
+}
+
+func TestFileRotateEvery(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2020-04-23T12:22:00-06:00")
+	fake := clock.NewFake(start)
+	util.Clock = fake
+	defer func() { util.Clock = clock.Real{} }()
+	dir, _ := ioutil.TempDir("", "logger_test")
+	defer os.RemoveAll(dir)
+	f := &File{FileName: filepath.Join(dir, "axenda.log"), RotateEvery: time.Hour}
+	f.Write([]byte("first\n"))
+	fake.Add(30 * time.Minute)
+	f.Write([]byte("second\n"))
+	assert.Equal(t, 0, len(f.Backups()))
+	fake.Add(30 * time.Minute)
+	f.Write([]byte("third\n"))
+	f.Close()
+	backups := f.Backups()
+	assert.Equal(t, 1, len(backups))
+	content, _ := ioutil.ReadFile(backups[0])
+	assert.Equal(t, "first\nsecond\n", string(content))
+}
+
+func TestFileReopen(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "logger_test")
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "axenda.log")
+	f := &File{FileName: fileName}
+	f.Write([]byte("before\n"))
+	// logrotate moves the file away then sends SIGHUP
+	os.Rename(fileName, fileName+".1")
+	assert.Nil(t, f.Reopen())
+	f.Write([]byte("after\n"))
+	f.Close()
+	moved, _ := ioutil.ReadFile(fileName + ".1")
+	assert.Equal(t, "before\n", string(moved))
+	content, _ := ioutil.ReadFile(fileName)
+	assert.Equal(t, "after\n", string(content))
+}
+
+func TestFileWriteFailure(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "logger_test")
+	defer os.RemoveAll(dir)
+	f := &File{FileName: filepath.Join(dir, "missing", "axenda.log")}
+	lg := &Logger{Level: LevelInfo, Encoder: TextEncoder{}, Out: f}
+	assert.NotPanics(t, func() { lg.Info("to stderr") })
+	_, err := f.Write([]byte("entry\n"))
+	assert.NotNil(t, err, "Error expected")
+}
+
+func TestFileFlushFailureToStderr(t *testing.T) {
+	var out bytes.Buffer
+	stderr = &out
+	defer func() { stderr = os.Stderr }()
+	dir, _ := ioutil.TempDir("", "logger_test")
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "axenda.log")
+	f := &File{FileName: fileName, FlushInterval: time.Hour}
+	_, err := f.Write([]byte("buffered\n"))
+	assert.Nil(t, err)
+	// the file fails under the buffer
+	f.file.Close()
+	assert.NotNil(t, f.Flush(), "Error expected")
+	assert.Contains(t, out.String(), "buffered\n", "Expected the buffer written to stderr")
+	_, err = f.Write([]byte("after\n"))
+	assert.Nil(t, err, "Expected the file to be opened again")
+	assert.Nil(t, f.Close())
+	content, _ := ioutil.ReadFile(fileName)
+	assert.Equal(t, "after\n", string(content))
+}
+
+func TestFileRotateRenameFailure(t *testing.T) {
+	var out bytes.Buffer
+	stderr = &out
+	rename = func(from, to string) error { return fmt.Errorf("Rename failure") }
+	defer func() { stderr = os.Stderr; rename = os.Rename }()
+	dir, _ := ioutil.TempDir("", "logger_test")
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "axenda.log")
+	f := &File{FileName: fileName, MaxSize: 20, FlushInterval: time.Hour}
+	for i := 0; i < 3; i++ {
+		_, err := f.Write([]byte(fmt.Sprintf("0123456789abcde%d\n", i)))
+		assert.Nil(t, err, "Expected to keep appending")
+	}
+	assert.Equal(t, 2, strings.Count(out.String(), "Unable to rotate"), "Expected one attempt per rotation due")
+	rename = os.Rename
+	_, err := f.Write([]byte("0123456789abcde3\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+	backups := f.Backups()
+	assert.Equal(t, 1, len(backups), "Expected the rotation to work again")
+	rotated, _ := ioutil.ReadFile(backups[0])
+	assert.Equal(t, "0123456789abcde0\n0123456789abcde1\n0123456789abcde2\n", string(rotated))
+}
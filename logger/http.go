@@ -0,0 +1,236 @@
+package loggers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/keenfury/axenda/util"
+)
+
+/*
+HTTP: ships the entries in batches to a log collector (SCH_LOG_HTTP_URL), posted as json lines
+(application/x-ndjson, see JSONEncoder) with an optional bearer token (SCH_LOG_HTTP_TOKEN)
+- a batch is sent once BatchSize entries are waiting or every FlushInterval
+- a post times out after Timeout, a failed post is tried again MaxRetries times with a growing wait, then the batch
+is dropped
+- at most MaxBuffer entries wait in memory, the oldest are dropped past that (the count goes to stderr)
+
+Close sends what is left without retrying, a collector down or hung holds Close (so a reload or the shutdown) for
+one Timeout.
+*/
+
+const (
+	defaultHTTPBatchSize     = 100
+	defaultHTTPMaxBuffer     = 10000
+	defaultHTTPFlushInterval = 5 * time.Second
+	defaultHTTPMaxRetries    = 3
+	defaultHTTPTimeout       = 10 * time.Second
+)
+
+type HTTP struct {
+	URL           string
+	Token         string
+	BatchSize     int
+	MaxBuffer     int
+	FlushInterval time.Duration
+	MaxRetries    int
+	// RetryWait: the wait before the first retry, doubled for each one (defaults to 1s)
+	RetryWait time.Duration
+	// Timeout: of each post (defaults to 10s)
+	Timeout time.Duration
+
+	mu      sync.Mutex
+	pending [][]byte
+	dropped int
+	// lost: the entries dropped or not shipped since the start
+	lost    int
+	started bool
+	closed  bool
+	kickCh  chan struct{}
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+}
+
+// Write: the line as is, Logger hands the entries to WriteEntry instead
+func (hs *HTTP) Write(p []byte) (int, error) {
+	if errAdd := hs.add(append([]byte{}, p...)); errAdd != nil {
+		return 0, errAdd
+	}
+	return len(p), nil
+}
+
+func (hs *HTTP) WriteEntry(e Entry) error {
+	return hs.add(JSONEncoder{}.Encode(e))
+}
+
+// Close: send the entries waiting and stop
+func (hs *HTTP) Close() error {
+	hs.mu.Lock()
+	if hs.closed || !hs.started {
+		hs.closed = true
+		hs.mu.Unlock()
+		return nil
+	}
+	hs.closed = true
+	hs.mu.Unlock()
+	close(hs.stopCh)
+	<-hs.doneCh
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if lost := hs.lost + hs.dropped + len(hs.pending); lost > 0 {
+		return fmt.Errorf("Unable to ship %d log entries", lost)
+	}
+	return nil
+}
+
+func (hs *HTTP) add(line []byte) error {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if hs.closed {
+		return fmt.Errorf("Log shipping closed: %s", hs.URL)
+	}
+	if !hs.started {
+		hs.started = true
+		hs.kickCh = make(chan struct{}, 1)
+		hs.stopCh = make(chan struct{})
+		hs.doneCh = make(chan struct{})
+		go hs.loop()
+	}
+	hs.pending = append(hs.pending, line)
+	if over := len(hs.pending) - hs.maxBuffer(); over > 0 {
+		hs.pending = hs.pending[over:]
+		hs.dropped += over
+	}
+	if len(hs.pending) >= hs.batchSize() {
+		select {
+		case hs.kickCh <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (hs *HTTP) loop() {
+	defer close(hs.doneCh)
+	interval := hs.FlushInterval
+	if interval <= 0 {
+		interval = defaultHTTPFlushInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-hs.stopCh:
+			for hs.ship(false) {
+			}
+			return
+		case <-hs.kickCh:
+			for hs.ship(true) {
+			}
+		case <-ticker.C:
+			for hs.ship(true) {
+			}
+		}
+	}
+}
+
+// ship: send one batch, true if more are waiting
+func (hs *HTTP) ship(retry bool) bool {
+	hs.mu.Lock()
+	if hs.dropped > 0 {
+		fmt.Fprintf(os.Stderr, "Log shipping buffer full, %d entries dropped\n", hs.dropped)
+		hs.lost += hs.dropped
+		hs.dropped = 0
+	}
+	n := len(hs.pending)
+	if n == 0 {
+		hs.mu.Unlock()
+		return false
+	}
+	if n > hs.batchSize() {
+		n = hs.batchSize()
+	}
+	batch := hs.pending[:n]
+	hs.mu.Unlock()
+
+	errPost := hs.post(batch)
+	wait := hs.RetryWait
+	if wait <= 0 {
+		wait = time.Second
+	}
+	retries := hs.MaxRetries
+	if retries <= 0 {
+		retries = defaultHTTPMaxRetries
+	}
+	if !retry {
+		retries = 0
+	}
+	for retry := 0; errPost != nil && retry < retries; retry++ {
+		time.Sleep(wait)
+		wait *= 2
+		errPost = hs.post(batch)
+	}
+	if errPost != nil {
+		fmt.Fprintf(os.Stderr, "Unable to ship %d log entries, dropped: %s\n", len(batch), errPost)
+	}
+
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	// entries dropped from the front while posting were part of the batch
+	if sent := len(batch) - hs.dropped; sent > 0 {
+		if errPost != nil {
+			hs.lost += sent
+		}
+		hs.pending = hs.pending[sent:]
+	}
+	return len(hs.pending) > 0 && errPost == nil
+}
+
+func (hs *HTTP) post(batch [][]byte) error {
+	timeout := hs.Timeout
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodPost, hs.URL, bytes.NewReader(bytes.Join(batch, nil)))
+	if errReq != nil {
+		return errReq
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if len(hs.Token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+hs.Token)
+	}
+	client, errClient := util.HTTPClient()
+	if errClient != nil {
+		return errClient
+	}
+	resp, errDo := client.Do(req)
+	if errDo != nil {
+		return errDo
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Unexpected code: %d, reason: %s", resp.StatusCode, resp.Status)
+	}
+	return nil
+}
+
+func (hs *HTTP) batchSize() int {
+	if hs.BatchSize > 0 {
+		return hs.BatchSize
+	}
+	return defaultHTTPBatchSize
+}
+
+func (hs *HTTP) maxBuffer() int {
+	if hs.MaxBuffer > 0 {
+		return hs.MaxBuffer
+	}
+	return defaultHTTPMaxBuffer
+}
@@ -0,0 +1,288 @@
+package loggers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/keenfury/axenda/util"
+)
+
+/*
+Leveled, structured logging, a message with key/value fields:
+
+	loggers.Info("RunJobs: job done", "token", job.Token, "job_name", job.JobName, "runner", "API", "attempt", 1)
+
+text (SCH_LOG_FORMAT=text, the default):
+
+	2020-04-23T12:22:00-06:00 INFO RunJobs: job done token=TOKEN job_name="Nightly report" runner=API attempt=1
+
+json (SCH_LOG_FORMAT=json), one object per line, the fields are top level keys to index on:
+
+	{"time":"2020-04-23T12:22:00-06:00","level":"info","msg":"RunJobs: job done","token":"TOKEN","job_name":"Nightly report","runner":"API","attempt":1}
+
+Entries below the level (SCH_LOG_LEVEL: debug, info, warn or error) are dropped.  Every package logs through the
+package level functions (Debug, Info, Warn, Error) to Current, main sets the Logger behind it from the configuration.
+The usual field keys: token, job_name, runner, attempt, run_id, workflow_run, error.
+*/
+
+type (
+	Level int
+
+	Entry struct {
+		Time    time.Time
+		Level   Level
+		Message string
+		// Fields: key, value, key, value...
+		Fields []interface{}
+	}
+
+	Encoder interface {
+		Encode(Entry) []byte
+	}
+
+	TextEncoder struct{}
+
+	JSONEncoder struct{}
+
+	// EntryWriter: an Out taking the entries as they are instead of the encoded lines (Syslog, HTTP)
+	EntryWriter interface {
+		WriteEntry(Entry) error
+	}
+
+	// Logger: encodes the entries at or above Level to Out
+	Logger struct {
+		Level   Level
+		Encoder Encoder
+		Out     io.Writer
+
+		mu sync.Mutex
+	}
+
+	// Switch: the Logger every package logs to, swapped on reload while in use
+	Switch struct {
+		mu     sync.RWMutex
+		logger *Logger
+	}
+)
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// Current: where the package level functions log to, stdout and text until Set
+var Current = &Switch{logger: &Logger{Level: LevelInfo, Encoder: TextEncoder{}, Out: &StdOut{}}}
+
+func (lv Level) String() string {
+	switch lv {
+	case LevelDebug:
+		return "debug"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return "info"
+}
+
+// ParseLevel: the level by its name, empty is info
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return LevelDebug, nil
+	case "", "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("Unknown log level: %q", name)
+}
+
+// Log: write the entry if the level is enabled, on a failed write the entry goes to stderr
+func (lg *Logger) Log(level Level, msg string, kv ...interface{}) {
+	if level < lg.Level {
+		return
+	}
+	entry := Entry{Time: util.GetNow(), Level: level, Message: strings.TrimSpace(msg), Fields: kv}
+	if entryWriter, ok := lg.Out.(EntryWriter); ok {
+		if errWrite := entryWriter.WriteEntry(entry); errWrite != nil {
+			os.Stderr.Write(lg.Encoder.Encode(entry))
+		}
+		return
+	}
+	line := lg.Encoder.Encode(entry)
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	if _, errWrite := lg.Out.Write(line); errWrite != nil {
+		os.Stderr.Write(line)
+	}
+}
+
+func (lg *Logger) Debug(msg string, kv ...interface{}) {
+	lg.Log(LevelDebug, msg, kv...)
+}
+
+func (lg *Logger) Info(msg string, kv ...interface{}) {
+	lg.Log(LevelInfo, msg, kv...)
+}
+
+func (lg *Logger) Warn(msg string, kv ...interface{}) {
+	lg.Log(LevelWarn, msg, kv...)
+}
+
+func (lg *Logger) Error(msg string, kv ...interface{}) {
+	lg.Log(LevelError, msg, kv...)
+}
+
+// Set: swap in the logger, the one replaced is closed if its Out is an io.Closer (e.g. a File, flushed)
+func (s *Switch) Set(logger *Logger) {
+	s.mu.Lock()
+	old := s.logger
+	s.logger = logger
+	s.mu.Unlock()
+	if old != nil && old.Out != logger.Out {
+		if closer, ok := old.Out.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+}
+
+// Reopen: reopen the log file if the logger writes to one, e.g. on SIGHUP after logrotate
+func (s *Switch) Reopen() error {
+	if reopener, ok := s.Get().Out.(interface{ Reopen() error }); ok {
+		return reopener.Reopen()
+	}
+	return nil
+}
+
+// Close: flush and close the log output (file, syslog, http) if it has to be, call it before exiting
+func (s *Switch) Close() error {
+	if closer, ok := s.Get().Out.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Get: the logger in use
+func (s *Switch) Get() *Logger {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.logger
+}
+
+func (s *Switch) Debug(msg string, kv ...interface{}) {
+	s.Get().Log(LevelDebug, msg, kv...)
+}
+
+func (s *Switch) Info(msg string, kv ...interface{}) {
+	s.Get().Log(LevelInfo, msg, kv...)
+}
+
+func (s *Switch) Warn(msg string, kv ...interface{}) {
+	s.Get().Log(LevelWarn, msg, kv...)
+}
+
+func (s *Switch) Error(msg string, kv ...interface{}) {
+	s.Get().Log(LevelError, msg, kv...)
+}
+
+func Debug(msg string, kv ...interface{}) {
+	Current.Debug(msg, kv...)
+}
+
+func Info(msg string, kv ...interface{}) {
+	Current.Info(msg, kv...)
+}
+
+func Warn(msg string, kv ...interface{}) {
+	Current.Warn(msg, kv...)
+}
+
+func Error(msg string, kv ...interface{}) {
+	Current.Error(msg, kv...)
+}
+
+// Encode: time LEVEL message key=value..., values with spaces or quotes are quoted
+func (TextEncoder) Encode(e Entry) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(e.Time.Format(time.RFC3339))
+	buf.WriteByte(' ')
+	buf.WriteString(strings.ToUpper(e.Level.String()))
+	buf.WriteByte(' ')
+	buf.WriteString(e.Message)
+	for _, field := range pairs(e.Fields) {
+		value := fmt.Sprint(field.value)
+		if len(value) == 0 || strings.ContainsAny(value, " \t\n\"=") {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(&buf, " %s=%s", field.key, value)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// Encode: a json object on one line, time, level and msg first then the fields in order
+func (JSONEncoder) Encode(e Entry) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	writeJSON(&buf, "time", e.Time.Format(time.RFC3339))
+	buf.WriteByte(',')
+	writeJSON(&buf, "level", e.Level.String())
+	buf.WriteByte(',')
+	writeJSON(&buf, "msg", e.Message)
+	for _, field := range pairs(e.Fields) {
+		buf.WriteByte(',')
+		writeJSON(&buf, field.key, field.value)
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+// pairs: the fields as key/value, errors and times as strings, a key without its value gets "!MISSING"
+func pairs(kv []interface{}) (fields []field) {
+	for i := 0; i < len(kv); i += 2 {
+		f := field{key: fmt.Sprint(kv[i]), value: "!MISSING"}
+		if i+1 < len(kv) {
+			f.value = kv[i+1]
+		}
+		switch v := f.value.(type) {
+		case error:
+			f.value = v.Error()
+		case time.Time:
+			f.value = v.Format(time.RFC3339)
+		case time.Duration:
+			f.value = v.String()
+		case fmt.Stringer:
+			f.value = v.String()
+		}
+		fields = append(fields, f)
+	}
+	return
+}
+
+func writeJSON(buf *bytes.Buffer, key string, value interface{}) {
+	bKey, _ := json.Marshal(key)
+	bValue, errMarshal := json.Marshal(value)
+	if errMarshal != nil {
+		bValue, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buf.Write(bKey)
+	buf.WriteByte(':')
+	buf.Write(bValue)
+}
@@ -0,0 +1,36 @@
+package loggers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTextEncoder(t *testing.T) {
+	at, _ := time.Parse(time.RFC3339, "2020-04-23T12:22:00-06:00")
+	line := TextEncoder{}.Encode(Entry{Time: at, Level: LevelWarn, Message: "RunJobs: done", Fields: []interface{}{"token", "TOKENLOG", "job_name", "Nightly report", "error", fmt.Errorf("boom"), "attempt"}})
+	assert.Equal(t, "2020-04-23T12:22:00-06:00 WARN RunJobs: done token=TOKENLOG job_name=\"Nightly report\" error=boom attempt=!MISSING\n", string(line))
+}
+
+func TestJSONEncoder(t *testing.T) {
+	at, _ := time.Parse(time.RFC3339, "2020-04-23T12:22:00-06:00")
+	line := JSONEncoder{}.Encode(Entry{Time: at, Level: LevelInfo, Message: "RunJobs: done", Fields: []interface{}{"token", "TOKENLOG", "attempt", 2}})
+	assert.Equal(t, `{"time":"2020-04-23T12:22:00-06:00","level":"info","msg":"RunJobs: done","token":"TOKENLOG","attempt":2}`+"\n", string(line))
+	entry := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(line, &entry), "Expected valid json")
+}
+
+func TestLoggerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	lg := &Logger{Level: LevelWarn, Encoder: JSONEncoder{}, Out: &buf}
+	lg.Info("dropped")
+	lg.Error("kept", "token", "TOKENLOG")
+	assert.Contains(t, buf.String(), `"level":"error","msg":"kept","token":"TOKENLOG"`)
+	assert.NotContains(t, buf.String(), "dropped")
+	_, err := ParseLevel("verbose")
+	assert.NotNil(t, err, "Error expected")
+}
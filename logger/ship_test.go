@@ -0,0 +1,140 @@
+package loggers
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyslogUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer conn.Close()
+	s := &Syslog{Addr: "udp://" + conn.LocalAddr().String(), AppName: "axenda-test"}
+	defer s.Close()
+	when, _ := time.Parse(time.RFC3339, "2020-04-23T12:22:00-06:00")
+	assert.Nil(t, s.WriteEntry(Entry{Time: when, Level: LevelError, Message: "RunJobs: job failed", Fields: []interface{}{"token", "NIGHTLY", "error", `bad "quote"]`}}))
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	assert.Nil(t, err)
+	msg := string(buf[:n])
+	assert.True(t, strings.HasPrefix(msg, "<27>1 2020-04-23T12:22:00.000000-06:00 "), "Expected daemon.err and the timestamp: %s", msg)
+	assert.Contains(t, msg, " axenda-test ")
+	assert.True(t, strings.HasSuffix(msg, `[axenda@32473 token="NIGHTLY" error="bad \"quote\"\]"] RunJobs: job failed`), msg)
+}
+
+func TestSyslogTCPFraming(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, errAccept := listener.Accept()
+		if errAccept != nil {
+			return
+		}
+		defer conn.Close()
+		length, _ := bufio.NewReader(conn).ReadString(' ')
+		received <- length
+	}()
+	s := &Syslog{Addr: "tcp://" + listener.Addr().String()}
+	defer s.Close()
+	assert.Nil(t, s.WriteEntry(Entry{Time: time.Now(), Level: LevelInfo, Message: "hello"}))
+	select {
+	case length := <-received:
+		assert.Regexp(t, `^\d+ $`, length, "Expected the octet count first")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a message")
+	}
+}
+
+func TestHTTPRetryAndBatch(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	bodies := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		bodies = append(bodies, string(body))
+		assert.Equal(t, "Bearer secret", req.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+	hs := &HTTP{URL: server.URL, Token: "secret", BatchSize: 2, FlushInterval: time.Hour, RetryWait: time.Millisecond}
+	for _, msg := range []string{"one", "two", "three"} {
+		assert.Nil(t, hs.WriteEntry(Entry{Time: time.Now(), Level: LevelInfo, Message: msg}))
+	}
+	// the full batch is retried, close doesn't retry
+	for i := 0; i < 100; i++ {
+		mu.Lock()
+		sent := len(bodies)
+		mu.Unlock()
+		if sent == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Nil(t, hs.Close(), "Expected the last entry sent on close")
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 3, calls, "Expected one failed post retried and the remainder on close")
+	assert.Equal(t, 2, len(bodies))
+	assert.Equal(t, 2, strings.Count(bodies[0], "\n"), "Expected a batch of 2 json lines")
+	assert.Contains(t, bodies[1], `"msg":"three"`)
+}
+
+func TestSyslogDownNotBlocking(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+	s := &Syslog{Addr: "tcp://" + addr, Timeout: 50 * time.Millisecond}
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, s.WriteEntry(Entry{Time: time.Now(), Level: LevelInfo, Message: "hello"}), "Expected the entry queued")
+	}
+	assert.True(t, time.Since(start) < 50*time.Millisecond, "Expected the writes not to wait for the server")
+	assert.NotNil(t, s.Close(), "Expected the entries not sent reported")
+}
+
+func TestHTTPTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	hs := &HTTP{URL: server.URL, FlushInterval: time.Hour, Timeout: 50 * time.Millisecond}
+	assert.Nil(t, hs.WriteEntry(Entry{Time: time.Now(), Level: LevelInfo, Message: "hello"}))
+	start := time.Now()
+	assert.NotNil(t, hs.Close(), "Expected the entry not shipped reported")
+	assert.True(t, time.Since(start) < 2*time.Second, "Expected a hung collector not to hold close")
+}
+
+func TestHTTPBufferBounded(t *testing.T) {
+	hs := &HTTP{URL: "http://127.0.0.1:1", BatchSize: 100, MaxBuffer: 3, FlushInterval: time.Hour, MaxRetries: 1, RetryWait: time.Millisecond}
+	for _, msg := range []string{"one", "two", "three", "four", "five"} {
+		hs.WriteEntry(Entry{Time: time.Now(), Level: LevelInfo, Message: msg})
+	}
+	hs.mu.Lock()
+	pending := len(hs.pending)
+	first := string(hs.pending[0])
+	hs.mu.Unlock()
+	assert.Equal(t, 3, pending, "Expected the oldest entries dropped")
+	assert.Contains(t, first, `"msg":"three"`)
+	assert.NotNil(t, hs.Close(), "Expected the entries not shipped reported")
+}
@@ -1,9 +1,10 @@
 package loggers
 
-import "fmt"
+import "os"
 
+// StdOut: writes the log to stdout
 type StdOut struct{}
 
-func (s *StdOut) SetMessage(msg string) {
-	fmt.Println(msg)
+func (s *StdOut) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
 }
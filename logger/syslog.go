@@ -0,0 +1,274 @@
+package loggers
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Syslog: sends each entry as an RFC 5424 message to a syslog server (SCH_LOG_SYSLOG_ADDR):
+- udp://host:514, one message per datagram
+- tcp://host:601, octet counted (RFC 6587)
+- unix:///dev/log, the local syslog socket
+
+	<30>1 2020-04-23T12:22:00.000000-06:00 host axenda 4242 - [axenda@32473 token="NIGHTLY" runner="API"] RunJobs: job done
+
+The fields of the entry are the structured data, the facility is daemon.  Like HTTP the messages are sent by a
+goroutine, a slow or down server never blocks the caller:
+- the connection is made on the first message, and made again once after a failed write
+- the dial and each write have a Timeout, once a message can't be sent the ones waiting with it are dropped
+- at most MaxBuffer messages wait in memory, the oldest are dropped past that (the count goes to stderr)
+
+Close sends what is left.
+*/
+
+const (
+	facilityDaemon = 3
+	// sdID: the structured data id, 32473 is the example enterprise number (RFC 5612)
+	sdID = "axenda@32473"
+
+	defaultSyslogMaxBuffer = 10000
+	defaultSyslogTimeout   = 5 * time.Second
+)
+
+type Syslog struct {
+	// Addr: udp://host:port, tcp://host:port or unix:///path
+	Addr    string
+	AppName string
+	// MaxBuffer: the messages waiting to be sent (defaults to 10000)
+	MaxBuffer int
+	// Timeout: of the dial and of each write (defaults to 5s)
+	Timeout time.Duration
+
+	mu       sync.Mutex
+	pending  []string
+	dropped  int
+	lost     int
+	started  bool
+	closed   bool
+	kickCh   chan struct{}
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	hostname string
+	// conn, stream: only used by the loop
+	conn   net.Conn
+	stream bool
+}
+
+// Write: the line as an info message, Logger hands the entries to WriteEntry instead
+func (s *Syslog) Write(p []byte) (int, error) {
+	errWrite := s.WriteEntry(Entry{Time: time.Now(), Level: LevelInfo, Message: strings.TrimSpace(string(p))})
+	if errWrite != nil {
+		return 0, errWrite
+	}
+	return len(p), nil
+}
+
+func (s *Syslog) WriteEntry(e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return fmt.Errorf("Syslog closed: %s", s.Addr)
+	}
+	if !s.started {
+		s.started = true
+		s.kickCh = make(chan struct{}, 1)
+		s.stopCh = make(chan struct{})
+		s.doneCh = make(chan struct{})
+		go s.loop()
+	}
+	s.pending = append(s.pending, s.format(e))
+	if over := len(s.pending) - s.maxBuffer(); over > 0 {
+		s.pending = s.pending[over:]
+		s.dropped += over
+	}
+	select {
+	case s.kickCh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Close: send the messages waiting and stop
+func (s *Syslog) Close() error {
+	s.mu.Lock()
+	if s.closed || !s.started {
+		s.closed = true
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+	close(s.stopCh)
+	<-s.doneCh
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if lost := s.lost + s.dropped + len(s.pending); lost > 0 {
+		return fmt.Errorf("Unable to send %d log entries to syslog", lost)
+	}
+	return nil
+}
+
+func (s *Syslog) loop() {
+	defer close(s.doneCh)
+	defer func() {
+		if s.conn != nil {
+			s.conn.Close()
+		}
+	}()
+	for {
+		select {
+		case <-s.stopCh:
+			s.send()
+			return
+		case <-s.kickCh:
+			s.send()
+		}
+	}
+}
+
+// send: write the messages waiting, the rest of them are dropped once one fails
+func (s *Syslog) send() {
+	s.mu.Lock()
+	if s.dropped > 0 {
+		fmt.Fprintf(os.Stderr, "Syslog buffer full, %d entries dropped\n", s.dropped)
+		s.lost += s.dropped
+		s.dropped = 0
+	}
+	msgs := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+	for i, msg := range msgs {
+		if errWrite := s.write(msg); errWrite != nil {
+			fmt.Fprintf(os.Stderr, "Unable to send %d log entries to syslog, dropped: %s\n", len(msgs)-i, errWrite)
+			s.mu.Lock()
+			s.lost += len(msgs) - i
+			s.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (s *Syslog) write(msg string) error {
+	var errWrite error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if errWrite = s.connect(); errWrite != nil {
+				continue
+			}
+		}
+		s.conn.SetWriteDeadline(time.Now().Add(s.timeout()))
+		if s.stream {
+			_, errWrite = fmt.Fprintf(s.conn, "%d %s", len(msg), msg)
+		} else {
+			_, errWrite = s.conn.Write([]byte(msg))
+		}
+		if errWrite == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return errWrite
+}
+
+func (s *Syslog) connect() (err error) {
+	u, errParse := url.Parse(s.Addr)
+	if errParse != nil {
+		return errParse
+	}
+	timeout := s.timeout()
+	switch u.Scheme {
+	case "udp":
+		s.conn, err = net.DialTimeout("udp", u.Host, timeout)
+		s.stream = false
+	case "tcp":
+		s.conn, err = net.DialTimeout("tcp", u.Host, timeout)
+		s.stream = true
+	case "unix":
+		// the local socket is a datagram one most of the time
+		if s.conn, err = net.DialTimeout("unixgram", u.Path, timeout); err != nil {
+			s.conn, err = net.DialTimeout("unix", u.Path, timeout)
+			s.stream = true
+			return
+		}
+		s.stream = false
+	default:
+		err = fmt.Errorf("Unknown syslog address: %q, use udp://, tcp:// or unix://", s.Addr)
+	}
+	return
+}
+
+func (s *Syslog) maxBuffer() int {
+	if s.MaxBuffer > 0 {
+		return s.MaxBuffer
+	}
+	return defaultSyslogMaxBuffer
+}
+
+func (s *Syslog) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return defaultSyslogTimeout
+}
+
+// format: the RFC 5424 message of the entry
+func (s *Syslog) format(e Entry) string {
+	if len(s.hostname) == 0 {
+		s.hostname, _ = os.Hostname()
+		if len(s.hostname) == 0 {
+			s.hostname = "-"
+		}
+	}
+	appName := s.AppName
+	if len(appName) == 0 {
+		appName = "axenda"
+	}
+	structured := "-"
+	if fields := pairs(e.Fields); len(fields) > 0 {
+		params := make([]string, 0, len(fields))
+		for _, field := range fields {
+			params = append(params, fmt.Sprintf("%s=\"%s\"", sdName(field.key), sdEscape(fmt.Sprint(field.value))))
+		}
+		structured = fmt.Sprintf("[%s %s]", sdID, strings.Join(params, " "))
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s %d - %s %s", facilityDaemon*8+severity(e.Level), e.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		s.hostname, appName, os.Getpid(), structured, e.Message)
+}
+
+func severity(level Level) int {
+	switch level {
+	case LevelDebug:
+		return 7
+	case LevelWarn:
+		return 4
+	case LevelError:
+		return 3
+	}
+	return 6
+}
+
+// sdName: printable ascii without '=', ' ', ']' and '"', at most 32 characters
+func sdName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if r <= 32 || r >= 127 || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, key)
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	return name
+}
+
+// sdEscape: '"', '\' and ']' are escaped in a param value
+func sdEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
@@ -4,15 +4,25 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/keenfury/axenda/config"
 	d "github.com/keenfury/axenda/discovery"
+	h "github.com/keenfury/axenda/history"
 	j "github.com/keenfury/axenda/job"
 	l "github.com/keenfury/axenda/logger"
+	"github.com/keenfury/axenda/metrics"
 	r "github.com/keenfury/axenda/runner"
+	"github.com/keenfury/axenda/tracing"
 	"github.com/keenfury/axenda/util"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type (
@@ -23,8 +33,22 @@ type (
 		CompleteJob(j.Job, chan<- j.Job) error
 	}
 
+	// Watcher: a discovery that can tell when its jobs changed, they are checked right away instead of on the next tick
+	Watcher interface {
+		Watch(chan<- struct{}) (func(), error)
+	}
+
 	LogAdapter interface {
-		SetMessage(string)
+		Debug(string, ...interface{})
+		Info(string, ...interface{})
+		Warn(string, ...interface{})
+		Error(string, ...interface{})
+	}
+
+	HistoryAdapter interface {
+		WhichHistory() string
+		Save(h.Record) error
+		List(string, time.Time, time.Time) ([]h.Record, error)
 	}
 )
 
@@ -33,75 +57,276 @@ var (
 	JobArrayCh       chan j.Job
 	JobUpdateCh      chan j.Job
 	JobRemoveCh      chan j.Job
-	discoveryAdapter = SetDiscoveryAdapter()
-	logAdapter       = SetLoggingAdapter()
+	JobListCh        chan chan []j.Job
+	runnerAdapter    r.RunnerAdapter
+	discoveryAdapter DiscoveryAdapter
+	logAdapter       LogAdapter = l.Current
+	historyAdapter   HistoryAdapter
+	// dispatchSlots: one per job allowed to run at once (SCH_CONCURRENCY), nil is no limit
+	dispatchSlots chan struct{}
 )
 
 func main() {
-	logAdapter.SetMessage(fmt.Sprintf("Using discovery: %s\n", discoveryAdapter.WhichDiscovery()))
+	errCmd := RunCommand(os.Args[1:])
+	// flush the spans waiting and the buffered log file
+	if errShutdown := shutdownTracing(context.Background()); errShutdown != nil {
+		logAdapter.Warn("Tracing: unable to flush spans", "error", errShutdown)
+	}
+	l.Current.Close()
+	if errCmd != nil {
+		fmt.Fprintln(os.Stderr, errCmd)
+		os.Exit(1)
+	}
+}
+
+// Setup: load and validate the configuration (file and environment variables) then set the adapters
+func Setup() (err error) {
+	if err = config.Load(config.ConfigFile); err != nil {
+		return
+	}
+	l.Current.Set(SetLoggingAdapter())
+	runner := SetRunnerAdapter()
+	discovery, errDiscovery := SetDiscoveryAdapter(runner)
+	if errDiscovery != nil {
+		err = errDiscovery
+		return
+	}
+	SetAdapters(runner, discovery, SetHistoryAdapter())
+	SetDispatchSlots()
+	SetNotifier()
+	SetAuditAdapter()
+	err = SetTracing()
+	return
+}
+
+// shutdownTracing: flushes the spans of the exporter set by SetTracing
+var shutdownTracing = func(context.Context) error { return nil }
+
+// SetTracing: the exporter of SCH_TRACE_EXPORTER, set once, the SCH_TRACE_* settings need a restart
+func SetTracing() error {
+	shutdown, errSetup := tracing.Setup(config.TraceExporter, config.GetTraceOTLPEndpoint(), config.TraceOTLPInsecure == "true")
+	if errSetup != nil {
+		return errSetup
+	}
+	shutdownTracing = shutdown
+	if len(config.TraceExporter) > 0 {
+		logAdapter.Info("Using trace exporter", "exporter", config.TraceExporter)
+	}
+	return nil
+}
+
+// SetDispatchSlots: limit the jobs running at once to SCH_CONCURRENCY, the jobs already running keep their slot
+func SetDispatchSlots() {
+	slots := make(chan struct{}, config.GetConcurrency())
+	if cap(slots) == 0 {
+		slots = nil
+	}
+	adapterMu.Lock()
+	defer adapterMu.Unlock()
+	dispatchSlots = slots
+}
+
+// Run: the scheduler's loop, checks for jobs every minute
+func Run() {
+	logAdapter.Info("Using discovery", "discovery", CurrentDiscovery().WhichDiscovery())
+	logAdapter.Info("Using history", "history", CurrentHistory().WhichHistory())
 	jobs = []j.Job{}
 	JobUpdateCh = make(chan j.Job)
-	minuteTicker := time.NewTicker(time.Minute)
+	JobRemoveCh = make(chan j.Job)
+	JobListCh = make(chan chan []j.Job)
+	if len(config.MetricsAddr) > 0 {
+		HandleHTTP(config.MetricsAddr, "/metrics", metrics.Handler())
+	}
+	if len(config.HealthAddr) > 0 {
+		HandleHTTP(config.HealthAddr, "/healthz", http.HandlerFunc(HealthzHandler))
+		HandleHTTP(config.HealthAddr, "/readyz", http.HandlerFunc(ReadyzHandler))
+	}
+	if len(config.AdminAddr) > 0 {
+		HandleHTTP(config.AdminAddr, "/", AdminHandler())
+	}
+	if len(config.DashboardAddr) > 0 {
+		HandleHTTP(config.DashboardAddr, "/", DashboardAuthHandler())
+	}
+	StartHTTP()
+	reloadCh := make(chan struct{}, 1)
+	WatchReload(reloadCh)
+	changeCh := make(chan struct{}, 1)
+	stopWatch := WatchDiscovery(CurrentDiscovery(), changeCh)
+	minuteTicker := util.Clock.NewTicker(time.Minute)
 
 	for {
 		select {
 		case job := <-JobUpdateCh:
 			UpdateStatus(job, &jobs)
-		case t := <-minuteTicker.C:
+		case job := <-JobRemoveCh:
+			RemoveJob(job, &jobs)
+		case listCh := <-JobListCh:
+			listCh <- append([]j.Job{}, jobs...)
+		case <-reloadCh:
+			if errReload := Reload(&jobs); errReload != nil {
+				logAdapter.Error("Reload: keeping the current configuration", "error", errReload)
+				break
+			}
+			stopWatch()
+			stopWatch = WatchDiscovery(CurrentDiscovery(), changeCh)
+		case <-changeCh:
+			noSecondsTime := util.TruncateTimeToMinute(util.GetNow())
+			ctx, span := tracing.Start(context.Background(), "DiscoveryChange")
+			CheckForJobs(ctx, noSecondsTime, &jobs, CurrentDiscovery())
+			RunJobs(ctx, &jobs, CurrentDiscovery(), JobUpdateCh)
+			span.End()
+		case t := <-minuteTicker.C():
 			noSecondsTime := util.TruncateTimeToMinute(t)
-			ProcessMinute(noSecondsTime, &jobs, discoveryAdapter, JobUpdateCh)
+			ProcessMinute(noSecondsTime, &jobs, CurrentDiscovery(), JobUpdateCh)
+			go CheckSLAs(noSecondsTime)
 		}
 	}
 }
 
+// WatchDiscovery: watch the discovery if it is a Watcher, call stop before watching another
+func WatchDiscovery(discovery DiscoveryAdapter, changeCh chan<- struct{}) (stop func()) {
+	stop = func() {}
+	watcher, ok := discovery.(Watcher)
+	if !ok {
+		return
+	}
+	watchStop, errWatch := watcher.Watch(changeCh)
+	if errWatch != nil {
+		logAdapter.Error("WatchDiscovery: unable to watch", "error", errWatch)
+		return
+	}
+	logAdapter.Info("Watching discovery for changes")
+	return watchStop
+}
+
 // ProcessMinute: called by the MinuteTicker, start the process
 func ProcessMinute(t time.Time, jobs *[]j.Job, ja DiscoveryAdapter, updateCh chan<- j.Job) {
-	CheckForJobs(t, jobs, ja)
-	RunJobs(*jobs, ja, updateCh)
+	ctx, span := tracing.Start(context.Background(), "ProcessMinute", attribute.String("time", t.Format(time.RFC3339)))
+	defer span.End()
+	// t is the minute in the scheduler's zone, the tick is the actual time
+	RecordTick(util.GetNow())
+	CheckForJobs(ctx, t, jobs, ja)
+	RunJobs(ctx, jobs, ja, updateCh)
 }
 
 // CheckForJobs: called by ProcessMinute, call the adpater's GetJobs, set the Job's status to 'Received'
-func CheckForJobs(t time.Time, jobs *[]j.Job, ja DiscoveryAdapter) {
+func CheckForJobs(ctx context.Context, t time.Time, jobs *[]j.Job, ja DiscoveryAdapter) {
+	_, span := tracing.Start(ctx, "GetJobs", attribute.String("discovery", AdapterName(ja)))
 	newJobs, errGet := ja.GetJobs(t)
+	span.SetAttributes(attribute.Int("jobs", len(newJobs)))
+	tracing.End(span, errGet)
 	if errGet != nil {
-		logAdapter.SetMessage(fmt.Sprintf("CheckForJobs: %s", errGet))
+		logAdapter.Error("CheckForJobs: unable to get jobs", "discovery", AdapterName(ja), "error", errGet)
 	}
-	for _, j := range newJobs {
-		if CheckDup(j, *jobs) {
-			j.Status = "Received"
-			*jobs = append(*jobs, j)
+	metrics.JobsDiscovered.Set(float64(len(newJobs)))
+	watchdog.Track(newJobs, false, t)
+	RecordDiscovery(t, errGet)
+	for _, job := range newJobs {
+		if len(job.DependsOn) > 0 {
+			// runs within a workflow only, the graph needs a discovery listing all the jobs
+			if _, ok := ja.(JobStore); !ok {
+				logAdapter.Error("CheckForJobs: depends_on needs a discovery listing all the jobs (file or db), job ignored", "token", job.Token, "job_name", job.JobName, "discovery", ja.WhichDiscovery())
+			}
+			continue
+		}
+		if CheckDup(job, *jobs) {
+			job.SetStatus(j.StatusReceived, util.GetNow(), "")
+			*jobs = append(*jobs, job)
 		}
 	}
+	SetJobGauges(*jobs)
 }
 
 // RunJobs: called by ProcessMinute, run Job(s) if the status has been 'Received'
-// this function call the adapter's StartJob and CompleteJob
-func RunJobs(jobs []j.Job, ja DiscoveryAdapter, updateCh chan<- j.Job) {
+// this function call the adapter's StartJob and CompleteJob, a job is set 'In Process' before its goroutine starts so
+// a discovery change handled before StartJob's update does not run it again
+func RunJobs(ctx context.Context, jobs *[]j.Job, ja DiscoveryAdapter, updateCh chan<- j.Job) {
 	nowWithNoSeconds := util.TruncateTimeToMinute(util.GetNow())
-	for _, job := range jobs {
-		if job.Status == "Received" {
+	for i := range *jobs {
+		job := (*jobs)[i]
+		if job.Status == j.StatusReceived {
 			if nowWithNoSeconds.Sub(job.RunTime) >= 0 {
+				slots, ok := acquireSlot()
+				if !ok {
+					logAdapter.Warn("RunJobs: concurrency limit reached, the job waits for the next minute", "token", job.Token, "job_name", job.JobName)
+					continue
+				}
+				(*jobs)[i].SetStatus(j.StatusInProcess, util.GetNow(), "")
+				runner, history, done := acquireAdapters()
 				go func(job j.Job) {
-					if errStart := ja.StartJob(job, updateCh); errStart != nil {
-						logAdapter.SetMessage(fmt.Sprintf("RunJobs: %s", errStart))
-						job.Status = fmt.Sprintf("Error: %s", errStart)
+					defer done()
+					defer releaseSlot(slots)
+					// the runner reports its attempts and response on it
+					job.Result = &j.RunResult{}
+					record := h.NewRecord(job, runner.WhichRunner(), util.GetNow())
+					record.Workflow = StartWorkflow(job, record.Start)
+					var errRun error
+					attrs := []attribute.KeyValue{attribute.String("token", job.Token), attribute.String("job_name", job.JobName), attribute.String("run_id", record.RunID)}
+					startCtx, startSpan := tracing.Start(ctx, "StartJob", attrs...)
+					// the runner's span is a child of StartJob
+					job.TraceParent = tracing.TraceParent(startCtx)
+					errStart := ja.StartJob(job, updateCh)
+					tracing.End(startSpan, errStart)
+					if errStart != nil {
+						logAdapter.Error("RunJobs: unable to start job", "token", job.Token, "job_name", job.JobName, "runner", record.Runner, "run_id", record.RunID, "attempt", job.Result.Attempts, "error", errStart)
+						job.Status = j.StatusError
+						job.Error = errStart.Error()
 						updateCh <- job
+						errRun = errStart
 					}
-					if errComplete := ja.CompleteJob(job, updateCh); errComplete != nil {
-						logAdapter.SetMessage(fmt.Sprintf("CompleteJobs: %s", errComplete))
-						job.Status = fmt.Sprintf("Error: %s", errComplete)
+					_, completeSpan := tracing.Start(ctx, "CompleteJob", attrs...)
+					errComplete := ja.CompleteJob(job, updateCh)
+					tracing.End(completeSpan, errComplete)
+					if errComplete != nil {
+						logAdapter.Error("CompleteJobs: unable to complete job", "token", job.Token, "job_name", job.JobName, "runner", record.Runner, "run_id", record.RunID, "attempt", job.Result.Attempts, "error", errComplete)
+						job.Status = j.StatusError
+						job.Error = errComplete.Error()
 						updateCh <- job
+						if errRun == nil {
+							errRun = errComplete
+						}
+					}
+					record.Finish(util.GetNow(), errRun)
+					record.SetResult(job.Result)
+					metrics.ObserveDispatch(record.Runner, record.End.Sub(record.Start), record.Start.Sub(job.RunTime))
+					logAdapter.Debug("RunJobs: job done", "token", job.Token, "job_name", job.JobName, "runner", record.Runner, "run_id", record.RunID, "attempt", record.Attempt, "result", record.Result)
+					if errSave := history.Save(record); errSave != nil {
+						logAdapter.Error("RunJobs: unable to save history", "token", job.Token, "run_id", record.RunID, "error", errSave)
 					}
+					FinishWorkflowJob(record.Workflow, job.Token, errRun)
+					NotifyRun(job, errRun)
+					RunHooks(job, errRun, nil)
 				}(job)
 			}
 		}
 	}
+	SetJobGauges(*jobs)
+}
+
+// acquireSlot: false when SCH_CONCURRENCY jobs are already running, the slots are handed back to releaseSlot
+func acquireSlot() (slots chan struct{}, ok bool) {
+	adapterMu.RLock()
+	slots = dispatchSlots
+	adapterMu.RUnlock()
+	if slots == nil {
+		return nil, true
+	}
+	select {
+	case slots <- struct{}{}:
+		return slots, true
+	default:
+		return nil, false
+	}
+}
+
+func releaseSlot(slots chan struct{}) {
+	if slots != nil {
+		<-slots
+	}
 }
 
-// SetDiscoveryAdapter: look through the environment variables to deterimine with adapter to use.
-// Order of precedence: file, db, api, grpc and then the failsafe mock.
-func SetDiscoveryAdapter() DiscoveryAdapter {
-	// check runners first so we can use them below
+// SetRunnerAdapter: look through the environment variables to determine which runner to use, the failsafe is mock
+func SetRunnerAdapter() r.RunnerAdapter {
 	var runner r.RunnerAdapter
 	if config.UseRunnerAPI == "true" {
 		runner = &r.API{}
@@ -112,54 +337,190 @@ func SetDiscoveryAdapter() DiscoveryAdapter {
 	if runner == nil {
 		runner = &r.Mock{}
 	}
-	// now check adapters
-	// check for local file
-	if len(config.JobFileName) > 0 {
-		return &d.File{FileName: config.JobFileName, Runner: runner}
+	return SetBreaker(runner)
+}
+
+// SetDiscoveryAdapter: the discovery of SCH_DISCOVERY, if not set the order of precedence is: file, db, api and grpc.
+// The mock is only used when SCH_DISCOVERY is "mock" (see config.Validate), a list e.g. "file,db" merges the jobs
+// of each with a Composite, the tokens are then namespaced by the discovery e.g. "db:TOKEN1"
+func SetDiscoveryAdapter(runner r.RunnerAdapter) (DiscoveryAdapter, error) {
+	types := config.DiscoveryTypes()
+	if len(types) == 0 {
+		return nil, fmt.Errorf("No discovery configured")
+	}
+	if len(types) == 1 {
+		return NewDiscovery(types[0], runner)
 	}
-	// check for DB
-	if len(config.DBHost) > 0 { // will just check host
+	composite := &d.Composite{}
+	for _, kind := range types {
+		discovery, errDiscovery := NewDiscovery(kind, runner)
+		if errDiscovery != nil {
+			composite.Close()
+			return nil, errDiscovery
+		}
+		composite.Sources = append(composite.Sources, d.Source{Name: kind, Discovery: discovery})
+	}
+	return composite, nil
+}
+
+// NewDiscovery: the discovery adapter of the kind (see config.DiscoveryFile...)
+func NewDiscovery(kind string, runner r.RunnerAdapter) (DiscoveryAdapter, error) {
+	switch kind {
+	case config.DiscoveryFile:
+		return &d.File{FileName: config.JobFileName, StateFileName: config.JobStateFileName, Runner: runner}, nil
+	case config.DiscoveryDB:
 		db := d.DB{Runner: runner}
-		db.Connect()
-		return &db
+		if errConnect := db.Connect(); errConnect != nil {
+			return nil, errConnect
+		}
+		return &db, nil
+	case config.DiscoveryAPI:
+		return &d.API{Runner: runner}, nil
+	case config.DiscoveryGRPC:
+		return &d.GRPC{URL: config.GRPCUrl, Runner: runner}, nil
+	case config.DiscoveryMock:
+		return &d.Mock{Runner: runner}, nil
 	}
-	// check for API
-	if len(config.APIGetUrl) > 0 { // will just check get url
-		return &d.API{Runner: runner}
+	return nil, fmt.Errorf("Unknown discovery: %s", kind)
+}
+
+// SetBreaker: wraps the runner with a circuit breaker if SCH_BREAKER_THRESHOLD is set
+func SetBreaker(runner r.RunnerAdapter) r.RunnerAdapter {
+	threshold, errThreshold := strconv.Atoi(config.BreakerThreshold)
+	if errThreshold != nil || threshold < 1 {
+		return runner
 	}
-	// check for gRPC
-	if len(config.GRPCUrl) > 0 {
-		return &d.GRPC{URL: config.GRPCUrl, Runner: runner}
+	coolDown := time.Minute
+	if len(config.BreakerCoolDown) > 0 {
+		if d, errParse := time.ParseDuration(config.BreakerCoolDown); errParse == nil {
+			coolDown = d
+		}
 	}
-	// default mock
-	return &d.Mock{Runner: runner}
+	breaker := &r.Breaker{Runner: runner, Threshold: threshold, CoolDown: coolDown, OnChange: BreakerChange}
+	// a reload keeps the state of the circuits
+	if old, ok := CurrentRunner().(*r.Breaker); ok {
+		breaker.CarryOver(old)
+	}
+	return breaker
+}
+
+// BreakerChange: called by the breaker when a target's circuit changes state
+func BreakerChange(target, from, to string) {
+	logAdapter.Warn("Breaker: circuit changed", "target", target, "from", from, "to", to)
 }
 
 // SetLoggingAdapter: determines which logging adapter to use
-// customize which adapter you want to use, order of precedency: file and then the failsafe stdout
-func SetLoggingAdapter() LogAdapter {
-	if len(config.LogFileName) > 0 {
-		return &l.File{}
+// customize which adapter you want to use with SCH_LOG_OUTPUT: file, syslog, http or the failsafe stdout
+func SetLoggingAdapter() *l.Logger {
+	var out io.Writer = &l.StdOut{}
+	switch config.LogOutputType() {
+	case config.LogOutputFile:
+		out = &l.File{FileName: config.LogFileName, MaxSize: config.GetLogMaxSize(), RotateEvery: config.GetLogRotateEvery(), MaxBackups: config.GetLogMaxBackups(), Compress: config.LogCompress == "true"}
+	case config.LogOutputSyslog:
+		out = &l.Syslog{Addr: config.LogSyslogAddr, AppName: config.LogSyslogApp}
+	case config.LogOutputHTTP:
+		out = &l.HTTP{URL: config.LogHTTPUrl, Token: config.LogHTTPToken, BatchSize: config.GetLogHTTPBatch(), MaxBuffer: config.GetLogHTTPBuffer(), FlushInterval: config.GetLogHTTPFlush()}
 	}
-	return &l.StdOut{}
+	// validated by config.Load
+	level, _ := l.ParseLevel(config.LogLevel)
+	var encoder l.Encoder = l.TextEncoder{}
+	if strings.ToLower(config.LogFormat) == "json" {
+		encoder = l.JSONEncoder{}
+	}
+	return &l.Logger{Level: level, Encoder: encoder, Out: out}
+}
+
+// SetHistoryAdapter: determines where the run history is kept
+// order of precedence: file, db and then the failsafe memory
+func SetHistoryAdapter() HistoryAdapter {
+	retention := h.Retention{}
+	if maxRecords, errAtoi := strconv.Atoi(config.HistoryMaxRecords); errAtoi == nil {
+		retention.MaxRecords = maxRecords
+	}
+	if maxAge, errParse := time.ParseDuration(config.HistoryMaxAge); errParse == nil {
+		retention.MaxAge = maxAge
+	}
+	if len(config.HistoryFileName) > 0 {
+		return &h.File{FileName: config.HistoryFileName, Retention: retention}
+	}
+	if config.HistoryUseDB == "true" {
+		db := h.DB{Retention: retention}
+		errConnect := db.Connect()
+		if errConnect == nil {
+			return &db
+		}
+		logAdapter.Error("SetHistoryAdapter: unable to connect, using memory", "error", errConnect)
+	}
+	if retention.MaxRecords == 0 {
+		// keep memory in check
+		retention.MaxRecords = 1000
+	}
+	return &h.Memory{Retention: retention}
 }
 
 // UpdateStatus: update the status of the Job in the array of Job
-// remove from array of Job when status is "Done"
+// remove from array of Job when the status is terminal ("Done" or "Error"), a Job in error will be picked up again
+// by discovery when it is due
 func UpdateStatus(job j.Job, jobs *[]j.Job) {
 	removeIdx := -1
-	for i, _ := range *jobs {
+	for i := range *jobs {
 		if (*jobs)[i].Token == job.Token {
-			(*jobs)[i].Status = job.Status
-			if job.Status == "Done" {
-				removeIdx = i
+			if (*jobs)[i].Status == job.Status {
+				// set by RunJobs when the job was dispatched
+				break
+			}
+			if errSet := (*jobs)[i].SetStatus(job.Status, util.GetNow(), job.Error); errSet != nil {
+				logAdapter.Warn("UpdateStatus: invalid transition", "token", job.Token, "error", errSet)
 				break
 			}
+			if job.Status.IsTerminal() {
+				removeIdx = i
+			}
+			break
 		}
 	}
 	if removeIdx > -1 {
 		*jobs = append((*jobs)[:removeIdx], (*jobs)[removeIdx+1:]...)
 	}
+	SetJobGauges(*jobs)
+}
+
+// RemoveJob: drop a Job that hasn't started yet from the array of Job, e.g. when it was paused or changed
+func RemoveJob(job j.Job, jobs *[]j.Job) {
+	for i := range *jobs {
+		if (*jobs)[i].Token == job.Token && (*jobs)[i].Status == j.StatusReceived {
+			*jobs = append((*jobs)[:i], (*jobs)[i+1:]...)
+			break
+		}
+	}
+	SetJobGauges(*jobs)
+}
+
+// CurrentJobs: copy of the array of Job, safe to call outside of the main loop
+func CurrentJobs() []j.Job {
+	listCh := make(chan []j.Job)
+	JobListCh <- listCh
+	return <-listCh
+}
+
+// SetJobGauges: update the queued/in flight metrics from the array of Job
+func SetJobGauges(jobs []j.Job) {
+	queued, inFlight := 0, 0
+	for _, job := range jobs {
+		switch job.Status {
+		case j.StatusReceived:
+			queued++
+		case j.StatusInProcess:
+			inFlight++
+		}
+	}
+	metrics.JobsQueued.Set(float64(queued))
+	metrics.JobsInFlight.Set(float64(inFlight))
+}
+
+// AdapterName: short name of the discovery adapter, e.g. "File"
+func AdapterName(ja DiscoveryAdapter) string {
+	return strings.SplitN(ja.WhichDiscovery(), " ", 2)[0]
 }
 
 // CheckDup: checks if the Jobs already has the token, add it if needed
@@ -0,0 +1,690 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/keenfury/axenda/audit"
+	"github.com/keenfury/axenda/clock"
+	"github.com/keenfury/axenda/config"
+	d "github.com/keenfury/axenda/discovery"
+	h "github.com/keenfury/axenda/history"
+	j "github.com/keenfury/axenda/job"
+	r "github.com/keenfury/axenda/runner"
+	"github.com/keenfury/axenda/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessMinuteFakeClock(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2020-04-23T12:20:00-06:00")
+	fake := clock.NewFake(start)
+	util.Clock = fake
+	defer func() { util.Clock = clock.Real{} }()
+	fileName := "/tmp/main_test_process"
+	ioutil.WriteFile(fileName, []byte(`[{"token":"TOKENMAIN","active":true,"run_time":"2020-04-23T12:22:00-06:00","frequency":4}]`), 0644)
+	defer os.Remove(fileName)
+	runnerAdapter = &r.Mock{}
+	history := &h.Memory{}
+	historyAdapter = history
+	ja := &d.File{FileName: fileName, Runner: runnerAdapter, Clock: fake}
+	jobs := []j.Job{}
+	updateCh := make(chan j.Job)
+
+	// within the look ahead but not due yet
+	ProcessMinute(start, &jobs, ja, updateCh)
+	assert.Equal(t, 1, len(jobs), "Expected jobs count to be 1")
+	assert.Equal(t, j.StatusReceived, jobs[0].Status)
+
+	fake.Add(2 * time.Minute)
+	ProcessMinute(fake.Now(), &jobs, ja, updateCh)
+	UpdateStatus(<-updateCh, &jobs)
+	assert.Equal(t, j.StatusInProcess, jobs[0].Status)
+	UpdateStatus(<-updateCh, &jobs)
+	assert.Equal(t, 0, len(jobs), "Expected job to be removed when done")
+
+	var records []h.Record
+	for i := 0; i < 100 && len(records) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		records, _ = history.List("TOKENMAIN", time.Time{}, time.Time{})
+	}
+	assert.Equal(t, 1, len(records), "Expected a history record")
+	waitInFlight()
+	assert.Equal(t, h.ResultSuccess, records[0].Result)
+	assert.True(t, fake.Now().Equal(records[0].Start))
+	fileJobs, _ := ja.ListJobs()
+	expecting, _ := time.Parse(time.RFC3339, "2020-04-24T12:22:00-06:00")
+	assert.True(t, expecting.Equal(fileJobs[0].RunTime), "Expected the run time to move a day")
+}
+
+func TestProcessMinuteTimeZone(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2020-04-23T12:20:30-06:00")
+	fake := clock.NewFake(start)
+	util.Clock = fake
+	config.TimeZone = "Asia/Tokyo"
+	defer func() { util.Clock = clock.Real{}; config.TimeZone = "" }()
+	fileName := "/tmp/main_test_process_zone"
+	ioutil.WriteFile(fileName, []byte(`[{"token":"TOKENZONE","active":true,"run_time":"2020-04-23T12:22:00-06:00","frequency":4}]`), 0644)
+	defer os.Remove(fileName)
+	ja := &d.File{FileName: fileName, Runner: &r.Mock{}, Clock: fake}
+	jobs := []j.Job{}
+
+	// the ticker's time is in the host's zone, not SCH_TIME_ZONE
+	tick := util.TruncateTimeToMinute(fake.Now())
+	assert.Equal(t, "2020-04-24T03:20:00+09:00", tick.Format(time.RFC3339), "Expected the tick converted to the time zone")
+	assert.True(t, tick.Equal(util.TruncateTimeToMinute(util.GetNow())), "Expected the tick and the change paths to agree")
+	ProcessMinute(tick, &jobs, ja, make(chan j.Job, 10))
+	assert.Equal(t, 1, len(jobs), "Expected the job within the look ahead")
+}
+
+func TestDiscoveryChangeAfterTick(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2020-04-23T12:22:00-06:00")
+	fake := clock.NewFake(start)
+	util.Clock = fake
+	defer func() { util.Clock = clock.Real{} }()
+	fileName := "/tmp/main_test_change_tick"
+	ioutil.WriteFile(fileName, []byte(`[{"token":"TOKENONCE","active":true,"run_time":"2020-04-23T12:22:00-06:00","frequency":4}]`), 0644)
+	defer os.Remove(fileName)
+	runnerAdapter = &r.Mock{}
+	history := &h.Memory{}
+	historyAdapter = history
+	ja := &d.File{FileName: fileName, Runner: runnerAdapter, Clock: fake}
+	jobs := []j.Job{}
+	// nobody drains the updates, as when the loop handles the change first
+	updateCh := make(chan j.Job, 10)
+
+	ProcessMinute(start, &jobs, ja, updateCh)
+	assert.Equal(t, j.StatusInProcess, jobs[0].Status, "Expected the job marked when dispatched")
+	CheckForJobs(context.Background(), start, &jobs, ja)
+	RunJobs(context.Background(), &jobs, ja, updateCh)
+	waitInFlight()
+	records, _ := history.List("TOKENONCE", time.Time{}, time.Time{})
+	assert.Equal(t, 1, len(records), "Expected the change not to run the job again")
+	UpdateStatus(<-updateCh, &jobs)
+	assert.Equal(t, j.StatusInProcess, jobs[0].Status)
+	UpdateStatus(<-updateCh, &jobs)
+	assert.Equal(t, 0, len(jobs), "Expected job to be removed when done")
+}
+
+func TestUpdateStatusInvalidTransition(t *testing.T) {
+	jobs := []j.Job{{Token: "TOKENMAIN", Status: j.StatusReceived}}
+	UpdateStatus(j.Job{Token: "TOKENMAIN", Status: j.StatusDone}, &jobs)
+	assert.Equal(t, 1, len(jobs), "Invalid transition should be ignored")
+	assert.Equal(t, j.StatusReceived, jobs[0].Status)
+	UpdateStatus(j.Job{Token: "TOKENMAIN", Status: j.StatusError, Error: "Runner failure"}, &jobs)
+	assert.Equal(t, 0, len(jobs), "Expected job in error to be removed")
+}
+
+func TestReloadSuccess(t *testing.T) {
+	configFile := "/tmp/main_test_reload.yaml"
+	ioutil.WriteFile(configFile, []byte("discovery:\n  file:\n    name: /tmp/main_test_reload_a\n"), 0644)
+	defer os.Remove(configFile)
+	config.ConfigFile = configFile
+	defer func() {
+		config.ConfigFile = ""
+		config.Load("")
+	}()
+	assert.Nil(t, Setup())
+	assert.Equal(t, "/tmp/main_test_reload_a", CurrentDiscovery().(*d.File).FileName)
+	jobs := []j.Job{{Token: "TOKENRECEIVED", Status: j.StatusReceived}, {Token: "TOKENRUNNING", Status: j.StatusInProcess}}
+
+	// invalid, the current configuration is kept
+	ioutil.WriteFile(configFile, []byte("discovery:\n  type: nowhere\n"), 0644)
+	assert.NotNil(t, Reload(&jobs))
+	assert.Equal(t, "/tmp/main_test_reload_a", config.JobFileName)
+	assert.Equal(t, 2, len(jobs))
+
+	ioutil.WriteFile(configFile, []byte("discovery:\n  file:\n    name: /tmp/main_test_reload_b\nconcurrency: 2\n"), 0644)
+	assert.Nil(t, Reload(&jobs))
+	assert.Equal(t, "/tmp/main_test_reload_b", CurrentDiscovery().(*d.File).FileName)
+	assert.Equal(t, 2, cap(dispatchSlots))
+	assert.Equal(t, 1, len(jobs), "Expected the received job to be dropped")
+	assert.Equal(t, "TOKENRUNNING", jobs[0].Token)
+
+	// only the state file changes
+	jobs = append(jobs, j.Job{Token: "TOKENSTATE", Status: j.StatusReceived})
+	ioutil.WriteFile(configFile, []byte("discovery:\n  file:\n    name: /tmp/main_test_reload_b\n    state_file: /tmp/main_test_reload_state.json\nconcurrency: 2\n"), 0644)
+	assert.Nil(t, Reload(&jobs))
+	assert.Equal(t, "/tmp/main_test_reload_state.json", CurrentDiscovery().(*d.File).StateFile(), "Expected the discovery to use the new state file")
+	assert.Equal(t, 1, len(jobs), "Expected the job received with the old state file to be dropped")
+}
+
+func TestWorkflowRunSuccess(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2020-04-23T12:22:00-06:00")
+	fake := clock.NewFake(start)
+	util.Clock = fake
+	defer func() { util.Clock = clock.Real{} }()
+	fileName := "/tmp/main_test_workflow"
+	ioutil.WriteFile(fileName, []byte(`[{"token":"TOKENEXTRACT","active":true,"run_time":"2020-04-23T12:22:00-06:00","frequency":4},
+		{"token":"TOKENLOAD","active":true,"depends_on":["TOKENEXTRACT"]}]`), 0644)
+	defer os.Remove(fileName)
+	runnerAdapter = &r.Mock{}
+	history := &h.Memory{}
+	historyAdapter = history
+	ja := &d.File{FileName: fileName, Runner: runnerAdapter, Clock: fake}
+	discoveryAdapter = ja
+	defer func() { discoveryAdapter = nil }()
+	jobs := []j.Job{}
+	updateCh := make(chan j.Job, 10)
+
+	ProcessMinute(start, &jobs, ja, updateCh)
+	assert.Equal(t, 1, len(jobs), "Expected the dependent job to be left to the workflow")
+
+	var records []h.Record
+	for i := 0; i < 100 && len(records) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		records, _ = history.List("TOKENLOAD", time.Time{}, time.Time{})
+	}
+	assert.Equal(t, 1, len(records), "Expected the downstream job to run")
+	waitInFlight()
+	run, ok := workflows.Get(records[0].Workflow)
+	assert.True(t, ok, "Expected the run to be tracked")
+	assert.Equal(t, "TOKENEXTRACT", run.Root)
+}
+
+func TestWorkflowCompositeSuccess(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2020-04-23T12:22:00-06:00")
+	fake := clock.NewFake(start)
+	util.Clock = fake
+	defer func() { util.Clock = clock.Real{} }()
+	fileName := "/tmp/main_test_workflow_composite"
+	ioutil.WriteFile(fileName, []byte(`[{"token":"TOKENEXTRACT","active":true,"run_time":"2020-04-23T12:22:00-06:00","frequency":4},
+		{"token":"TOKENLOAD","active":true,"depends_on":["TOKENEXTRACT"]}]`), 0644)
+	defer os.Remove(fileName)
+	runnerAdapter = &r.Mock{}
+	history := &h.Memory{}
+	historyAdapter = history
+	ja := &d.Composite{Sources: []d.Source{{Name: "file", Discovery: &d.File{FileName: fileName, Runner: runnerAdapter, Clock: fake}}}}
+	discoveryAdapter = ja
+	defer func() { discoveryAdapter = nil }()
+	assert.Nil(t, CheckWorkflow(ja, withJob(j.Job{Token: "file:TOKENREPORT", DependsOn: []string{"file:TOKENLOAD"}})), "Expected the namespaced dependencies to resolve")
+	jobs := []j.Job{}
+	updateCh := make(chan j.Job, 10)
+
+	ProcessMinute(start, &jobs, ja, updateCh)
+	var records []h.Record
+	for i := 0; i < 100 && len(records) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		records, _ = history.List("file:TOKENLOAD", time.Time{}, time.Time{})
+	}
+	assert.Equal(t, 1, len(records), "Expected the downstream job to run")
+	waitInFlight()
+	assert.NotEqual(t, "", records[0].Workflow)
+}
+
+type tokenRunner struct {
+	r.Mock
+	mu     sync.Mutex
+	tokens []string
+}
+
+func (tr *tokenRunner) RunJob(job *j.Job) error {
+	tr.mu.Lock()
+	tr.tokens = append(tr.tokens, job.Token)
+	tr.mu.Unlock()
+	return nil
+}
+
+func TestRunManualCompositeToken(t *testing.T) {
+	fileName := "/tmp/main_test_manual_composite"
+	ioutil.WriteFile(fileName, []byte(`[{"token":"TOKENEXPORT","active":true,"always":["TOKENNOTIFY"]},
+		{"token":"TOKENNOTIFY","active":true}]`), 0644)
+	defer os.Remove(fileName)
+	runner := &tokenRunner{}
+	runnerAdapter = runner
+	defer func() { runnerAdapter = &r.Mock{} }()
+	historyAdapter = &h.Memory{}
+	ja := &d.Composite{Sources: []d.Source{{Name: "file", Discovery: &d.File{FileName: fileName, Runner: runner}}}}
+	discoveryAdapter = ja
+	defer func() { discoveryAdapter = nil }()
+	job, _ := FindJob(ja, "file:TOKENEXPORT")
+
+	RunManual(job)
+	for i := 0; i < 100; i++ {
+		runner.mu.Lock()
+		ran := len(runner.tokens)
+		runner.mu.Unlock()
+		if ran == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	waitInFlight()
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	assert.Equal(t, []string{"TOKENEXPORT", "TOKENNOTIFY"}, runner.tokens, "Expected the runner to get the tokens the source issued")
+}
+
+func TestRunHooksSuccess(t *testing.T) {
+	fileName := "/tmp/main_test_hooks"
+	ioutil.WriteFile(fileName, []byte(`[{"token":"TOKENEXPORT","active":true,"on_failure":["TOKENCLEANUP"],"always":["TOKENNOTIFY"]},
+		{"token":"TOKENCLEANUP","active":true,"on_success":["TOKENEXPORT"]},
+		{"token":"TOKENNOTIFY","active":true}]`), 0644)
+	defer os.Remove(fileName)
+	runnerAdapter = &r.Mock{}
+	history := &h.Memory{}
+	historyAdapter = history
+	ja := &d.File{FileName: fileName, Runner: runnerAdapter}
+	discoveryAdapter = ja
+	defer func() { discoveryAdapter = nil }()
+	jobs, _ := ja.ListJobs()
+
+	RunHooks(jobs[0], fmt.Errorf("export failed"), nil)
+	var records []h.Record
+	for i := 0; i < 100 && len(records) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+		records, _ = history.List("", time.Time{}, time.Time{})
+	}
+	time.Sleep(20 * time.Millisecond)
+	records, _ = history.List("", time.Time{}, time.Time{})
+	assert.Equal(t, 2, len(records), "Expected cleanup and notify, the export not to run again")
+	for _, record := range records {
+		assert.Equal(t, "TOKENEXPORT", record.Trigger)
+	}
+	assert.Equal(t, []string{"TOKENNOTIFY"}, HookTargets(jobs[0], nil))
+}
+
+func TestAdminAuditSuccess(t *testing.T) {
+	fileName := "/tmp/main_test_audit"
+	ioutil.WriteFile(fileName, []byte(`[{"token":"TOKENAUDIT","active":true,"run_time":"2020-04-23T12:22:00-06:00","frequency":4,"url_path":"http://localhost"}]`), 0644)
+	defer os.Remove(fileName)
+	discoveryAdapter = &d.File{FileName: fileName, Runner: &r.Mock{}}
+	auditAdapter = &audit.Memory{}
+	JobRemoveCh = make(chan j.Job, 10)
+	defer func() { discoveryAdapter = nil; JobRemoveCh = nil }()
+	server := httptest.NewServer(AdminHandler())
+	defer server.Close()
+
+	body := `{"token":"TOKENAUDIT","active":true,"run_time":"2020-04-23T13:22:00-06:00","frequency":4,"url_path":"http://localhost"}`
+	req, _ := http.NewRequest(http.MethodPut, server.URL+"/jobs/TOKENAUDIT", strings.NewReader(body))
+	req.Header.Set("X-Actor", "alice")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, err = http.Post(server.URL+"/jobs/TOKENAUDIT/pause", "application/json", nil)
+	assert.Nil(t, err)
+	resp.Body.Close()
+
+	resp, err = http.Get(server.URL + "/audit?job=TOKENAUDIT&action=update")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	events := []audit.Event{}
+	json.NewDecoder(resp.Body).Decode(&events)
+	assert.Equal(t, 1, len(events), "Expected the update only")
+	assert.Equal(t, "alice", events[0].Actor)
+	assert.Equal(t, []audit.Change{{Field: "run_time", Before: "2020-04-23T12:22:00-06:00", After: "2020-04-23T13:22:00-06:00"}}, events[0].Changes)
+	paused, _ := CurrentAudit().List(audit.Filter{Actor: "admin", Action: audit.ActionPause})
+	assert.Equal(t, 1, len(paused), "Expected the pause by the default actor")
+}
+
+func TestRunCommand(t *testing.T) {
+	jobFile := "/tmp/main_test_cli_jobs"
+	historyFile := "/tmp/main_test_cli_history"
+	auditFile := "/tmp/main_test_cli_audit"
+	configFile := "/tmp/main_test_cli.yaml"
+	noAuditFile := "/tmp/main_test_cli_no_audit.yaml"
+	ioutil.WriteFile(noAuditFile, []byte("discovery:\n  file:\n    name: "+jobFile+"\n"), 0644)
+	ioutil.WriteFile(jobFile, []byte(`[{"token":"TOKENCLI","active":true,"run_time":"2020-04-23T12:22:00-06:00","frequency":4}]`), 0644)
+	ioutil.WriteFile(configFile, []byte("discovery:\n  file:\n    name: "+jobFile+"\nhistory:\n  file_name: "+historyFile+"\naudit:\n  file_name: "+auditFile+"\n"), 0644)
+	for _, fileName := range []string{jobFile, historyFile, auditFile, configFile, noAuditFile} {
+		defer os.Remove(fileName)
+	}
+	defer func() {
+		config.ConfigFile = ""
+		config.Load("")
+		SetAdapters(&r.Mock{}, nil, &h.Memory{})
+	}()
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"version", []string{"version"}, ""},
+		{"help", []string{"help"}, ""},
+		{"unknown command", []string{"nowhere"}, "Unknown command: nowhere"},
+		{"unknown flag", []string{"--nowhere"}, "flag provided but not defined"},
+		{"jobs missing command", []string{"jobs"}, "Missing jobs command"},
+		{"next missing job", []string{"next"}, "Missing --job"},
+		{"history missing command", []string{"history"}, "Usage: axenda history export|report"},
+		{"jobs list", []string{"--config", configFile, "jobs", "list"}, ""},
+		{"jobs missing token", []string{"--config", configFile, "jobs", "pause"}, "Usage: axenda jobs pause TOKEN"},
+		{"jobs unknown token", []string{"--config", configFile, "jobs", "pause", "MISSING"}, d.ErrJobNotFound.Error()},
+		{"jobs unknown command", []string{"--config", configFile, "jobs", "nowhere", "TOKENCLI"}, "Unknown jobs command: nowhere"},
+		{"jobs without audit file", []string{"--config", noAuditFile, "jobs", "resume", "TOKENCLI"}, ""},
+		{"jobs trigger", []string{"--config", configFile, "jobs", "trigger", "TOKENCLI"}, ""},
+		{"jobs pause", []string{"--config", configFile, "jobs", "pause", "TOKENCLI"}, ""},
+		{"next", []string{"--config", configFile, "next", "--job", "TOKENCLI", "--count", "2"}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := RunCommand(test.args)
+			if len(test.wantErr) == 0 {
+				assert.Nil(t, err, "No error expected")
+				return
+			}
+			if assert.NotNil(t, err, "Error expected") {
+				assert.Contains(t, err.Error(), test.wantErr)
+			}
+		})
+	}
+	records, _ := (&h.File{FileName: historyFile}).List("TOKENCLI", time.Time{}, time.Time{})
+	if assert.Equal(t, 1, len(records), "Expected the trigger in the run history") {
+		assert.True(t, records[0].Manual)
+	}
+	jobs, _ := (&d.File{FileName: jobFile}).ListJobs()
+	assert.False(t, jobs[0].Active, "Expected the job paused")
+}
+
+func TestHistoryRangeMonth(t *testing.T) {
+	config.UseUTC = "true"
+	defer func() { config.UseUTC = "" }()
+	from, to, err := HistoryRange("", "", "2020-02")
+	assert.Nil(t, err)
+	assert.Equal(t, "2020-02-01T00:00:00Z", from.Format(time.RFC3339))
+	assert.Equal(t, "2020-02-29T23:59:59Z", to.Format(time.RFC3339), "Expected the end of the leap month")
+	_, _, err = HistoryRange("2020-02-01T00:00:00Z", "", "2020-02")
+	assert.NotNil(t, err, "Expected a month or from/to")
+}
+
+func TestBuildDashboardFakeClock(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2020-04-23T12:20:00-06:00")
+	fake := clock.NewFake(start)
+	util.Clock = fake
+	defer func() { util.Clock = clock.Real{} }()
+	fileName := "/tmp/main_test_dashboard"
+	ioutil.WriteFile(fileName, []byte(`[{"token":"TOKENDASH","job_name":"dash","active":true,"run_time":"2020-04-23T12:22:00-06:00","frequency":3}]`), 0644)
+	defer os.Remove(fileName)
+	discoveryAdapter = &d.File{FileName: fileName, Runner: &r.Mock{}, Clock: fake}
+	history := &h.Memory{}
+	historyAdapter = history
+	history.Save(h.Record{Token: "TOKENDASH", Start: start.Add(-2 * time.Hour), Result: h.ResultSuccess})
+	history.Save(h.Record{Token: "TOKENDASH", Start: start.Add(-time.Hour), Result: h.ResultError, Error: "boom"})
+	listCh := serveJobList([]j.Job{{Token: "TOKENDASH", Status: j.StatusInProcess}})
+	defer func() { close(listCh); JobListCh = nil; discoveryAdapter = nil }()
+
+	data := BuildDashboard(fake.Now())
+	assert.Equal(t, 0, len(data.Errors), "No errors expected")
+	assert.True(t, start.Equal(data.Now))
+	assert.Equal(t, 1, len(data.Jobs))
+	assert.Equal(t, "2020-04-23T12:22:00-06:00", data.Jobs[0].NextRun.Format(time.RFC3339))
+	assert.Equal(t, 24, len(data.Upcoming), "Expected the hourly runs of the next 24 hours")
+	assert.Equal(t, "2020-04-24T11:22:00-06:00", data.Upcoming[23].RunTime.Format(time.RFC3339))
+	assert.Equal(t, 1, len(data.InFlight))
+	assert.Equal(t, 1, len(data.Failures), "Expected the failed run only")
+	assert.Equal(t, "boom", data.Failures[0].Error)
+}
+
+func TestCachedDashboardPerMinute(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2020-04-23T12:20:00-06:00")
+	fake := clock.NewFake(start)
+	util.Clock = fake
+	defer func() { util.Clock = clock.Real{} }()
+	fileName := "/tmp/main_test_dashboard_cache"
+	ioutil.WriteFile(fileName, []byte(`[{"token":"TOKENDASH","active":true,"run_time":"2020-04-23T12:22:00-06:00","frequency":3}]`), 0644)
+	defer os.Remove(fileName)
+	discoveryAdapter = &d.File{FileName: fileName, Runner: &r.Mock{}, Clock: fake}
+	historyAdapter = &h.Memory{}
+	listCh := serveJobList(nil)
+	defer func() { close(listCh); JobListCh = nil; discoveryAdapter = nil }()
+	dashboardCache.minute = time.Time{}
+
+	assert.Equal(t, 1, len(CachedDashboard(fake.Now()).Jobs))
+	ioutil.WriteFile(fileName, []byte(`[{"token":"TOKENDASH","active":true,"run_time":"2020-04-23T12:22:00-06:00","frequency":3},
+		{"token":"TOKENNEW","active":true,"run_time":"2020-04-23T12:30:00-06:00","frequency":3}]`), 0644)
+	fake.Add(30 * time.Second)
+	assert.Equal(t, 1, len(CachedDashboard(fake.Now()).Jobs), "Expected the dashboard of the minute")
+	fake.Add(30 * time.Second)
+	assert.Equal(t, 2, len(CachedDashboard(fake.Now()).Jobs), "Expected the dashboard to be built again")
+}
+
+func TestDashboardAdminToken(t *testing.T) {
+	config.AdminToken = "secret"
+	defer func() { config.AdminToken = "" }()
+	server := httptest.NewServer(DashboardAuthHandler())
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+// serveJobList: answer CurrentJobs with jobs until the returned channel is closed
+func serveJobList(jobs []j.Job) chan chan []j.Job {
+	JobListCh = make(chan chan []j.Job)
+	go func(listCh chan chan []j.Job) {
+		for reply := range listCh {
+			reply <- jobs
+		}
+	}(JobListCh)
+	return JobListCh
+}
+
+func TestHealthzTickWindow(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2020-04-23T12:20:00-06:00")
+	fake := clock.NewFake(start)
+	util.Clock = fake
+	defer func() { util.Clock = clock.Real{} }()
+	health = healthState{started: start}
+	defer func() { health = healthState{started: util.GetNow()} }()
+
+	code, resp := getHealth(HealthzHandler)
+	assert.Equal(t, http.StatusOK, code, "Expected healthy while starting")
+	fake.Add(3 * time.Minute)
+	code, resp = getHealth(HealthzHandler)
+	assert.Equal(t, http.StatusServiceUnavailable, code, "Expected failing without any tick")
+	assert.Equal(t, "fail", resp.Status)
+
+	RecordTick(fake.Now())
+	code, resp = getHealth(HealthzHandler)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", resp.Status)
+	assert.True(t, resp.Checks["tick"].OK)
+	fake.Add(2 * time.Minute)
+	code, _ = getHealth(HealthzHandler)
+	assert.Equal(t, http.StatusOK, code, "Expected healthy within the 2 minute window")
+	fake.Add(time.Minute)
+	code, resp = getHealth(HealthzHandler)
+	assert.Equal(t, http.StatusServiceUnavailable, code, "Expected a stale tick to fail")
+	assert.False(t, resp.Checks["tick"].OK)
+}
+
+func TestHealthzTimeZone(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2020-04-23T12:20:30-06:00")
+	fake := clock.NewFake(start)
+	util.Clock = fake
+	config.TimeZone = "Asia/Tokyo"
+	defer func() { util.Clock = clock.Real{}; config.TimeZone = "" }()
+	health = healthState{started: start}
+	defer func() { health = healthState{started: util.GetNow()} }()
+	fileName := "/tmp/main_test_healthz_zone"
+	ioutil.WriteFile(fileName, []byte(`[]`), 0644)
+	defer os.Remove(fileName)
+	ja := &d.File{FileName: fileName, Runner: &r.Mock{}, Clock: fake}
+	jobs := []j.Job{}
+
+	ProcessMinute(util.TruncateTimeToMinute(fake.Now()), &jobs, ja, make(chan j.Job, 10))
+	code, _ := getHealth(HealthzHandler)
+	assert.Equal(t, http.StatusOK, code, "Expected the tick recorded at the actual time")
+	fake.Add(3 * time.Minute)
+	code, _ = getHealth(HealthzHandler)
+	assert.Equal(t, http.StatusServiceUnavailable, code, "Expected a stale tick to fail")
+}
+
+func TestReadyzDiscovery(t *testing.T) {
+	fileName := "/tmp/main_test_readyz"
+	ioutil.WriteFile(fileName, []byte(`[]`), 0644)
+	defer os.Remove(fileName)
+	discoveryAdapter = &d.File{FileName: fileName, Runner: &r.Mock{}}
+	health = healthState{started: util.GetNow()}
+	defer func() { health = healthState{started: util.GetNow()}; discoveryAdapter = nil }()
+
+	RecordDiscovery(util.GetNow(), nil)
+	code, resp := getHealth(ReadyzHandler)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, resp.Checks["discovery"].OK)
+	assert.True(t, resp.Checks["backend"].OK)
+
+	RecordDiscovery(util.GetNow(), fmt.Errorf("Discovery failure"))
+	code, resp = getHealth(ReadyzHandler)
+	assert.Equal(t, http.StatusServiceUnavailable, code, "Expected the failed GetJobs to fail")
+	assert.Equal(t, HealthCheck{OK: false, Detail: "Discovery failure"}, resp.Checks["discovery"])
+	assert.True(t, resp.Checks["backend"].OK)
+
+	RecordDiscovery(util.GetNow(), nil)
+	os.Remove(fileName)
+	code, resp = getHealth(ReadyzHandler)
+	assert.Equal(t, http.StatusServiceUnavailable, code, "Expected the failed Ping to fail")
+	assert.True(t, resp.Checks["discovery"].OK)
+	assert.False(t, resp.Checks["backend"].OK)
+}
+
+// getHealth: the status code and body of a health handler
+func getHealth(handler http.HandlerFunc) (int, HealthResponse) {
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	resp := HealthResponse{}
+	httpResp, errGet := http.Get(server.URL)
+	if errGet != nil {
+		return 0, resp
+	}
+	defer httpResp.Body.Close()
+	json.NewDecoder(httpResp.Body).Decode(&resp)
+	return httpResp.StatusCode, resp
+}
+
+func TestAdminJobsCRUD(t *testing.T) {
+	fileName := "/tmp/main_test_admin_crud"
+	ioutil.WriteFile(fileName, []byte(`[]`), 0644)
+	defer os.Remove(fileName)
+	discoveryAdapter = &d.File{FileName: fileName, Runner: &r.Mock{}}
+	auditAdapter = &audit.Memory{}
+	JobRemoveCh = make(chan j.Job, 10)
+	defer func() { discoveryAdapter = nil; JobRemoveCh = nil }()
+	server := httptest.NewServer(AdminHandler())
+	defer server.Close()
+
+	body := `{"token":"TOKENCRUD","active":true,"run_time":"2020-04-23T12:22:00-06:00","frequency":4,"url_path":"http://localhost"}`
+	code, _ := adminDo(server.URL, http.MethodPost, "/jobs", body)
+	assert.Equal(t, http.StatusCreated, code)
+	code, _ = adminDo(server.URL, http.MethodPost, "/jobs", body)
+	assert.Equal(t, http.StatusConflict, code, "Expected a duplicate token to conflict")
+	code, _ = adminDo(server.URL, http.MethodPost, "/jobs", `{"token":"TOKENBAD"}`)
+	assert.Equal(t, http.StatusBadRequest, code, "Expected an invalid job to be refused")
+	code, _ = adminDo(server.URL, http.MethodPost, "/jobs", `{"token":"TOKENBIG","job_name":"`+strings.Repeat("x", maxJobBody)+`"}`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code, "Expected a body over the limit to be refused")
+
+	code, bList := adminDo(server.URL, http.MethodGet, "/jobs", "")
+	assert.Equal(t, http.StatusOK, code)
+	jobs := []j.Job{}
+	json.Unmarshal(bList, &jobs)
+	assert.Equal(t, 1, len(jobs))
+
+	body = `{"token":"TOKENCRUD","job_name":"crud","active":true,"run_time":"2020-04-23T13:22:00-06:00","frequency":4,"url_path":"http://localhost"}`
+	code, _ = adminDo(server.URL, http.MethodPut, "/jobs/TOKENCRUD", body)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = adminDo(server.URL, http.MethodPut, "/jobs/TOKENOTHER", body)
+	assert.Equal(t, http.StatusBadRequest, code, "Expected the token of the url and body to match")
+	code, bJob := adminDo(server.URL, http.MethodGet, "/jobs/TOKENCRUD", "")
+	assert.Equal(t, http.StatusOK, code)
+	job := j.Job{}
+	json.Unmarshal(bJob, &job)
+	assert.Equal(t, "crud", job.JobName)
+
+	code, _ = adminDo(server.URL, http.MethodDelete, "/jobs/TOKENCRUD", "")
+	assert.Equal(t, http.StatusNoContent, code)
+	code, _ = adminDo(server.URL, http.MethodGet, "/jobs/TOKENCRUD", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = adminDo(server.URL, http.MethodDelete, "/jobs/TOKENCRUD", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, 2, len(JobRemoveCh), "Expected the update and delete to drop the job from the scheduler")
+}
+
+func TestAdminPauseResume(t *testing.T) {
+	fileName := "/tmp/main_test_admin_pause"
+	ioutil.WriteFile(fileName, []byte(`[{"token":"TOKENPAUSE","active":true,"run_time":"2020-04-23T12:22:00-06:00","frequency":4,"url_path":"http://localhost"}]`), 0644)
+	defer os.Remove(fileName)
+	discoveryAdapter = &d.File{FileName: fileName, Runner: &r.Mock{}}
+	auditAdapter = &audit.Memory{}
+	JobRemoveCh = make(chan j.Job, 10)
+	defer func() { discoveryAdapter = nil; JobRemoveCh = nil }()
+	server := httptest.NewServer(AdminHandler())
+	defer server.Close()
+
+	code, _ := adminDo(server.URL, http.MethodPost, "/jobs/TOKENPAUSE/pause", "")
+	assert.Equal(t, http.StatusOK, code)
+	job, _ := FindJob(CurrentDiscovery().(JobStore), "TOKENPAUSE")
+	assert.False(t, job.Active, "Expected the job paused")
+	assert.Equal(t, 1, len(JobRemoveCh), "Expected the paused job dropped from the scheduler")
+	code, _ = adminDo(server.URL, http.MethodPost, "/jobs/TOKENPAUSE/resume", "")
+	assert.Equal(t, http.StatusOK, code)
+	job, _ = FindJob(CurrentDiscovery().(JobStore), "TOKENPAUSE")
+	assert.True(t, job.Active, "Expected the job resumed")
+	code, _ = adminDo(server.URL, http.MethodGet, "/jobs/TOKENPAUSE/pause", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+	code, _ = adminDo(server.URL, http.MethodPost, "/jobs/TOKENMISSING/pause", "")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestAdminTrigger(t *testing.T) {
+	fileName := "/tmp/main_test_admin_trigger"
+	ioutil.WriteFile(fileName, []byte(`[{"token":"TOKENTRIGGER","active":true,"run_time":"2020-04-23T12:22:00-06:00","frequency":4,"url_path":"http://localhost"}]`), 0644)
+	defer os.Remove(fileName)
+	runnerAdapter = &r.Mock{}
+	history := &h.Memory{}
+	historyAdapter = history
+	discoveryAdapter = &d.File{FileName: fileName, Runner: runnerAdapter}
+	auditAdapter = &audit.Memory{}
+	defer func() { discoveryAdapter = nil }()
+	server := httptest.NewServer(AdminHandler())
+	defer server.Close()
+
+	code, _ := adminDo(server.URL, http.MethodPost, "/jobs/TOKENTRIGGER/trigger", "")
+	assert.Equal(t, http.StatusAccepted, code)
+	waitInFlight()
+	records, _ := history.List("TOKENTRIGGER", time.Time{}, time.Time{})
+	assert.Equal(t, 1, len(records), "Expected the job to run")
+	assert.True(t, records[0].Manual)
+	job, _ := FindJob(CurrentDiscovery().(JobStore), "TOKENTRIGGER")
+	assert.Equal(t, "2020-04-23T12:22:00-06:00", job.RunTime.Format(time.RFC3339), "Expected the schedule left as is")
+	triggered, _ := CurrentAudit().List(audit.Filter{Action: audit.ActionTrigger})
+	assert.Equal(t, 1, len(triggered))
+}
+
+func TestAdminAuthToken(t *testing.T) {
+	config.AdminToken = "secret"
+	defer func() { config.AdminToken = "" }()
+	server := httptest.NewServer(AdminAuth(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	defer server.Close()
+	for authorization, expected := range map[string]int{"": http.StatusUnauthorized, "Bearer secre": http.StatusUnauthorized,
+		"Bearer secrets": http.StatusUnauthorized, "Bearer secret": http.StatusNoContent} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		req.Header.Set("Authorization", authorization)
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		resp.Body.Close()
+		assert.Equal(t, expected, resp.StatusCode, "Authorization: %q", authorization)
+	}
+}
+
+// adminDo: the status code and body of an admin api request
+func adminDo(serverURL, method, path, body string) (int, []byte) {
+	req, _ := http.NewRequest(method, serverURL+path, strings.NewReader(body))
+	resp, errDo := http.DefaultClient.Do(req)
+	if errDo != nil {
+		return 0, nil
+	}
+	defer resp.Body.Close()
+	bBody, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, bBody
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+/*
+Prometheus metrics for the scheduler, served on /metrics when SCH_METRICS_ADDR is set, the discovery adapters,
+runners and circuit breaker record their own
+
+- axenda_jobs_discovered: jobs returned by discovery on the last tick (gauge) and in total (counter)
+- axenda_discovery_errors_total: GetJobs errors by adapter
+- axenda_dispatches_total: runs by runner and outcome (success/error), a run refused by an open circuit is an error
+- axenda_dispatch_duration_seconds: time for StartJob and CompleteJob by runner
+- axenda_schedule_lag_seconds: actual start minus the job's RunTime
+- axenda_jobs_in_flight / axenda_jobs_queued: jobs "In Process" / "Received"
+- axenda_breaker_state: circuit breaker state by target (0 closed, 1 half-open, 2 open)
+- axenda_sla_misses_total: SLA expectations missed by expectation (start_within/succeed_by)
+*/
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+var (
+	JobsDiscovered = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "axenda_jobs_discovered",
+		Help: "Number of jobs returned by discovery on the last tick.",
+	})
+	JobsDiscoveredTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "axenda_jobs_discovered_total",
+		Help: "Total number of jobs returned by discovery.",
+	})
+	DiscoveryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "axenda_discovery_errors_total",
+		Help: "Number of discovery errors by adapter.",
+	}, []string{"adapter"})
+	Dispatches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "axenda_dispatches_total",
+		Help: "Number of job dispatches by runner and outcome.",
+	}, []string{"runner", "outcome"})
+	DispatchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "axenda_dispatch_duration_seconds",
+		Help:    "Time taken to start and complete a job by runner.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"runner"})
+	ScheduleLag = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "axenda_schedule_lag_seconds",
+		Help:    "Actual start of a job minus its scheduled run time.",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600},
+	})
+	JobsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "axenda_jobs_in_flight",
+		Help: "Number of jobs currently in process.",
+	})
+	JobsQueued = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "axenda_jobs_queued",
+		Help: "Number of jobs received and waiting for their run time.",
+	})
+	BreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "axenda_breaker_state",
+		Help: "Circuit breaker state by target: 0 closed, 1 half-open, 2 open.",
+	}, []string{"target"})
+	SLAMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "axenda_sla_misses_total",
+		Help: "Number of SLA expectations missed by expectation.",
+	}, []string{"expectation"})
+)
+
+// ObserveDispatch: record the duration of a job run, lag is the actual start minus the RunTime
+func ObserveDispatch(runner string, duration, lag time.Duration) {
+	DispatchDuration.WithLabelValues(runner).Observe(duration.Seconds())
+	ScheduleLag.Observe(lag.Seconds())
+}
+
+// ObserveRun: record the outcome of a runner's RunJob
+func ObserveRun(runner string, err error) {
+	outcome := OutcomeSuccess
+	if err != nil {
+		outcome = OutcomeError
+	}
+	Dispatches.WithLabelValues(runner, outcome).Inc()
+}
+
+// ObserveDiscovery: record the result of an adapter's GetJobs, the jobs of the tick are set by the scheduler
+func ObserveDiscovery(adapter string, count int, err error) {
+	if err != nil {
+		DiscoveryErrors.WithLabelValues(adapter).Inc()
+	}
+	JobsDiscoveredTotal.Add(float64(count))
+}
+
+// SetBreakerState: record the state of a target's circuit, 0 closed, 1 half-open, 2 open
+func SetBreakerState(target string, value float64) {
+	BreakerState.WithLabelValues(target).Set(value)
+}
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
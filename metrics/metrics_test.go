@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+// sampleCount: the number of observations of a histogram
+func sampleCount(observer prometheus.Observer) uint64 {
+	m := &dto.Metric{}
+	observer.(prometheus.Metric).Write(m)
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestObserveDiscovery(t *testing.T) {
+	total := testutil.ToFloat64(JobsDiscoveredTotal)
+	errors := testutil.ToFloat64(DiscoveryErrors.WithLabelValues("Test"))
+	ObserveDiscovery("Test", 3, nil)
+	ObserveDiscovery("Test", 0, fmt.Errorf("Discovery failure"))
+	assert.Equal(t, total+3, testutil.ToFloat64(JobsDiscoveredTotal))
+	assert.Equal(t, errors+1, testutil.ToFloat64(DiscoveryErrors.WithLabelValues("Test")))
+	JobsDiscovered.Set(2)
+	assert.Equal(t, 2.0, testutil.ToFloat64(JobsDiscovered))
+}
+
+func TestObserveRun(t *testing.T) {
+	success := testutil.ToFloat64(Dispatches.WithLabelValues("Test", OutcomeSuccess))
+	failure := testutil.ToFloat64(Dispatches.WithLabelValues("Test", OutcomeError))
+	ObserveRun("Test", nil)
+	ObserveRun("Test", fmt.Errorf("Runner failure"))
+	ObserveRun("Test", fmt.Errorf("Runner failure"))
+	assert.Equal(t, success+1, testutil.ToFloat64(Dispatches.WithLabelValues("Test", OutcomeSuccess)))
+	assert.Equal(t, failure+2, testutil.ToFloat64(Dispatches.WithLabelValues("Test", OutcomeError)))
+}
+
+func TestObserveDispatch(t *testing.T) {
+	durations := sampleCount(DispatchDuration.WithLabelValues("Test"))
+	lags := sampleCount(ScheduleLag)
+	ObserveDispatch("Test", time.Second, 5*time.Second)
+	assert.Equal(t, durations+1, sampleCount(DispatchDuration.WithLabelValues("Test")))
+	assert.Equal(t, lags+1, sampleCount(ScheduleLag))
+}
+
+func TestJobGauges(t *testing.T) {
+	JobsInFlight.Set(4)
+	JobsQueued.Set(7)
+	assert.Equal(t, 4.0, testutil.ToFloat64(JobsInFlight))
+	assert.Equal(t, 7.0, testutil.ToFloat64(JobsQueued))
+}
+
+func TestSetBreakerState(t *testing.T) {
+	SetBreakerState("test:1", 2)
+	assert.Equal(t, 2.0, testutil.ToFloat64(BreakerState.WithLabelValues("test:1")))
+	SetBreakerState("test:1", 0)
+	assert.Equal(t, 0.0, testutil.ToFloat64(BreakerState.WithLabelValues("test:1")))
+}
+
+func TestSLAMisses(t *testing.T) {
+	misses := testutil.ToFloat64(SLAMisses.WithLabelValues("start_within"))
+	SLAMisses.WithLabelValues("start_within").Inc()
+	assert.Equal(t, misses+1, testutil.ToFloat64(SLAMisses.WithLabelValues("start_within")))
+}
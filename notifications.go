@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/keenfury/axenda/config"
+	j "github.com/keenfury/axenda/job"
+	"github.com/keenfury/axenda/notify"
+	"github.com/keenfury/axenda/util"
+)
+
+// notifier: follows the result of every run, its failure counts are kept on reload
+var notifier = &notify.Dispatcher{}
+
+// SetNotifier: the rules and notifiers of the SCH_NOTIFY_* and SCH_SMTP_* settings
+func SetNotifier() {
+	rules := notify.Rules{Throttle: config.GetNotifyThrottle(), Dedup: config.GetNotifyDedup()}
+	for _, rule := range config.GetNotifyOn() {
+		switch rule {
+		case config.NotifyOnFirst:
+			rules.FirstFailure = true
+		case config.NotifyOnConsecutive:
+			rules.Consecutive = config.GetNotifyConsecutive()
+		case config.NotifyOnRecovery:
+			rules.Recovery = true
+		}
+	}
+	notifiers := []notify.Notifier{}
+	if len(config.NotifyWebhookUrl) > 0 {
+		notifiers = append(notifiers, &notify.Webhook{URL: config.NotifyWebhookUrl})
+	}
+	if len(config.NotifySlackUrl) > 0 {
+		notifiers = append(notifiers, &notify.Slack{URL: config.NotifySlackUrl})
+	}
+	if len(config.SMTPAddr) > 0 {
+		smtp := &notify.SMTP{Addr: config.SMTPAddr, User: config.SMTPUser, Password: config.SMTPPwd, From: config.SMTPFrom}
+		for _, to := range strings.Split(config.SMTPTo, ",") {
+			if to = strings.TrimSpace(to); len(to) > 0 {
+				smtp.To = append(smtp.To, to)
+			}
+		}
+		notifiers = append(notifiers, smtp)
+	}
+	notifier.Set(rules, notifiers...)
+}
+
+// NotifyRun: alert on the result of the run if the rules call for it
+func NotifyRun(job j.Job, errRun error) {
+	alert := notifier.Observe(job, errRun, util.GetNow())
+	if alert == nil {
+		return
+	}
+	logAdapter.Info("Notify: "+alert.Subject(), "token", alert.Token, "job_name", alert.JobName, "kind", alert.Kind, "failures", alert.Failures)
+	SendAlert(*alert)
+}
+
+// SendAlert: send the alert from a goroutine, a slow webhook or smtp server doesn't hold the job (see notify.SendTimeout)
+func SendAlert(alert notify.Alert) {
+	goInFlight(func() {
+		for _, errSend := range notifier.Send(alert) {
+			logAdapter.Error("Notify: unable to send", "token", alert.Token, "kind", alert.Kind, "error", errSend)
+		}
+	})
+}
@@ -0,0 +1,233 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	j "github.com/keenfury/axenda/job"
+	"github.com/keenfury/axenda/util"
+)
+
+/*
+Failure notifications, the Dispatcher follows the result of every run and decides when to alert:
+- first: the first failure of a job
+- consecutive: the job failed Consecutive times in a row, and again every Consecutive failures while it keeps failing
+- recovery: the job succeeded again after a failure alert was sent
+
+An alert goes to every notifier (Webhook, Slack, SMTP) plus the recipients of the job (job.Notify):
+- an email address, added to the SMTP recipients
+- a url, posted the webhook json
+- "slack:" and a url, posted the Slack incoming webhook text
+
+Repeat failure alerts of a job are throttled (not more than one per Throttle) and deduplicated (the same error is not
+sent again within Dedup).  Every notifier gives up after SendTimeout, Send is meant to be called from a goroutine.
+*/
+
+const (
+	KindFailure  = "Failure"
+	KindRecovery = "Recovery"
+	// KindMissed: an SLA expectation of the job was missed, see the sla package
+	KindMissed = "Missed"
+
+	slackPrefix = "slack:"
+)
+
+// SendTimeout: how long a notifier waits for the webhook or the smtp server
+var SendTimeout = 10 * time.Second
+
+type (
+	Notifier interface {
+		WhichNotifier() string
+		Notify(Alert) error
+	}
+
+	Alert struct {
+		Kind       string    `json:"kind"`
+		Token      string    `json:"token"`
+		JobName    string    `json:"job_name"`
+		Error      string    `json:"error,omitempty"`
+		Failures   int       `json:"failures"`
+		At         time.Time `json:"at"`
+		Recipients []string  `json:"recipients,omitempty"`
+	}
+
+	Rules struct {
+		FirstFailure bool
+		// Consecutive: alert every time a job failed this many more times in a row (3, 6, 9...), 0 is off
+		Consecutive int
+		Recovery    bool
+		// Throttle: min time between two failure alerts of a job
+		Throttle time.Duration
+		// Dedup: a failure alert with the same error as the last one of the job is dropped within this time
+		Dedup time.Duration
+	}
+
+	// Dispatcher: keeps the failures of each job, safe to use from the running jobs
+	Dispatcher struct {
+		mu        sync.Mutex
+		rules     Rules
+		notifiers []Notifier
+		state     map[string]*jobState
+	}
+
+	jobState struct {
+		failures  int
+		alerted   bool
+		lastAlert time.Time
+		lastError string
+	}
+)
+
+// Set: the rules and the notifiers, the failures of the jobs are kept (e.g. on reload)
+func (d *Dispatcher) Set(rules Rules, notifiers ...Notifier) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rules = rules
+	d.notifiers = notifiers
+}
+
+// Notifiers: the names of the notifiers set
+func (d *Dispatcher) Notifiers() (names []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, n := range d.notifiers {
+		names = append(names, n.WhichNotifier())
+	}
+	return
+}
+
+// Observe: the result of a run, returns the alert to send if the rules call for one
+func (d *Dispatcher) Observe(job j.Job, errRun error, at time.Time) (alert *Alert) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.state == nil {
+		d.state = map[string]*jobState{}
+	}
+	st, ok := d.state[job.Token]
+	if !ok {
+		st = &jobState{}
+		d.state[job.Token] = st
+	}
+	base := Alert{Token: job.Token, JobName: job.JobName, At: at, Recipients: job.Notify}
+	if errRun == nil {
+		failures, alerted := st.failures, st.alerted
+		st.failures, st.alerted = 0, false
+		if failures == 0 || !alerted || !d.rules.Recovery {
+			return nil
+		}
+		base.Kind = KindRecovery
+		base.Failures = failures
+		return &base
+	}
+	st.failures++
+	fire := (d.rules.FirstFailure && st.failures == 1) || (d.rules.Consecutive > 0 && st.failures%d.rules.Consecutive == 0)
+	if !fire {
+		return nil
+	}
+	since := at.Sub(st.lastAlert)
+	if !st.lastAlert.IsZero() && d.rules.Throttle > 0 && since < d.rules.Throttle {
+		return nil
+	}
+	if !st.lastAlert.IsZero() && d.rules.Dedup > 0 && since < d.rules.Dedup && st.lastError == errRun.Error() {
+		return nil
+	}
+	st.alerted = true
+	st.lastAlert = at
+	st.lastError = errRun.Error()
+	base.Kind = KindFailure
+	base.Error = errRun.Error()
+	base.Failures = st.failures
+	return &base
+}
+
+// Send: the alert to every notifier and the url recipients, the errors of those that failed are returned
+func (d *Dispatcher) Send(alert Alert) (errs []error) {
+	d.mu.Lock()
+	targets := append([]Notifier{}, d.notifiers...)
+	d.mu.Unlock()
+	for _, recipient := range alert.Recipients {
+		switch {
+		case strings.HasPrefix(recipient, slackPrefix):
+			targets = append(targets, &Slack{URL: strings.TrimPrefix(recipient, slackPrefix)})
+		case strings.Contains(recipient, "://"):
+			targets = append(targets, &Webhook{URL: recipient})
+		}
+	}
+	for _, n := range targets {
+		if errNotify := n.Notify(alert); errNotify != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", n.WhichNotifier(), errNotify))
+		}
+	}
+	return
+}
+
+// Subject: one line about the alert
+func (a Alert) Subject() string {
+	name := a.Token
+	if len(a.JobName) > 0 {
+		name = fmt.Sprintf("%s (%s)", a.JobName, a.Token)
+	}
+	if a.Kind == KindMissed {
+		return fmt.Sprintf("axenda: %s missed its SLA", name)
+	}
+	if a.Kind == KindRecovery {
+		return fmt.Sprintf("axenda: %s recovered after %d failure(s)", name, a.Failures)
+	}
+	if a.Failures > 1 {
+		return fmt.Sprintf("axenda: %s failed %d times in a row", name, a.Failures)
+	}
+	return fmt.Sprintf("axenda: %s failed", name)
+}
+
+// Text: the subject, the time and the error
+func (a Alert) Text() string {
+	text := fmt.Sprintf("%s\nat: %s", a.Subject(), a.At.Format(time.RFC3339))
+	if len(a.Error) > 0 {
+		text += fmt.Sprintf("\nerror: %s", a.Error)
+	}
+	return text
+}
+
+// Emails: the recipients that are email addresses
+func (a Alert) Emails() (emails []string) {
+	for _, recipient := range a.Recipients {
+		if strings.Contains(recipient, "@") && !strings.Contains(recipient, "://") {
+			emails = append(emails, recipient)
+		}
+	}
+	return
+}
+
+// postJSON: post the body, any 2xx is a success
+func postJSON(url string, body interface{}) error {
+	bBody, errMarshal := json.Marshal(body)
+	if errMarshal != nil {
+		return errMarshal
+	}
+	client, errClient := util.HTTPClient()
+	if errClient != nil {
+		return errClient
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), SendTimeout)
+	defer cancel()
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(bBody))
+	if errReq != nil {
+		return errReq
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, errPost := client.Do(req)
+	if errPost != nil {
+		return errPost
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Unexpected code: %d, reason: %s", resp.StatusCode, resp.Status)
+	}
+	return nil
+}
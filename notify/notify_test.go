@@ -0,0 +1,145 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	j "github.com/keenfury/axenda/job"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserveFirstAndRecovery(t *testing.T) {
+	d := &Dispatcher{}
+	d.Set(Rules{FirstFailure: true, Recovery: true})
+	job := j.Job{Token: "TOKENNOTIFY"}
+	at := time.Now()
+	assert.Nil(t, d.Observe(job, nil, at), "No alert on success")
+	alert := d.Observe(job, fmt.Errorf("boom"), at)
+	assert.NotNil(t, alert, "Expected an alert on the first failure")
+	assert.Equal(t, KindFailure, alert.Kind)
+	assert.Nil(t, d.Observe(job, fmt.Errorf("boom"), at), "No alert on the second failure")
+	alert = d.Observe(job, nil, at)
+	assert.NotNil(t, alert, "Expected a recovery alert")
+	assert.Equal(t, KindRecovery, alert.Kind)
+	assert.Equal(t, 2, alert.Failures)
+}
+
+func TestObserveConsecutive(t *testing.T) {
+	d := &Dispatcher{}
+	d.Set(Rules{Consecutive: 3, Recovery: true})
+	job := j.Job{Token: "TOKENNOTIFY"}
+	at := time.Now()
+	assert.Nil(t, d.Observe(job, fmt.Errorf("boom"), at))
+	assert.Nil(t, d.Observe(job, nil, at), "No recovery without a failure alert")
+	for i := 1; i < 3; i++ {
+		assert.Nil(t, d.Observe(job, fmt.Errorf("boom"), at))
+	}
+	alert := d.Observe(job, fmt.Errorf("boom"), at)
+	assert.NotNil(t, alert, "Expected an alert on the third failure in a row")
+	assert.Equal(t, "axenda: TOKENNOTIFY failed 3 times in a row", alert.Subject())
+	for i := 1; i < 3; i++ {
+		assert.Nil(t, d.Observe(job, fmt.Errorf("boom"), at))
+	}
+	alert = d.Observe(job, fmt.Errorf("boom"), at)
+	assert.NotNil(t, alert, "Expected an alert again on the sixth failure in a row")
+	assert.Equal(t, 6, alert.Failures)
+}
+
+func TestObserveThrottleAndDedup(t *testing.T) {
+	d := &Dispatcher{}
+	d.Set(Rules{FirstFailure: true, Throttle: 10 * time.Minute, Dedup: time.Hour})
+	job := j.Job{Token: "TOKENNOTIFY"}
+	at := time.Now()
+	assert.NotNil(t, d.Observe(job, fmt.Errorf("boom"), at))
+	d.Observe(job, nil, at)
+	assert.Nil(t, d.Observe(job, fmt.Errorf("other"), at.Add(5*time.Minute)), "Expected the alert to be throttled")
+	d.Observe(job, nil, at)
+	assert.Nil(t, d.Observe(job, fmt.Errorf("boom"), at.Add(20*time.Minute)), "Expected the same error to be deduplicated")
+	d.Observe(job, nil, at)
+	assert.NotNil(t, d.Observe(job, fmt.Errorf("other"), at.Add(20*time.Minute)), "Expected a new error to be sent")
+}
+
+func TestSendRecipients(t *testing.T) {
+	bodies := make(chan map[string]interface{}, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body := map[string]interface{}{}
+		json.NewDecoder(req.Body).Decode(&body)
+		bodies <- body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	var mailTo []string
+	var mail string
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		mailTo, mail = to, string(msg)
+		return nil
+	}
+	defer func() { sendMail = sendMailTimeout }()
+
+	d := &Dispatcher{}
+	d.Set(Rules{FirstFailure: true}, &Webhook{URL: server.URL}, &SMTP{Addr: "localhost:25", From: "axenda@example.com", To: []string{"ops@example.com"}})
+	job := j.Job{Token: "TOKENNOTIFY", Notify: []string{"dev@example.com", "slack:" + server.URL}}
+	alert := d.Observe(job, fmt.Errorf("boom"), time.Now())
+	errs := d.Send(*alert)
+	assert.Equal(t, 0, len(errs), "No error expected")
+	webhook, slack := <-bodies, <-bodies
+	assert.Equal(t, "TOKENNOTIFY", webhook["token"])
+	assert.True(t, strings.HasPrefix(slack["text"].(string), "axenda: TOKENNOTIFY failed"))
+	assert.Equal(t, []string{"ops@example.com", "dev@example.com"}, mailTo)
+	assert.Contains(t, mail, "error: boom")
+}
+
+func TestSMTPHeaderInjection(t *testing.T) {
+	mailTo, mail := []string{}, ""
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		mailTo, mail = to, string(msg)
+		return nil
+	}
+	defer func() { sendMail = sendMailTimeout }()
+	d := &Dispatcher{}
+	d.Set(Rules{FirstFailure: true})
+	job := j.Job{Token: "TOKENNOTIFY", JobName: "Nightly\r\nBcc: evil@example.com", Notify: []string{"dev@example.com\r\nBcc: evil@example.com", "ops@example.com"}}
+	alert := d.Observe(job, fmt.Errorf("boom"), time.Now())
+	err := (&SMTP{Addr: "localhost:25", From: "axenda@example.com"}).Notify(*alert)
+	assert.NotNil(t, err, "Expected the address with a line break reported")
+	assert.Equal(t, []string{"ops@example.com"}, mailTo, "Expected the address with a line break skipped")
+	headers := strings.SplitN(mail, "\r\n\r\n", 2)[0]
+	assert.NotContains(t, headers, "\r\nBcc:", "Expected no header added")
+	assert.Equal(t, 3, strings.Count(headers, "\r\n"), "Expected From, To, Subject and Content-Type only")
+	assert.Contains(t, headers, "\r\nSubject: axenda: Nightly Bcc: evil@example.com (TOKENNOTIFY) failed\r\n")
+	assert.Equal(t, "=?utf-8?q?Caf=C3=A9_du_matin?=", headerValue("Café\r\ndu matin"), "Expected a non ascii subject encoded")
+}
+
+func TestSendFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	d := &Dispatcher{}
+	d.Set(Rules{}, &Slack{URL: server.URL})
+	errs := d.Send(Alert{Kind: KindFailure, Token: "TOKENNOTIFY"})
+	assert.Equal(t, 1, len(errs), "Expected the slack error")
+}
+
+func TestSendTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	SendTimeout = 50 * time.Millisecond
+	defer func() { SendTimeout = 10 * time.Second }()
+	d := &Dispatcher{}
+	d.Set(Rules{FirstFailure: true}, &Webhook{URL: server.URL})
+	start := time.Now()
+	errs := d.Send(Alert{Kind: KindFailure, Token: "TOKENNOTIFY", At: start})
+	assert.Equal(t, 1, len(errs), "Expected the hung webhook to time out")
+	assert.True(t, time.Since(start) < 2*time.Second)
+}
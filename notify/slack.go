@@ -0,0 +1,14 @@
+package notify
+
+// Slack: posts the alert's text to a Slack compatible incoming webhook (Mattermost, Rocket.Chat, ...)
+type Slack struct {
+	URL string
+}
+
+func (s *Slack) WhichNotifier() string {
+	return "Slack"
+}
+
+func (s *Slack) Notify(alert Alert) error {
+	return postJSON(s.URL, map[string]string{"text": alert.Text()})
+}
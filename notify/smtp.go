@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// sendMail: replaced in the tests
+var sendMail = sendMailTimeout
+
+// SMTP: emails the alert to To and the email recipients of the job, Addr is host:port, User/Password for plain auth
+type SMTP struct {
+	Addr     string
+	User     string
+	Password string
+	From     string
+	To       []string
+}
+
+func (s *SMTP) WhichNotifier() string {
+	return "SMTP"
+}
+
+// sendMailTimeout: smtp.SendMail with the connection and the whole exchange limited to SendTimeout
+func sendMailTimeout(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+	conn, errDial := net.DialTimeout("tcp", addr, SendTimeout)
+	if errDial != nil {
+		return errDial
+	}
+	conn.SetDeadline(time.Now().Add(SendTimeout))
+	host, _, _ := net.SplitHostPort(addr)
+	c, errClient := smtp.NewClient(conn, host)
+	if errClient != nil {
+		conn.Close()
+		return errClient
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if errTLS := c.StartTLS(&tls.Config{ServerName: host}); errTLS != nil {
+			return errTLS
+		}
+	}
+	if auth != nil {
+		if errAuth := c.Auth(auth); errAuth != nil {
+			return errAuth
+		}
+	}
+	if errMail := c.Mail(from); errMail != nil {
+		return errMail
+	}
+	for _, rcpt := range to {
+		if errRcpt := c.Rcpt(rcpt); errRcpt != nil {
+			return errRcpt
+		}
+	}
+	w, errData := c.Data()
+	if errData != nil {
+		return errData
+	}
+	if _, errWrite := w.Write(msg); errWrite != nil {
+		return errWrite
+	}
+	if errClose := w.Close(); errClose != nil {
+		return errClose
+	}
+	return c.Quit()
+}
+
+// Notify: the job name and addresses come from the job definitions, an address with a line break is skipped and
+// the subject is stripped of line breaks and MIME encoded so neither can add a header
+func (s *SMTP) Notify(alert Alert) (err error) {
+	to := []string{}
+	for _, address := range append(append([]string{}, s.To...), alert.Emails()...) {
+		if strings.ContainsAny(address, "\r\n") {
+			err = fmt.Errorf("Email address with a line break skipped: %q", address)
+			continue
+		}
+		to = append(to, address)
+	}
+	if len(to) == 0 {
+		// neither SCH_SMTP_TO nor the job has an email address
+		return
+	}
+	var auth smtp.Auth
+	if len(s.User) > 0 {
+		host, _, _ := net.SplitHostPort(s.Addr)
+		auth = smtp.PlainAuth("", s.User, s.Password, host)
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		s.From, strings.Join(to, ", "), headerValue(alert.Subject()), strings.ReplaceAll(alert.Text(), "\n", "\r\n"))
+	if errSend := sendMail(s.Addr, auth, s.From, to, []byte(msg)); errSend != nil {
+		return errSend
+	}
+	return
+}
+
+// headerValue: a single line, MIME encoded header value
+func headerValue(value string) string {
+	value = strings.Join(strings.FieldsFunc(value, func(r rune) bool { return r == '\r' || r == '\n' }), " ")
+	return mime.QEncoding.Encode("utf-8", value)
+}
@@ -0,0 +1,14 @@
+package notify
+
+// Webhook: posts the alert as json
+type Webhook struct {
+	URL string
+}
+
+func (w *Webhook) WhichNotifier() string {
+	return "Webhook"
+}
+
+func (w *Webhook) Notify(alert Alert) error {
+	return postJSON(w.URL, alert)
+}
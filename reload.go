@@ -0,0 +1,221 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/keenfury/axenda/config"
+	j "github.com/keenfury/axenda/job"
+	l "github.com/keenfury/axenda/logger"
+	r "github.com/keenfury/axenda/runner"
+)
+
+/*
+Hot reload of the configuration, on SIGHUP or when the configuration file changes.
+
+The configuration is loaded and validated again, an invalid configuration is logged and the current one is kept, the
+settings are swapped in under a lock once valid (see config.Load) so the readers never see half of a configuration.
+New adapters are built and swapped in, the jobs already running keep the adapters they started with and those
+adapters are closed (if they are an io.Closer) once all of their jobs are done, the circuits of the breaker are
+carried over. The changed settings are logged.
+Jobs only "Received" are dropped when the discovery changed, the new discovery hands them out again if they are due.
+The http addresses are only read at startup, a change to those is logged as needing a restart.
+*/
+
+// settings only read at startup
+var restartSettings = map[string]bool{"SCH_METRICS_ADDR": true, "SCH_HEALTH_ADDR": true, "SCH_ADMIN_ADDR": true, "SCH_DASHBOARD_ADDR": true, "SCH_CONFIG_FILE": true,
+	"SCH_TRACE_EXPORTER": true, "SCH_TRACE_OTLP_ENDPOINT": true, "SCH_TRACE_OTLP_INSECURE": true}
+
+var (
+	// adapterMu: guards the adapters, they are read by the http handlers and the running jobs
+	adapterMu sync.RWMutex
+	// inFlight: jobs running on the current adapters
+	inFlight = &sync.WaitGroup{}
+)
+
+func CurrentDiscovery() DiscoveryAdapter {
+	adapterMu.RLock()
+	defer adapterMu.RUnlock()
+	return discoveryAdapter
+}
+
+func CurrentRunner() r.RunnerAdapter {
+	adapterMu.RLock()
+	defer adapterMu.RUnlock()
+	return runnerAdapter
+}
+
+func CurrentHistory() HistoryAdapter {
+	adapterMu.RLock()
+	defer adapterMu.RUnlock()
+	return historyAdapter
+}
+
+// acquireAdapters: the adapters for a job about to run, call the returned done when the job is over
+func acquireAdapters() (runner r.RunnerAdapter, history HistoryAdapter, done func()) {
+	adapterMu.RLock()
+	defer adapterMu.RUnlock()
+	wg := inFlight
+	wg.Add(1)
+	return runnerAdapter, historyAdapter, wg.Done
+}
+
+// goInFlight: run fn in a goroutine counted with the jobs running on the current adapters, so it is waited for
+func goInFlight(fn func()) {
+	adapterMu.RLock()
+	wg := inFlight
+	wg.Add(1)
+	adapterMu.RUnlock()
+	go func() {
+		defer wg.Done()
+		fn()
+	}()
+}
+
+// waitInFlight: wait for the jobs running on the current adapters, e.g. the hooks of a job the cli triggered
+func waitInFlight() {
+	adapterMu.RLock()
+	wg := inFlight
+	adapterMu.RUnlock()
+	wg.Wait()
+}
+
+// SetAdapters: swap in new adapters, the old ones are closed once the jobs running on them are done
+func SetAdapters(runner r.RunnerAdapter, discovery DiscoveryAdapter, history HistoryAdapter) {
+	adapterMu.Lock()
+	oldWG := inFlight
+	old := []interface{}{runnerAdapter, discoveryAdapter, historyAdapter}
+	runnerAdapter = runner
+	discoveryAdapter = discovery
+	historyAdapter = history
+	inFlight = &sync.WaitGroup{}
+	adapterMu.Unlock()
+	go func() {
+		oldWG.Wait()
+		for _, adapter := range old {
+			if closer, ok := adapter.(io.Closer); ok {
+				if errClose := closer.Close(); errClose != nil {
+					logAdapter.Warn("Reload: unable to close old adapter", "error", errClose)
+				}
+			}
+		}
+	}()
+}
+
+// Reload: load the configuration again and rebuild the adapters, on error the current configuration is kept
+func Reload(jobs *[]j.Job) error {
+	before := config.Snapshot()
+	if errLoad := config.Load(config.ConfigFile); errLoad != nil {
+		return errLoad
+	}
+	changes := config.Diff(before, config.Snapshot())
+	if len(changes) == 0 {
+		logAdapter.Info("Reload: no changes")
+		return nil
+	}
+	runner := SetRunnerAdapter()
+	discovery, errDiscovery := SetDiscoveryAdapter(runner)
+	if errDiscovery != nil {
+		config.Restore(before)
+		return errDiscovery
+	}
+	l.Current.Set(SetLoggingAdapter())
+	SetAdapters(runner, discovery, SetHistoryAdapter())
+	SetDispatchSlots()
+	SetNotifier()
+	SetAuditAdapter()
+	for _, change := range changes {
+		setting := strings.SplitN(change, ":", 2)[0]
+		logAdapter.Info("Reload: setting changed", "setting", setting, "change", strings.TrimSpace(strings.TrimPrefix(change, setting+":")), "restart", restartSettings[setting])
+	}
+	if discoveryChanged(changes) {
+		DropReceived(jobs)
+	}
+	logAdapter.Info("Reload: using discovery", "discovery", discovery.WhichDiscovery())
+	return nil
+}
+
+// DropReceived: remove the jobs not started yet, they belong to the old discovery
+func DropReceived(jobs *[]j.Job) {
+	kept := (*jobs)[:0]
+	for _, job := range *jobs {
+		if job.Status == j.StatusReceived {
+			logAdapter.Info("Reload: dropped received job", "token", job.Token, "job_name", job.JobName)
+			continue
+		}
+		kept = append(kept, job)
+	}
+	*jobs = kept
+	SetJobGauges(*jobs)
+}
+
+func discoveryChanged(changes []string) bool {
+	for _, change := range changes {
+		for _, prefix := range []string{"SCH_DISCOVERY", "SCH_JOB_FILE_NAME", "SCH_JOB_STATE_FILE", "SCH_DB_", "SCH_API_", "SCH_GRPC_URL"} {
+			if strings.HasPrefix(change, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WatchReload: signal on reloadCh on SIGHUP and when the configuration file changes, a pending reload is not doubled
+func WatchReload(reloadCh chan<- struct{}) {
+	notify := func() {
+		select {
+		case reloadCh <- struct{}{}:
+		default:
+		}
+	}
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	go func() {
+		for range hupCh {
+			if errReopen := l.Current.Reopen(); errReopen != nil {
+				logAdapter.Error("Reload: unable to reopen the log file", "error", errReopen)
+			}
+			logAdapter.Info("Reload: SIGHUP received")
+			notify()
+		}
+	}()
+	if len(config.ConfigFile) == 0 {
+		return
+	}
+	watcher, errWatcher := fsnotify.NewWatcher()
+	if errWatcher != nil {
+		logAdapter.Error("WatchReload: unable to watch the configuration file", "error", errWatcher)
+		return
+	}
+	// watch the directory, editors replace the file instead of writing it
+	configFile := filepath.Clean(config.ConfigFile)
+	if errAdd := watcher.Add(filepath.Dir(configFile)); errAdd != nil {
+		logAdapter.Error("WatchReload: unable to watch the configuration file", "error", errAdd)
+		watcher.Close()
+		return
+	}
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == configFile && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					notify()
+				}
+			case errWatch, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logAdapter.Warn("WatchReload: watch error", "error", errWatch)
+			}
+		}
+	}()
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	h "github.com/keenfury/axenda/history"
+	"github.com/keenfury/axenda/util"
+)
+
+/*
+Run history export and reports, on the admin api and the command line (axenda history export|report):
+
+	GET /history/export   ?job=TOKEN &from=RFC3339 &to=RFC3339 (or &month=2020-04) &format=csv|jsonl
+	GET /history/report   same range, &top=10 longest runs
+*/
+
+const defaultReportTop = 10
+
+// HistoryRange: the range of from/to (RFC3339) or of a month (2020-04, in the configured time zone), zero is open ended
+func HistoryRange(from, to, month string) (start, end time.Time, err error) {
+	if len(month) > 0 {
+		if len(from) > 0 || len(to) > 0 {
+			err = fmt.Errorf("Set a month or from/to, not both")
+			return
+		}
+		if start, err = time.ParseInLocation("2006-01", month, util.GetLocation(time.Now())); err != nil {
+			err = fmt.Errorf("Invalid month, e.g. 2020-04: %s", err)
+			return
+		}
+		end = start.AddDate(0, 1, 0).Add(-time.Nanosecond)
+		return
+	}
+	if len(from) > 0 {
+		if start, err = time.Parse(time.RFC3339, from); err != nil {
+			err = fmt.Errorf("Invalid from: %s", err)
+			return
+		}
+	}
+	if len(to) > 0 {
+		if end, err = time.Parse(time.RFC3339, to); err != nil {
+			err = fmt.Errorf("Invalid to: %s", err)
+		}
+	}
+	return
+}
+
+// HistoryRecords: the records of the job (empty is all) within the range, oldest first
+func HistoryRecords(history HistoryAdapter, token string, from, to time.Time) ([]h.Record, error) {
+	records, errList := history.List(token, from, to)
+	if errList != nil {
+		return nil, errList
+	}
+	if records == nil {
+		records = []h.Record{}
+	}
+	return records, nil
+}
+
+func AdminHistoryHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method not allowed"))
+		return
+	}
+	query := req.URL.Query()
+	from, to, errRange := HistoryRange(query.Get("from"), query.Get("to"), query.Get("month"))
+	if errRange != nil {
+		writeError(w, http.StatusBadRequest, errRange)
+		return
+	}
+	records, errRecords := HistoryRecords(CurrentHistory(), query.Get("job"), from, to)
+	if errRecords != nil {
+		writeError(w, http.StatusInternalServerError, errRecords)
+		return
+	}
+	switch strings.Trim(strings.TrimPrefix(req.URL.Path, "/history"), "/") {
+	case "export":
+		format := query.Get("format")
+		switch format {
+		case h.FormatCSV:
+			w.Header().Set("Content-Type", "text/csv")
+		case h.FormatJSONLines, "":
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		default:
+			writeError(w, http.StatusBadRequest, fmt.Errorf("Unknown export format: %q, use csv or jsonl", format))
+			return
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"history-%s.%s\"", util.GetNow().Format("20060102T150405"), exportExt(format)))
+		if errExport := h.Export(w, records, format); errExport != nil {
+			logAdapter.Error("Admin: unable to export history", "error", errExport)
+		}
+	case "report":
+		top := defaultReportTop
+		if len(query.Get("top")) > 0 {
+			n, errAtoi := strconv.Atoi(query.Get("top"))
+			if errAtoi != nil || n < 0 {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("Invalid top: %q", query.Get("top")))
+				return
+			}
+			top = n
+		}
+		writeJSON(w, http.StatusOK, h.Summarize(records, from, to, top))
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("Not found"))
+	}
+}
+
+func exportExt(format string) string {
+	if format == h.FormatCSV {
+		return "csv"
+	}
+	return "jsonl"
+}
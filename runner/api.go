@@ -2,7 +2,11 @@ package runners
 
 import (
 	j "github.com/keenfury/axenda/job"
+	"github.com/keenfury/axenda/metrics"
+	"github.com/keenfury/axenda/tracing"
 	"github.com/keenfury/axenda/util"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 type API struct{}
@@ -10,8 +14,17 @@ type API struct{}
 func (a *API) WhichRunner() string {
 	return "API"
 }
-func (a *API) RunJob(job *j.Job) error {
-	hdrs := make(map[string]string, 1)
+func (a *API) RunJob(job *j.Job) (err error) {
+	ctx, span := tracing.Start(tracing.Context(job.TraceParent), "RunJob", attribute.String("runner", a.WhichRunner()), attribute.String("token", job.Token), attribute.String("url", job.UrlPath))
+	defer func() {
+		tracing.End(span, err)
+		metrics.ObserveRun(a.WhichRunner(), err)
+	}()
+	hdrs := make(map[string]string, 2)
 	hdrs["Content-Type"] = "application/json"
-	return util.SimpleRequest("POST", job.UrlPath, &job, nil, 204, hdrs)
+	// the traceparent header of this span
+	tracing.Inject(ctx, propagation.MapCarrier(hdrs))
+	response, errRequest := util.Request("POST", job.UrlPath, &job, 204, hdrs)
+	job.Result.Called(response)
+	return errRequest
 }
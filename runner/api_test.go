@@ -0,0 +1,68 @@
+package runners
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	j "github.com/keenfury/axenda/job"
+	"github.com/keenfury/axenda/metrics"
+	"github.com/keenfury/axenda/tracing"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestAPITraceParent(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	received := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		received = req.Header.Get("traceparent")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	ctx, parent := tracing.Start(context.Background(), "StartJob")
+	job := j.Job{Token: "TOKEN", UrlPath: server.URL, TraceParent: tracing.TraceParent(ctx)}
+	assert.Nil(t, (&API{}).RunJob(&job))
+	parent.End()
+	spans := recorder.Ended()
+	assert.Equal(t, 2, len(spans))
+	runJob := spans[0]
+	assert.Equal(t, "RunJob", runJob.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), runJob.Parent().SpanID(), "Expected RunJob a child of StartJob")
+	assert.True(t, strings.Contains(received, runJob.SpanContext().TraceID().String()), "Expected the trace sent to the target: %s", received)
+	assert.True(t, strings.Contains(received, runJob.SpanContext().SpanID().String()), "Expected RunJob as the target's parent: %s", received)
+}
+
+func TestAPIResponseResult(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(strings.Repeat("x", j.MaxResponse+10)))
+	}))
+	defer server.Close()
+	job := j.Job{Token: "TOKEN", UrlPath: server.URL, Result: &j.RunResult{}}
+	assert.NotNil(t, (&API{}).RunJob(&job))
+	assert.Equal(t, 1, job.Result.Attempts)
+	assert.Equal(t, j.MaxResponse, len(job.Result.Response), "Expected the response capped")
+}
+
+func TestAPIDispatchMetric(t *testing.T) {
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	success := testutil.ToFloat64(metrics.Dispatches.WithLabelValues("API", metrics.OutcomeSuccess))
+	failure := testutil.ToFloat64(metrics.Dispatches.WithLabelValues("API", metrics.OutcomeError))
+	job := j.Job{Token: "TOKEN", UrlPath: server.URL, Result: &j.RunResult{}}
+	assert.Nil(t, (&API{}).RunJob(&job))
+	status = http.StatusInternalServerError
+	assert.NotNil(t, (&API{}).RunJob(&job))
+	assert.Equal(t, success+1, testutil.ToFloat64(metrics.Dispatches.WithLabelValues("API", metrics.OutcomeSuccess)))
+	assert.Equal(t, failure+1, testutil.ToFloat64(metrics.Dispatches.WithLabelValues("API", metrics.OutcomeError)))
+}
@@ -0,0 +1,179 @@
+package runners
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/keenfury/axenda/clock"
+	j "github.com/keenfury/axenda/job"
+	"github.com/keenfury/axenda/metrics"
+	"github.com/keenfury/axenda/util"
+)
+
+/*
+Breaker wraps any RunnerAdapter with a circuit breaker per target, the target being the host of the job's url_path
+(or the url_path itself for gRPC style "host:port" values).
+
+- Closed: jobs run as normal, consecutive failures are counted
+- Open: after Threshold consecutive failures, jobs for that target fail fast until CoolDown has passed
+- Half-Open: after the CoolDown one job is let through as a probe, success closes the circuit, failure opens it again
+*/
+
+const (
+	BreakerClosed   = "Closed"
+	BreakerOpen     = "Open"
+	BreakerHalfOpen = "Half-Open"
+)
+
+// breakerValues: the axenda_breaker_state value of each state
+var breakerValues = map[string]float64{BreakerClosed: 0, BreakerHalfOpen: 1, BreakerOpen: 2}
+
+type (
+	Breaker struct {
+		Runner    RunnerAdapter
+		Threshold int
+		CoolDown  time.Duration
+		// OnChange: optional, called every time a target's circuit changes state
+		OnChange func(target, from, to string)
+		// Clock: optional, defaults to util.Clock
+		Clock clock.Clock
+
+		mu       sync.Mutex
+		circuits map[string]*circuit
+	}
+
+	circuit struct {
+		state    string
+		failures int
+		openedAt time.Time
+		probing  bool
+	}
+
+	BreakerOpenError struct {
+		Target string
+	}
+)
+
+func (e *BreakerOpenError) Error() string {
+	return fmt.Sprintf("Circuit open for target: %s", e.Target)
+}
+
+func (b *Breaker) WhichRunner() string {
+	return fmt.Sprintf("%s with breaker", b.Runner.WhichRunner())
+}
+
+func (b *Breaker) RunJob(job *j.Job) error {
+	target := BreakerTarget(job.UrlPath)
+	if !b.allow(target) {
+		err := &BreakerOpenError{Target: target}
+		metrics.ObserveRun(b.Runner.WhichRunner(), err)
+		return err
+	}
+	err := b.Runner.RunJob(job)
+	b.result(target, err == nil)
+	return err
+}
+
+// States: snapshot of the current state of each known target
+func (b *Breaker) States() map[string]string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	states := make(map[string]string, len(b.circuits))
+	for target, c := range b.circuits {
+		states[target] = c.state
+	}
+	return states
+}
+
+// CarryOver: take the circuits of the breaker being replaced (e.g. on a reload) so open circuits stay open, a probe
+// still running on the old breaker is not waited for, the next job of that target is the probe
+func (b *Breaker) CarryOver(old *Breaker) {
+	old.mu.Lock()
+	circuits := make(map[string]*circuit, len(old.circuits))
+	for target, c := range old.circuits {
+		carried := *c
+		if carried.probing {
+			carried.state = BreakerOpen
+			carried.probing = false
+		}
+		circuits[target] = &carried
+	}
+	old.mu.Unlock()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.circuits = circuits
+}
+
+func (b *Breaker) allow(target string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.getCircuit(target)
+	switch c.state {
+	case BreakerOpen:
+		if util.NowFrom(b.Clock).Sub(c.openedAt) < b.CoolDown {
+			return false
+		}
+		b.setState(target, c, BreakerHalfOpen)
+		c.probing = true
+		return true
+	case BreakerHalfOpen:
+		// only one probe at a time
+		if c.probing {
+			return false
+		}
+		c.probing = true
+	}
+	return true
+}
+
+func (b *Breaker) result(target string, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.getCircuit(target)
+	c.probing = false
+	if success {
+		c.failures = 0
+		b.setState(target, c, BreakerClosed)
+		return
+	}
+	c.failures++
+	if c.state == BreakerHalfOpen || c.failures >= b.Threshold {
+		c.openedAt = util.NowFrom(b.Clock)
+		b.setState(target, c, BreakerOpen)
+	}
+}
+
+func (b *Breaker) getCircuit(target string) *circuit {
+	if b.circuits == nil {
+		b.circuits = make(map[string]*circuit)
+	}
+	c, ok := b.circuits[target]
+	if !ok {
+		c = &circuit{state: BreakerClosed}
+		b.circuits[target] = c
+	}
+	return c
+}
+
+func (b *Breaker) setState(target string, c *circuit, state string) {
+	if c.state == state {
+		return
+	}
+	from := c.state
+	c.state = state
+	metrics.SetBreakerState(target, breakerValues[state])
+	if b.OnChange != nil {
+		b.OnChange(target, from, state)
+	}
+}
+
+// BreakerTarget: key used for the circuit, host of the url or the raw value if it has no host
+func BreakerTarget(urlPath string) string {
+	u, errParse := url.Parse(urlPath)
+	if errParse != nil || len(u.Host) == 0 {
+		return urlPath
+	}
+	return u.Host
+}
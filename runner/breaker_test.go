@@ -0,0 +1,119 @@
+package runners
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/keenfury/axenda/clock"
+	j "github.com/keenfury/axenda/job"
+	"github.com/keenfury/axenda/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type failRunner struct {
+	fail  bool
+	calls int
+}
+
+func (f *failRunner) WhichRunner() string {
+	return "Fail"
+}
+
+func (f *failRunner) RunJob(job *j.Job) error {
+	f.calls++
+	if f.fail {
+		return fmt.Errorf("Runner failure")
+	}
+	return nil
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	runner := &failRunner{fail: true}
+	breaker := Breaker{Runner: runner, Threshold: 2, CoolDown: time.Hour}
+	job := j.Job{UrlPath: "http://localhost:12572/run_job"}
+	assert.NotNil(t, breaker.RunJob(&job))
+	assert.NotNil(t, breaker.RunJob(&job))
+	assert.Equal(t, BreakerOpen, breaker.States()["localhost:12572"])
+	err := breaker.RunJob(&job)
+	assert.IsType(t, &BreakerOpenError{}, err, "Expected to fail fast")
+	assert.Equal(t, 2, runner.calls, "Runner should not be called while open")
+}
+
+func TestBreakerHalfOpenProbeSuccess(t *testing.T) {
+	runner := &failRunner{fail: true}
+	changes := []string{}
+	fake := clock.NewFake(time.Now())
+	breaker := Breaker{Runner: runner, Threshold: 1, CoolDown: time.Minute, Clock: fake, OnChange: func(target, from, to string) {
+		changes = append(changes, to)
+	}}
+	job := j.Job{UrlPath: "localhost:12500"}
+	assert.NotNil(t, breaker.RunJob(&job))
+	fake.Add(30 * time.Second)
+	assert.IsType(t, &BreakerOpenError{}, breaker.RunJob(&job), "Expected to fail fast during the cool down")
+	fake.Add(30 * time.Second)
+	runner.fail = false
+	assert.Nil(t, breaker.RunJob(&job), "Probe should be let through")
+	assert.Equal(t, BreakerClosed, breaker.States()["localhost:12500"])
+	assert.Equal(t, []string{BreakerOpen, BreakerHalfOpen, BreakerClosed}, changes)
+}
+
+func TestBreakerHalfOpenProbeFailure(t *testing.T) {
+	runner := &failRunner{fail: true}
+	fake := clock.NewFake(time.Now())
+	breaker := Breaker{Runner: runner, Threshold: 3, CoolDown: time.Minute, Clock: fake}
+	job := j.Job{UrlPath: "http://localhost:12572/run_job"}
+	for i := 0; i < 3; i++ {
+		breaker.RunJob(&job)
+	}
+	fake.Add(time.Minute)
+	assert.NotNil(t, breaker.RunJob(&job))
+	assert.Equal(t, BreakerOpen, breaker.States()["localhost:12572"], "A failed probe should open the circuit again")
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	runner := &failRunner{fail: true}
+	breaker := Breaker{Runner: runner, Threshold: 2, CoolDown: time.Hour}
+	job := j.Job{UrlPath: "http://localhost:12572/run_job"}
+	breaker.RunJob(&job)
+	runner.fail = false
+	breaker.RunJob(&job)
+	runner.fail = true
+	breaker.RunJob(&job)
+	assert.Equal(t, BreakerClosed, breaker.States()["localhost:12572"])
+}
+
+func TestBreakerCarryOver(t *testing.T) {
+	runner := &failRunner{fail: true}
+	old := &Breaker{Runner: runner, Threshold: 1, CoolDown: time.Hour}
+	job := j.Job{UrlPath: "http://localhost:12572/run_job"}
+	assert.NotNil(t, old.RunJob(&job))
+	breaker := &Breaker{Runner: runner, Threshold: 1, CoolDown: time.Hour}
+	breaker.CarryOver(old)
+	assert.Equal(t, BreakerOpen, breaker.States()["localhost:12572"], "Expected the open circuit to be kept")
+	assert.IsType(t, &BreakerOpenError{}, breaker.RunJob(&job), "Expected to fail fast")
+	assert.Equal(t, 1, runner.calls)
+}
+
+func TestBreakerMetrics(t *testing.T) {
+	runner := &failRunner{fail: true}
+	fake := clock.NewFake(time.Now())
+	breaker := Breaker{Runner: runner, Threshold: 1, CoolDown: time.Minute, Clock: fake}
+	job := j.Job{UrlPath: "http://localhost:12590/run_job"}
+	state := metrics.BreakerState.WithLabelValues("localhost:12590")
+	refused := testutil.ToFloat64(metrics.Dispatches.WithLabelValues("Fail", metrics.OutcomeError))
+	assert.NotNil(t, breaker.RunJob(&job))
+	assert.Equal(t, 2.0, testutil.ToFloat64(state), "Expected the circuit open")
+	assert.IsType(t, &BreakerOpenError{}, breaker.RunJob(&job))
+	assert.Equal(t, refused+1, testutil.ToFloat64(metrics.Dispatches.WithLabelValues("Fail", metrics.OutcomeError)), "Expected the refused run counted")
+	fake.Add(time.Minute)
+	runner.fail = false
+	assert.Nil(t, breaker.RunJob(&job))
+	assert.Equal(t, 0.0, testutil.ToFloat64(state), "Expected the circuit closed")
+}
+
+func TestBreakerWhich(t *testing.T) {
+	breaker := Breaker{Runner: &Mock{}}
+	assert.Equal(t, "Mock with breaker", breaker.WhichRunner())
+}
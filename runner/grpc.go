@@ -1,12 +1,16 @@
 package runners
 
 import (
-	"context"
 	"time"
 
 	"github.com/keenfury/axenda/discovery/proto"
 	j "github.com/keenfury/axenda/job"
+	"github.com/keenfury/axenda/metrics"
+	"github.com/keenfury/axenda/tracing"
+	"github.com/keenfury/axenda/util"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 type GRPC struct{}
@@ -15,8 +19,16 @@ func (g *GRPC) WhichRunner() string {
 	return "GRPC"
 }
 
-func (g *GRPC) RunJob(job *j.Job) error {
-	opts := grpc.WithInsecure()
+func (g *GRPC) RunJob(job *j.Job) (err error) {
+	ctx, span := tracing.Start(tracing.Context(job.TraceParent), "RunJob", attribute.String("runner", g.WhichRunner()), attribute.String("token", job.Token), attribute.String("url", job.UrlPath))
+	defer func() {
+		tracing.End(span, err)
+		metrics.ObserveRun(g.WhichRunner(), err)
+	}()
+	opts, errOpts := util.GRPCDialOption()
+	if errOpts != nil {
+		return errOpts
+	}
 	srv, errDial := grpc.Dial(job.UrlPath, opts)
 	if errDial != nil {
 		return errDial
@@ -29,9 +41,15 @@ func (g *GRPC) RunJob(job *j.Job) error {
 	}
 	pJob := &proto.Job{Token: job.Token, JobName: job.JobName, Runtime: job.RunTime.Format(time.RFC3339), Frequency: int32(job.Frequency), Payload: bPayload, Active: job.Active}
 	req := proto.JobRunRequest{Job: pJob}
-	_, errResp := cli.RunJob(context.Background(), &req)
+	// the traceparent metadata of this span
+	if traceParent := tracing.TraceParent(ctx); len(traceParent) > 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, "traceparent", traceParent)
+	}
+	resp, errResp := cli.RunJob(ctx, &req)
 	if errResp != nil {
+		job.Result.Called(nil)
 		return errResp
 	}
+	job.Result.Called([]byte(resp.GetMessage()))
 	return nil
 }
@@ -1,9 +1,11 @@
 package runners
 
 import (
-	"fmt"
-
 	j "github.com/keenfury/axenda/job"
+	l "github.com/keenfury/axenda/logger"
+	"github.com/keenfury/axenda/metrics"
+	"github.com/keenfury/axenda/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Mock struct{}
@@ -13,6 +15,10 @@ func (m *Mock) WhichRunner() string {
 }
 
 func (m *Mock) RunJob(job *j.Job) error {
-	fmt.Println("Running this url:", job.UrlPath)
+	_, span := tracing.Start(tracing.Context(job.TraceParent), "RunJob", attribute.String("runner", m.WhichRunner()), attribute.String("token", job.Token))
+	defer tracing.End(span, nil)
+	l.Info("Mock: running this url", "token", job.Token, "url", job.UrlPath)
+	job.Result.Called(nil)
+	metrics.ObserveRun(m.WhichRunner(), nil)
 	return nil
 }
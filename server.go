@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+)
+
+// the scheduler's http endpoints, grouped by listen address so features can share a port
+var serveMuxes = map[string]*http.ServeMux{}
+
+// HandleHTTP: register the handler for the pattern on the given address, nothing is served until StartHTTP
+func HandleHTTP(addr, pattern string, handler http.Handler) {
+	mux, ok := serveMuxes[addr]
+	if !ok {
+		mux = http.NewServeMux()
+		serveMuxes[addr] = mux
+	}
+	mux.Handle(pattern, handler)
+}
+
+// StartHTTP: serve each address with its registered handlers
+func StartHTTP() {
+	for addr, mux := range serveMuxes {
+		go func(addr string, mux *http.ServeMux) {
+			logAdapter.Info("Serving http", "addr", addr)
+			if errServe := http.ListenAndServe(addr, mux); errServe != nil {
+				logAdapter.Error("StartHTTP: server stopped", "addr", addr, "error", errServe)
+			}
+		}(addr, mux)
+	}
+}
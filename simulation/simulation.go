@@ -0,0 +1,146 @@
+package simulation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/keenfury/axenda/config"
+	fr "github.com/keenfury/axenda/frequency"
+	j "github.com/keenfury/axenda/job"
+	"github.com/keenfury/axenda/util"
+)
+
+/*
+Replay the scheduler over a window of time with a virtual clock, no runner is called.
+
+Every minute of the window the jobs due within the discovery's look ahead (config.GetLookahead) are "Received", the ones whose RunTime
+has come are fired ("In Process" => "Done") and moved along with their frequency, the same way CompleteJob does.
+Minutes where nothing can happen are skipped.  A replay stops with an error past MaxEvents, many minute jobs over a long
+window would otherwise fill the memory.
+*/
+
+const (
+	EventStatus   = "Status"
+	EventFire     = "Fire"
+	EventSchedule = "Schedule"
+	EventError    = "Error"
+
+	// MaxEvents: the most events a Run returns
+	MaxEvents = 100000
+)
+
+type (
+	Event struct {
+		At      time.Time `json:"at"`
+		Kind    string    `json:"kind"`
+		Token   string    `json:"token"`
+		JobName string    `json:"job_name"`
+		From    j.Status  `json:"from,omitempty"`
+		To      j.Status  `json:"to,omitempty"`
+		RunTime time.Time `json:"run_time"`
+		Detail  string    `json:"detail,omitempty"`
+	}
+)
+
+// Run: simulate the jobs from/to, returns the events in order
+func Run(jobs []j.Job, from, to time.Time) (events []Event, err error) {
+	if to.Before(from) {
+		err = fmt.Errorf("End of window before start")
+		return
+	}
+	sim := make([]j.Job, len(jobs))
+	copy(sim, jobs)
+	t := util.TruncateTimeToMinute(from)
+	for !t.After(to) {
+		for i := range sim {
+			events = append(events, tick(&sim[i], t)...)
+		}
+		if len(events) > MaxEvents {
+			err = fmt.Errorf("More than %d events, shorten the window or pick a job", MaxEvents)
+			events = nil
+			return
+		}
+		next, ok := nextTick(sim, t)
+		if !ok {
+			break
+		}
+		t = next
+	}
+	return
+}
+
+// FireTimes: only the fire events of Run
+func FireTimes(events []Event) (fires []Event) {
+	for _, e := range events {
+		if e.Kind == EventFire {
+			fires = append(fires, e)
+		}
+	}
+	return
+}
+
+func tick(job *j.Job, t time.Time) (events []Event) {
+	if len(job.DependsOn) > 0 {
+		// runs within a workflow, not on its schedule
+		return
+	}
+	if job.Status == j.StatusNone {
+		if !job.Active || job.RunTime.After(t.Add(config.GetLookahead())) {
+			return
+		}
+		events = append(events, setStatus(job, j.StatusReceived, t))
+	}
+	if job.Status != j.StatusReceived || job.RunTime.After(t) {
+		return
+	}
+	events = append(events, setStatus(job, j.StatusInProcess, t))
+	events = append(events, Event{At: t, Kind: EventFire, Token: job.Token, JobName: job.JobName, RunTime: job.RunTime})
+	events = append(events, setStatus(job, j.StatusDone, t))
+	previous := job.RunTime
+	if errUpdate := fr.UpdateAt(job, t); errUpdate != nil {
+		events = append(events, Event{At: t, Kind: EventError, Token: job.Token, JobName: job.JobName, RunTime: job.RunTime, Detail: errUpdate.Error()})
+		// the job would be picked up again by discovery and fail the same way, stop here
+		job.Active = false
+	} else {
+		detail := fmt.Sprintf("%s => %s", previous.Format(time.RFC3339), job.RunTime.Format(time.RFC3339))
+		if !job.Active {
+			detail = "inactive"
+		}
+		events = append(events, Event{At: t, Kind: EventSchedule, Token: job.Token, JobName: job.JobName, RunTime: job.RunTime, Detail: detail})
+	}
+	// back to discovery for the next run
+	job.Status = j.StatusNone
+	job.Transitions = nil
+	return
+}
+
+func setStatus(job *j.Job, to j.Status, t time.Time) Event {
+	e := Event{At: t, Kind: EventStatus, Token: job.Token, JobName: job.JobName, From: job.Status, To: to, RunTime: job.RunTime}
+	job.SetStatus(to, t, "")
+	return e
+}
+
+// nextTick: the next minute something can happen, skipping the quiet ones, false if nothing is left to run
+func nextTick(jobs []j.Job, t time.Time) (time.Time, bool) {
+	next := t.Add(time.Minute)
+	var earliest time.Time
+	for _, job := range jobs {
+		if !job.Active && job.Status == j.StatusNone {
+			continue
+		}
+		due := util.TruncateTimeToMinute(job.RunTime).Add(-config.GetLookahead())
+		if job.Status != j.StatusNone {
+			due = util.TruncateTimeToMinute(job.RunTime)
+		}
+		if earliest.IsZero() || due.Before(earliest) {
+			earliest = due
+		}
+	}
+	if earliest.IsZero() {
+		return t, false
+	}
+	if earliest.After(next) {
+		return earliest, true
+	}
+	return next, true
+}
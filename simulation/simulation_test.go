@@ -0,0 +1,67 @@
+package simulation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/keenfury/axenda/config"
+	fr "github.com/keenfury/axenda/frequency"
+	j "github.com/keenfury/axenda/job"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunDailySuccess(t *testing.T) {
+	from, _ := time.Parse(time.RFC3339, "2020-04-23T00:00:00-06:00")
+	runTime, _ := time.Parse(time.RFC3339, "2020-04-23T12:24:00-06:00")
+	jobs := []j.Job{{Token: "TOKENSIM", RunTime: runTime, Frequency: fr.Daily, Active: true}}
+	events, err := Run(jobs, from, from.AddDate(0, 0, 3))
+	assert.Nil(t, err, "No error expected")
+	fires := FireTimes(events)
+	assert.Equal(t, 3, len(fires), "Expected a fire per day")
+	assert.True(t, runTime.AddDate(0, 0, 2).Equal(fires[2].At))
+	assert.Equal(t, j.StatusReceived, events[0].To, "Expected the job to be received first")
+	assert.True(t, runTime.Add(-config.GetLookahead()).Equal(events[0].At), "Expected the job to be received within the look ahead")
+	assert.True(t, runTime.Equal(jobs[0].RunTime), "Jobs passed in should not change")
+}
+
+func TestRunOnceSuccess(t *testing.T) {
+	from, _ := time.Parse(time.RFC3339, "2020-04-23T00:00:00-06:00")
+	runTime, _ := time.Parse(time.RFC3339, "2020-04-23T00:10:00-06:00")
+	jobs := []j.Job{{Token: "TOKENSIM", RunTime: runTime, Frequency: fr.Once, Active: true}}
+	events, err := Run(jobs, from, from.AddDate(1, 0, 0))
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 1, len(FireTimes(events)), "Expected a single fire")
+	assert.Equal(t, "inactive", events[len(events)-1].Detail)
+}
+
+func TestRunQuarterlySuccess(t *testing.T) {
+	from, _ := time.Parse(time.RFC3339, "2020-01-01T00:00:00-06:00")
+	runTime, _ := time.Parse(time.RFC3339, "2020-01-01T06:00:00-06:00")
+	jobs := []j.Job{{Token: "TOKENSIM", RunTime: runTime, Frequency: fr.Quarterly, Active: true}}
+	events, err := Run(jobs, from, from.AddDate(1, 0, 0))
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 4, len(FireTimes(events)), "Expected a run every three months")
+}
+
+func TestRunMaxEventsFailure(t *testing.T) {
+	from, _ := time.Parse(time.RFC3339, "2020-01-01T00:00:00-06:00")
+	jobs := []j.Job{{Token: "TOKENSIM", RunTime: from, Frequency: fr.Minute, Active: true}}
+	events, err := Run(jobs, from, from.AddDate(0, 1, 0))
+	assert.NotNil(t, err, "Error expected")
+	assert.Contains(t, err.Error(), "More than 100000 events")
+	assert.Nil(t, events)
+}
+
+func TestRunInvalidFrequencyFailure(t *testing.T) {
+	from, _ := time.Parse(time.RFC3339, "2020-04-23T00:00:00-06:00")
+	jobs := []j.Job{{Token: "TOKENSIM", RunTime: from, Frequency: 10, Active: true}}
+	events, err := Run(jobs, from, from.Add(time.Hour))
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, EventError, events[len(events)-1].Kind)
+}
+
+func TestRunWindowFailure(t *testing.T) {
+	from := time.Now()
+	_, err := Run(nil, from, from.Add(-time.Hour))
+	assert.NotNil(t, err, "Error expected")
+}
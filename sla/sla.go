@@ -0,0 +1,267 @@
+package sla
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	fr "github.com/keenfury/axenda/frequency"
+	h "github.com/keenfury/axenda/history"
+	j "github.com/keenfury/axenda/job"
+)
+
+/*
+SLA expectations of a job, checked by the Watchdog against the run history no matter why a run is missing (discovery
+failing, the scheduler down, a runner hanging...):
+- start_within: each run starts within this duration of its run_time, e.g. "5m"
+- succeed_by: each run succeeded by the next time of day at or after its run_time, e.g. "06:00"
+
+	{"token":"NIGHTLY", "run_time":"2020-04-23T02:00:00-06:00", "frequency":4, "start_within":"5m", "succeed_by":"06:00", ...}
+
+The Watchdog tracks each run (occurrence) of a job from the job definitions it is handed and once an occurrence is
+checked the next one is projected with the job's frequency, so a job that discovery stops handing out is still
+expected.  A full list of the jobs (Track with full) is authoritative: jobs not in it are forgotten and projected
+occurrences still to come are replaced by the job's run_time.  Each occurrence is reported at most once per expectation.
+*/
+
+const (
+	ExpectStart   = "start_within"
+	ExpectSuccess = "succeed_by"
+
+	// occurrences kept per job, the oldest are dropped
+	maxOccurrences = 10
+)
+
+type (
+	Expectation struct {
+		StartWithin time.Duration
+		// SucceedBy: time of day "15:04", empty if not set
+		SucceedBy string
+		hour      int
+		minute    int
+	}
+
+	Miss struct {
+		Token       string    `json:"token"`
+		JobName     string    `json:"job_name"`
+		Expectation string    `json:"expectation"`
+		Scheduled   time.Time `json:"scheduled_time"`
+		Detail      string    `json:"detail"`
+		Job         j.Job     `json:"-"`
+	}
+
+	// Lister: where the run history is read from, e.g. a history store
+	Lister interface {
+		List(string, time.Time, time.Time) ([]h.Record, error)
+	}
+
+	Watchdog struct {
+		mu          sync.Mutex
+		jobs        map[string]j.Job
+		occurrences map[string][]*occurrence
+		// checked: the last occurrence checked by job, older run times are not tracked again
+		checked map[string]time.Time
+	}
+
+	occurrence struct {
+		at          time.Time
+		projected   bool
+		startDone   bool
+		successDone bool
+	}
+)
+
+// Parse: the expectations of the job
+func Parse(job j.Job) (exp Expectation, err error) {
+	if len(job.StartWithin) > 0 {
+		d, errParse := time.ParseDuration(job.StartWithin)
+		if errParse != nil || d <= 0 {
+			err = fmt.Errorf("Invalid start_within: %q, must be a positive duration e.g. \"5m\"", job.StartWithin)
+			return
+		}
+		exp.StartWithin = d
+	}
+	if len(job.SucceedBy) > 0 {
+		t, errParse := time.Parse("15:04", job.SucceedBy)
+		if errParse != nil {
+			err = fmt.Errorf("Invalid succeed_by: %q, must be a time of day e.g. \"06:00\"", job.SucceedBy)
+			return
+		}
+		exp.SucceedBy = job.SucceedBy
+		exp.hour, exp.minute = t.Hour(), t.Minute()
+	}
+	return
+}
+
+// Empty: no expectation set
+func (e Expectation) Empty() bool {
+	return e.StartWithin == 0 && len(e.SucceedBy) == 0
+}
+
+// Deadline: the first SucceedBy time of day at or after the run time, in the run time's location
+func (e Expectation) Deadline(runTime time.Time) time.Time {
+	deadline := time.Date(runTime.Year(), runTime.Month(), runTime.Day(), e.hour, e.minute, 0, 0, runTime.Location())
+	if deadline.Before(runTime) {
+		deadline = deadline.AddDate(0, 0, 1)
+	}
+	return deadline
+}
+
+// Track: the jobs handed out by discovery, full when it is every job (e.g. a job store's list)
+func (w *Watchdog) Track(jobs []j.Job, full bool, now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.init()
+	seen := map[string]bool{}
+	for _, job := range jobs {
+		exp, errParse := Parse(job)
+		if errParse != nil || exp.Empty() || !job.Active || len(job.DependsOn) > 0 || job.RunTime.IsZero() {
+			continue
+		}
+		seen[job.Token] = true
+		w.jobs[job.Token] = job
+		if full {
+			// the store knows the next run, drop what was projected after now
+			kept := []*occurrence{}
+			for _, o := range w.occurrences[job.Token] {
+				if o.projected && o.at.After(now) && !o.at.Equal(job.RunTime) {
+					continue
+				}
+				kept = append(kept, o)
+			}
+			w.occurrences[job.Token] = kept
+		}
+		w.add(job.Token, job.RunTime, false)
+	}
+	if !full {
+		return
+	}
+	for token := range w.jobs {
+		if !seen[token] {
+			delete(w.jobs, token)
+			delete(w.occurrences, token)
+			delete(w.checked, token)
+		}
+	}
+}
+
+// Check: the expectations missed as of now, each one reported once
+func (w *Watchdog) Check(now time.Time, history Lister) (misses []Miss, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.init()
+	tokens := make([]string, 0, len(w.occurrences))
+	for token := range w.occurrences {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	for _, token := range tokens {
+		occurrences := w.occurrences[token]
+		if len(occurrences) == 0 || occurrences[0].at.After(now) {
+			continue
+		}
+		records, errList := history.List(token, occurrences[0].at, time.Time{})
+		if errList != nil {
+			// can't tell, try again on the next check
+			err = errList
+			continue
+		}
+		job := w.jobs[token]
+		exp, _ := Parse(job)
+		kept := []*occurrence{}
+		for _, o := range occurrences {
+			misses = append(misses, o.check(job, exp, records, now)...)
+			if !o.startDone || !o.successDone {
+				kept = append(kept, o)
+				continue
+			}
+			w.checked[token] = o.at
+			if o == occurrences[len(occurrences)-1] {
+				// next run by the job's frequency, in case discovery never hands it out
+				next := job
+				next.RunTime = o.at
+				if errUpdate := fr.UpdateAt(&next, o.at); errUpdate == nil && next.Active {
+					kept = append(kept, &occurrence{at: next.RunTime, projected: true})
+				}
+			}
+		}
+		w.occurrences[token] = kept
+	}
+	return
+}
+
+// check: the expectations of the occurrence missed as of now, those decided are set done
+func (o *occurrence) check(job j.Job, exp Expectation, records []h.Record, now time.Time) (misses []Miss) {
+	var started, succeeded *h.Record
+	for i := range records {
+		record := records[i]
+		if !record.Scheduled.Equal(o.at) {
+			continue
+		}
+		if started == nil || record.Start.Before(started.Start) {
+			started = &record
+		}
+		if record.Result == h.ResultSuccess && (succeeded == nil || record.End.Before(succeeded.End)) {
+			succeeded = &record
+		}
+	}
+	miss := func(expectation, format string, args ...interface{}) {
+		misses = append(misses, Miss{Token: job.Token, JobName: job.JobName, Expectation: expectation, Scheduled: o.at, Detail: fmt.Sprintf(format, args...), Job: job})
+	}
+	if exp.StartWithin == 0 {
+		o.startDone = true
+	}
+	if !o.startDone {
+		switch {
+		case started != nil:
+			o.startDone = true
+			if late := started.Start.Sub(o.at); late > exp.StartWithin {
+				miss(ExpectStart, "run of %s started %s late, expected within %s", o.at.Format(time.RFC3339), late.Round(time.Second), exp.StartWithin)
+			}
+		case !now.Before(o.at.Add(exp.StartWithin)):
+			o.startDone = true
+			miss(ExpectStart, "run of %s did not start within %s", o.at.Format(time.RFC3339), exp.StartWithin)
+		}
+	}
+	if len(exp.SucceedBy) == 0 {
+		o.successDone = true
+	}
+	if !o.successDone {
+		deadline := exp.Deadline(o.at)
+		switch {
+		case succeeded != nil && !succeeded.End.After(deadline):
+			o.successDone = true
+		case !now.Before(deadline):
+			o.successDone = true
+			miss(ExpectSuccess, "run of %s had not succeeded by %s", o.at.Format(time.RFC3339), deadline.Format(time.RFC3339))
+		}
+	}
+	return
+}
+
+// add: track the occurrence unless it is known or already checked
+func (w *Watchdog) add(token string, at time.Time, projected bool) {
+	if checked, ok := w.checked[token]; ok && !at.After(checked) {
+		return
+	}
+	for _, o := range w.occurrences[token] {
+		if o.at.Equal(at) {
+			return
+		}
+	}
+	occurrences := append(w.occurrences[token], &occurrence{at: at, projected: projected})
+	sort.Slice(occurrences, func(a, b int) bool { return occurrences[a].at.Before(occurrences[b].at) })
+	if len(occurrences) > maxOccurrences {
+		occurrences = occurrences[len(occurrences)-maxOccurrences:]
+	}
+	w.occurrences[token] = occurrences
+}
+
+func (w *Watchdog) init() {
+	if w.jobs == nil {
+		w.jobs = map[string]j.Job{}
+		w.occurrences = map[string][]*occurrence{}
+		w.checked = map[string]time.Time{}
+	}
+}
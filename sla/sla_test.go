@@ -0,0 +1,90 @@
+package sla
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	fr "github.com/keenfury/axenda/frequency"
+	h "github.com/keenfury/axenda/history"
+	j "github.com/keenfury/axenda/job"
+	"github.com/stretchr/testify/assert"
+)
+
+func testJob(runTime time.Time) j.Job {
+	return j.Job{Token: "TOKENSLA", RunTime: runTime, Frequency: fr.Daily, Active: true, StartWithin: "5m", SucceedBy: "06:00"}
+}
+
+func saveRun(history *h.Memory, job j.Job, start, end time.Time, err error) {
+	record := h.NewRecord(job, "Mock", start)
+	record.Finish(end, err)
+	history.Save(record)
+}
+
+func TestCheckNotStarted(t *testing.T) {
+	runTime, _ := time.Parse(time.RFC3339, "2020-04-23T02:00:00-06:00")
+	history := &h.Memory{}
+	w := &Watchdog{}
+	w.Track([]j.Job{testJob(runTime)}, false, runTime)
+	misses, err := w.Check(runTime.Add(4*time.Minute), history)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(misses), "Still within start_within")
+	misses, _ = w.Check(runTime.Add(5*time.Minute), history)
+	assert.Equal(t, 1, len(misses), "Expected the start to be missed")
+	assert.Equal(t, ExpectStart, misses[0].Expectation)
+	misses, _ = w.Check(runTime.Add(10*time.Minute), history)
+	assert.Equal(t, 0, len(misses), "Expected the miss to be reported once")
+	misses, _ = w.Check(runTime.Add(4*time.Hour), history)
+	assert.Equal(t, 1, len(misses), "Expected the deadline to be missed")
+	assert.Equal(t, ExpectSuccess, misses[0].Expectation)
+
+	// the next run is projected even though discovery never handed it out
+	misses, _ = w.Check(runTime.AddDate(0, 0, 1).Add(5*time.Minute), history)
+	assert.Equal(t, 1, len(misses), "Expected the next run to be expected")
+	assert.True(t, runTime.AddDate(0, 0, 1).Equal(misses[0].Scheduled))
+}
+
+func TestCheckOnTime(t *testing.T) {
+	runTime, _ := time.Parse(time.RFC3339, "2020-04-23T02:00:00-06:00")
+	history := &h.Memory{}
+	job := testJob(runTime)
+	w := &Watchdog{}
+	w.Track([]j.Job{job}, false, runTime)
+	saveRun(history, job, runTime.Add(30*time.Second), runTime.Add(time.Hour), nil)
+	misses, err := w.Check(runTime.AddDate(0, 0, 1).Add(-time.Minute), history)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(misses), "No miss expected")
+}
+
+func TestCheckLateAndFailed(t *testing.T) {
+	runTime, _ := time.Parse(time.RFC3339, "2020-04-23T05:00:00-06:00")
+	history := &h.Memory{}
+	job := testJob(runTime)
+	w := &Watchdog{}
+	w.Track([]j.Job{job}, false, runTime)
+	saveRun(history, job, runTime.Add(10*time.Minute), runTime.Add(20*time.Minute), fmt.Errorf("boom"))
+	misses, _ := w.Check(runTime.Add(time.Hour), history)
+	assert.Equal(t, 2, len(misses), "Expected a late start and a missed deadline")
+	assert.Contains(t, misses[0].Detail, "started 10m0s late")
+	assert.Contains(t, misses[1].Detail, "had not succeeded by 2020-04-23T06:00:00-06:00")
+}
+
+func TestTrackFullForgets(t *testing.T) {
+	runTime, _ := time.Parse(time.RFC3339, "2020-04-23T02:00:00-06:00")
+	w := &Watchdog{}
+	w.Track([]j.Job{testJob(runTime)}, true, runTime)
+	w.Track([]j.Job{}, true, runTime)
+	misses, _ := w.Check(runTime.Add(time.Hour), &h.Memory{})
+	assert.Equal(t, 0, len(misses), "Expected the deleted job to be forgotten")
+}
+
+func TestParseFailure(t *testing.T) {
+	_, err := Parse(j.Job{StartWithin: "soon"})
+	assert.NotNil(t, err, "Error expected")
+	_, err = Parse(j.Job{SucceedBy: "6am"})
+	assert.NotNil(t, err, "Error expected")
+	exp, err := Parse(j.Job{SucceedBy: "06:00"})
+	assert.Nil(t, err)
+	runTime, _ := time.Parse(time.RFC3339, "2020-04-23T07:00:00-06:00")
+	assert.Equal(t, "2020-04-24T06:00:00-06:00", exp.Deadline(runTime).Format(time.RFC3339), "Expected the next day's deadline")
+}
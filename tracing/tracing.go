@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/keenfury/axenda/util"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/credentials"
+)
+
+/*
+OpenTelemetry spans of the scheduler, one trace per minute tick:
+
+	ProcessMinute
+		GetJobs (discovery, jobs)
+		StartJob (token, run_id)
+			RunJob (runner) => traceparent header / grpc metadata => the target's spans
+		CompleteJob (token, run_id)
+
+The trace context reaches the runners on the job (job.TraceParent, W3C traceparent), they send it on to the target:
+the traceparent header of the API runner, the traceparent metadata of the GRPC runner.  Manual, workflow and hook
+runs start their own trace at RunJob.
+
+Exporters (SCH_TRACE_EXPORTER):
+- otlp: OTLP over grpc to SCH_TRACE_OTLP_ENDPOINT (defaults to localhost:4317), TLS with the SCH_TLS_* settings
+  unless SCH_TRACE_OTLP_INSECURE is "true"
+- stdout: pretty printed json on stdout, for local testing
+- not set: no spans are recorded and no traceparent is sent
+*/
+
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdOut = "stdout"
+
+	serviceName = "axenda"
+	tracerName  = "github.com/keenfury/axenda"
+)
+
+var propagator = propagation.TraceContext{}
+
+// Setup: set the global tracer provider with the exporter, shutdown flushes the spans waiting, call it before exiting
+func Setup(exporter, endpoint string, insecure bool) (shutdown func(context.Context) error, err error) {
+	shutdown = func(context.Context) error { return nil }
+	otel.SetTextMapPropagator(propagator)
+	var spanExporter sdktrace.SpanExporter
+	switch exporter {
+	case "":
+		return
+	case ExporterStdOut:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+		if insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		} else {
+			tlsConfig, errTLS := util.TLSConfig()
+			if errTLS != nil {
+				err = errTLS
+				return
+			}
+			opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+		}
+		// the connection is made in the background, an unreachable collector doesn't stop the scheduler
+		spanExporter, err = otlptracegrpc.New(context.Background(), opts...)
+	default:
+		err = fmt.Errorf("Unknown trace exporter: %q", exporter)
+	}
+	if err != nil {
+		return
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	shutdown = provider.Shutdown
+	return
+}
+
+// Start: a span child of the one in ctx, end it with End
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End: end the span, marked as failed with the error if any
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceParent: the W3C traceparent of the span in ctx, empty if none
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// Context: a context carrying the remote span of the traceparent, the background one when empty or invalid
+func Context(traceParent string) context.Context {
+	if len(traceParent) == 0 {
+		return context.Background()
+	}
+	return propagator.Extract(context.Background(), propagation.MapCarrier{"traceparent": traceParent})
+}
+
+// Inject: set the trace context of ctx in carrier e.g. propagation.HeaderCarrier for http headers
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	propagator.Inject(ctx, carrier)
+}
@@ -0,0 +1,39 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraceParentRoundTrip(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	ctx, parent := Start(context.Background(), "ProcessMinute")
+	traceParent := TraceParent(ctx)
+	assert.Regexp(t, `^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`, traceParent)
+	_, child := Start(Context(traceParent), "RunJob")
+	End(child, nil)
+	parent.End()
+	spans := recorder.Ended()
+	assert.Equal(t, 2, len(spans))
+	assert.Equal(t, parent.SpanContext().TraceID(), spans[0].SpanContext().TraceID(), "Expected the same trace")
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID(), "Expected RunJob a child of ProcessMinute")
+}
+
+func TestContextEmpty(t *testing.T) {
+	assert.Equal(t, "", TraceParent(Context("")))
+	assert.Equal(t, "", TraceParent(Context("not-a-traceparent")))
+}
+
+func TestSetupUnknownExporter(t *testing.T) {
+	_, err := Setup("zipkin", "", false)
+	assert.NotNil(t, err)
+	shutdown, err := Setup("", "", false)
+	assert.Nil(t, err)
+	assert.Nil(t, shutdown(context.Background()))
+}
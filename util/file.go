@@ -0,0 +1,58 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic: write to a temp file in the same directory, fsync and rename it over fileName so a crash never
+// leaves a half written file, the directory is synced for the rename to be durable
+func WriteFileAtomic(fileName string, data []byte, perm os.FileMode) error {
+	return WriteFileAtomicCheck(fileName, data, perm, nil)
+}
+
+// WriteFileAtomicCheck: WriteFileAtomic calling check right before the rename, an error from check leaves fileName
+// as it is
+func WriteFileAtomicCheck(fileName string, data []byte, perm os.FileMode, check func() error) (err error) {
+	dir := filepath.Dir(fileName)
+	if info, errStat := os.Stat(fileName); errStat == nil {
+		perm = info.Mode().Perm()
+	}
+	tmp, errTemp := ioutil.TempFile(dir, "."+filepath.Base(fileName)+".tmp")
+	if errTemp != nil {
+		err = errTemp
+		return
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	if _, err = tmp.Write(data); err != nil {
+		return
+	}
+	if err = tmp.Sync(); err != nil {
+		return
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		return
+	}
+	if check != nil {
+		if err = check(); err != nil {
+			return
+		}
+	}
+	if err = os.Rename(tmp.Name(), fileName); err != nil {
+		return
+	}
+	if d, errOpen := os.Open(dir); errOpen == nil {
+		d.Sync()
+		d.Close()
+	}
+	return
+}
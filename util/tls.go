@@ -0,0 +1,107 @@
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/keenfury/axenda/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// the http client is kept per tls settings so connections are reused
+var httpClient = struct {
+	sync.Mutex
+	key    string
+	client *http.Client
+}{}
+
+// tlsSettings: the SCH_TLS_* settings read at once, a reload can change them
+type tlsSettings struct {
+	caFile, certFile, keyFile, insecureSkipVerify string
+}
+
+func currentTLS() tlsSettings {
+	values := config.GetAll(&config.TLSCAFile, &config.TLSCertFile, &config.TLSKeyFile, &config.TLSInsecureSkipVerify)
+	return tlsSettings{caFile: values[0], certFile: values[1], keyFile: values[2], insecureSkipVerify: values[3]}
+}
+
+func (s tlsSettings) enabled() bool {
+	return len(s.caFile) > 0 || len(s.certFile) > 0 || s.insecureSkipVerify == "true"
+}
+
+// TLSEnabled: true once any of the SCH_TLS_* settings is set
+func TLSEnabled() bool {
+	return currentTLS().enabled()
+}
+
+// TLSConfig: the tls config from the SCH_TLS_* settings, nil if none are set
+func TLSConfig() (*tls.Config, error) {
+	return tlsConfig(currentTLS())
+}
+
+func tlsConfig(s tlsSettings) (cfg *tls.Config, err error) {
+	if !s.enabled() {
+		return
+	}
+	cfg = &tls.Config{InsecureSkipVerify: s.insecureSkipVerify == "true"}
+	if len(s.caFile) > 0 {
+		bCA, errRead := ioutil.ReadFile(s.caFile)
+		if errRead != nil {
+			err = errRead
+			return
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(bCA) {
+			err = fmt.Errorf("No certificate found in: %s", s.caFile)
+			return
+		}
+	}
+	if len(s.certFile) > 0 {
+		cert, errLoad := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+		if errLoad != nil {
+			err = errLoad
+			return
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return
+}
+
+// HTTPClient: the client used by SimpleRequest, the default client unless tls is set
+func HTTPClient() (*http.Client, error) {
+	settings := currentTLS()
+	if !settings.enabled() {
+		return http.DefaultClient, nil
+	}
+	key := fmt.Sprintf("%s|%s|%s|%s", settings.caFile, settings.certFile, settings.keyFile, settings.insecureSkipVerify)
+	httpClient.Lock()
+	defer httpClient.Unlock()
+	if httpClient.client == nil || httpClient.key != key {
+		cfg, errTLS := tlsConfig(settings)
+		if errTLS != nil {
+			return nil, errTLS
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = cfg
+		httpClient.client = &http.Client{Transport: transport}
+		httpClient.key = key
+	}
+	return httpClient.client, nil
+}
+
+// GRPCDialOption: transport credentials when tls is set, else insecure
+func GRPCDialOption() (grpc.DialOption, error) {
+	cfg, errTLS := TLSConfig()
+	if errTLS != nil {
+		return nil, errTLS
+	}
+	if cfg == nil {
+		return grpc.WithInsecure(), nil
+	}
+	return grpc.WithTransportCredentials(credentials.NewTLS(cfg)), nil
+}
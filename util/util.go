@@ -7,30 +7,72 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/keenfury/axenda/clock"
 	"github.com/keenfury/axenda/config"
 )
 
+// TruncateTimeToMinute: the minute of t in UTC or the time zone if set, t is converted before it is truncated
 func TruncateTimeToMinute(t time.Time) time.Time {
-	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, GetLocation(t))
+	t = t.In(GetLocation(t))
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, t.Location())
 }
 
 func GetLocation(t time.Time) *time.Location {
-	if config.UseUTC == "true" {
+	if config.Get(&config.UseUTC) == "true" {
 		return time.UTC
 	}
+	if loc := config.GetLocation(); loc != nil {
+		return loc
+	}
 	return t.Location()
 }
 
+// IsHookURL: the hook entry (on_success, on_failure, always) is a runner url instead of a job token
+func IsHookURL(target string) bool {
+	return strings.Contains(target, "://")
+}
+
+// Clock: where GetNow reads the time from, set it to a clock.Fake to control time in tests
+var Clock clock.Clock = clock.Real{}
+
 func GetNow() time.Time {
-	if config.UseUTC == "true" {
-		return time.Now().UTC()
+	return NowFrom(Clock)
+}
+
+// NowFrom: the time of the given clock (util.Clock if nil) in UTC or the time zone if set
+func NowFrom(c clock.Clock) time.Time {
+	if c == nil {
+		c = Clock
 	}
-	return time.Now()
+	if config.Get(&config.UseUTC) == "true" {
+		return c.Now().UTC()
+	}
+	if loc := config.GetLocation(); loc != nil {
+		return c.Now().In(loc)
+	}
+	return c.Now()
 }
 
 func SimpleRequest(mode, url string, bodyIn, bodyOut interface{}, expectedCode int, hdrArgs map[string]string) (err error) {
+	body, errRequest := Request(mode, url, bodyIn, expectedCode, hdrArgs)
+	if errRequest != nil {
+		err = errRequest
+		return
+	}
+	if bodyOut != nil {
+		err = json.Unmarshal(body, bodyOut)
+	}
+	return
+}
+
+// the bytes of the response read when the status code is not the expected one
+const maxErrorBody = 64 << 10
+
+// Request: like SimpleRequest the body of the response is returned, also (the start of it) with an unexpected code
+func Request(mode, url string, bodyIn interface{}, expectedCode int, hdrArgs map[string]string) (body []byte, err error) {
 	var readerIn io.Reader
 	if bodyIn != nil {
 		bBodyIn, errMarshal := json.Marshal(bodyIn)
@@ -50,25 +92,22 @@ func SimpleRequest(mode, url string, bodyIn, bodyOut interface{}, expectedCode i
 			req.Header.Add(k, v)
 		}
 	}
-	resp, errResp := http.DefaultClient.Do(req)
+	client, errClient := HTTPClient()
+	if errClient != nil {
+		err = errClient
+		return
+	}
+	resp, errResp := client.Do(req)
 	if errResp != nil {
 		err = errResp
 		return
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != expectedCode {
+		body, _ = ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
 		err = fmt.Errorf("Unexpected code: %d, wanted: %d, reason: %s", resp.StatusCode, expectedCode, resp.Status)
 		return
 	}
-	body, errRead := ioutil.ReadAll(resp.Body)
-	if errRead != nil {
-		err = errRead
-		return
-	}
-	if bodyOut != nil {
-		if err = json.Unmarshal(body, bodyOut); err != nil {
-			return
-		}
-	}
+	body, err = ioutil.ReadAll(resp.Body)
 	return
 }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/keenfury/axenda/metrics"
+	"github.com/keenfury/axenda/notify"
+	"github.com/keenfury/axenda/sla"
+)
+
+// watchdog: the SLA expectations of the jobs (start_within, succeed_by), see the sla package
+var watchdog = &sla.Watchdog{}
+
+// slaChecking: a check is running, a slow history store doesn't stack them up
+var slaChecking int32
+
+// CheckSLAs: called every minute, refresh the jobs from a JobStore and alert on the expectations missed
+func CheckSLAs(now time.Time) {
+	if !atomic.CompareAndSwapInt32(&slaChecking, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&slaChecking, 0)
+	if store, ok := CurrentDiscovery().(JobStore); ok {
+		jobs, errList := store.ListJobs()
+		if errList != nil {
+			// keep expecting the jobs known so far
+			logAdapter.Error("CheckSLAs: unable to list jobs", "error", errList)
+		} else {
+			watchdog.Track(jobs, true, now)
+		}
+	}
+	misses, errCheck := watchdog.Check(now, CurrentHistory())
+	if errCheck != nil {
+		logAdapter.Error("CheckSLAs: unable to read history", "error", errCheck)
+	}
+	for _, miss := range misses {
+		AlertMiss(miss, now)
+	}
+}
+
+// AlertMiss: log the missed expectation and send it to the notifiers
+func AlertMiss(miss sla.Miss, now time.Time) {
+	logAdapter.Warn("SLA: expectation missed", "token", miss.Token, "job_name", miss.JobName, "expectation", miss.Expectation, "scheduled_time", miss.Scheduled, "detail", miss.Detail)
+	metrics.SLAMisses.WithLabelValues(miss.Expectation).Inc()
+	alert := notify.Alert{Kind: notify.KindMissed, Token: miss.Token, JobName: miss.JobName, Error: miss.Detail, At: now, Recipients: miss.Job.Notify}
+	SendAlert(alert)
+}
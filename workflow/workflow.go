@@ -0,0 +1,385 @@
+package workflow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	j "github.com/keenfury/axenda/job"
+)
+
+/*
+Workflows are DAGs built from the depends_on tokens of the jobs:
+
+	extract => transform-a, transform-b, transform-c => load
+
+	{"token":"extract", "run_time":..., "frequency":4, ...}
+	{"token":"transform-a", "depends_on":["extract"], ...}
+	{"token":"load", "depends_on":["transform-a","transform-b","transform-c"], ...}
+
+A job with depends_on never runs on its own schedule, it runs when its upstream jobs succeeded.  A root (a job other
+jobs depend on, without depends_on itself) runs on its schedule and starts a workflow Run made of every job
+downstream of it.  A job of the run fires once all of its upstream jobs within the run succeeded, when one fails
+everything downstream of it is skipped.  A job can only be downstream of one root: the runs are per root, a fan in of
+the workflows of two roots would fire as soon as either root is done, so NewGraph rejects it.
+*/
+
+const (
+	NodePending = "Pending"
+	NodeRunning = "Running"
+	NodeSuccess = "Success"
+	NodeFailed  = "Failed"
+	NodeSkipped = "Skipped"
+
+	RunRunning   = "Running"
+	RunSucceeded = "Succeeded"
+	RunFailed    = "Failed"
+)
+
+type (
+	Graph struct {
+		upstream   map[string][]string
+		downstream map[string][]string
+	}
+
+	Node struct {
+		Token    string    `json:"token"`
+		Upstream []string  `json:"upstream,omitempty"`
+		Status   string    `json:"status"`
+		Start    time.Time `json:"start_time,omitempty"`
+		End      time.Time `json:"end_time,omitempty"`
+		Error    string    `json:"error,omitempty"`
+	}
+
+	Run struct {
+		ID     string           `json:"id"`
+		Root   string           `json:"root"`
+		Status string           `json:"status"`
+		Start  time.Time        `json:"start_time"`
+		End    time.Time        `json:"end_time,omitempty"`
+		Nodes  map[string]*Node `json:"nodes"`
+	}
+
+	// Tracker: the runs in progress and the last finished ones
+	Tracker struct {
+		MaxFinished int
+
+		mu   sync.Mutex
+		runs []*Run
+	}
+)
+
+// NewGraph: the DAG of the jobs, fails on a dependency to an unknown token or a cycle
+func NewGraph(jobs []j.Job) (g *Graph, err error) {
+	g = &Graph{upstream: map[string][]string{}, downstream: map[string][]string{}}
+	known := map[string]bool{}
+	for _, job := range jobs {
+		known[job.Token] = true
+	}
+	problems := []string{}
+	for _, job := range jobs {
+		for _, up := range job.DependsOn {
+			if up == job.Token {
+				problems = append(problems, fmt.Sprintf("%s: depends on itself", job.Token))
+				continue
+			}
+			if !known[up] {
+				problems = append(problems, fmt.Sprintf("%s: depends on unknown job: %s", job.Token, up))
+				continue
+			}
+			g.upstream[job.Token] = append(g.upstream[job.Token], up)
+			g.downstream[up] = append(g.downstream[up], job.Token)
+		}
+	}
+	if cycle := g.cycle(); len(cycle) > 0 {
+		problems = append(problems, fmt.Sprintf("cycle: %s", strings.Join(cycle, " => ")))
+	} else {
+		problems = append(problems, g.fanIns()...)
+	}
+	if len(problems) > 0 {
+		err = fmt.Errorf("Invalid workflow: %s", strings.Join(problems, "; "))
+	}
+	return
+}
+
+// Upstream: the tokens the job depends on
+func (g *Graph) Upstream(token string) []string {
+	return g.upstream[token]
+}
+
+// Downstream: the tokens depending on the job
+func (g *Graph) Downstream(token string) []string {
+	return g.downstream[token]
+}
+
+// IsRoot: the job starts a workflow, others depend on it and it doesn't depend on any
+func (g *Graph) IsRoot(token string) bool {
+	return len(g.downstream[token]) > 0 && len(g.upstream[token]) == 0
+}
+
+// Descendants: every token downstream of the token, sorted
+func (g *Graph) Descendants(token string) (tokens []string) {
+	seen := map[string]bool{}
+	var visit func(string)
+	visit = func(t string) {
+		for _, down := range g.downstream[t] {
+			if !seen[down] {
+				seen[down] = true
+				tokens = append(tokens, down)
+				visit(down)
+			}
+		}
+	}
+	visit(token)
+	sort.Strings(tokens)
+	return
+}
+
+// fanIns: a problem per job downstream of more than one root, sorted
+func (g *Graph) fanIns() (problems []string) {
+	roots := map[string][]string{}
+	for token := range g.downstream {
+		if !g.IsRoot(token) {
+			continue
+		}
+		for _, down := range g.Descendants(token) {
+			roots[down] = append(roots[down], token)
+		}
+	}
+	for token, tokenRoots := range roots {
+		if len(tokenRoots) > 1 {
+			sort.Strings(tokenRoots)
+			problems = append(problems, fmt.Sprintf("%s: downstream of more than one root: %s", token, strings.Join(tokenRoots, ", ")))
+		}
+	}
+	sort.Strings(problems)
+	return
+}
+
+// cycle: the first cycle found as a path of tokens ending where it started, nil if none
+func (g *Graph) cycle() []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	path := []string{}
+	var found []string
+	var visit func(string) bool
+	visit = func(t string) bool {
+		state[t] = visiting
+		path = append(path, t)
+		for _, down := range g.downstream[t] {
+			switch state[down] {
+			case visiting:
+				for i := range path {
+					if path[i] == down {
+						found = append(append([]string{}, path[i:]...), down)
+						break
+					}
+				}
+				return true
+			case unvisited:
+				if visit(down) {
+					return true
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[t] = visited
+		return false
+	}
+	tokens := []string{}
+	for t := range g.downstream {
+		tokens = append(tokens, t)
+	}
+	// stable error messages
+	sort.Strings(tokens)
+	for _, t := range tokens {
+		if state[t] == unvisited && visit(t) {
+			return found
+		}
+	}
+	return nil
+}
+
+// NewRun: a run of the workflow started by root, the root is running
+func NewRun(g *Graph, id, root string, start time.Time) *Run {
+	run := &Run{ID: id, Root: root, Status: RunRunning, Start: start, Nodes: map[string]*Node{}}
+	run.Nodes[root] = &Node{Token: root, Status: NodeRunning, Start: start}
+	for _, token := range g.Descendants(root) {
+		run.Nodes[token] = &Node{Token: token, Upstream: g.Upstream(token), Status: NodePending}
+	}
+	return run
+}
+
+// Finish: the job of the run is over (err is its error if any), returns the tokens now ready to fire, those are
+// set running
+func (r *Run) Finish(token string, err error, at time.Time) (ready []string) {
+	node, ok := r.Nodes[token]
+	if !ok {
+		return
+	}
+	node.End = at
+	node.Status = NodeSuccess
+	if err != nil {
+		node.Status = NodeFailed
+		node.Error = err.Error()
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, t := range r.tokens() {
+			n := r.Nodes[t]
+			if n.Status != NodePending {
+				continue
+			}
+			status := r.upstreamStatus(n)
+			switch status {
+			case NodeSuccess:
+				n.Status = NodeRunning
+				n.Start = at
+				ready = append(ready, t)
+				changed = true
+			case NodeFailed, NodeSkipped:
+				n.Status = NodeSkipped
+				n.End = at
+				changed = true
+			}
+		}
+	}
+	r.finishRun(at)
+	return
+}
+
+// Done: no job of the run is pending or running
+func (r *Run) Done() bool {
+	for _, n := range r.Nodes {
+		if n.Status == NodePending || n.Status == NodeRunning {
+			return false
+		}
+	}
+	return true
+}
+
+// upstreamStatus: NodeSuccess when all the upstream jobs in the run succeeded, NodeFailed/NodeSkipped if one did not
+// make it, else NodePending
+func (r *Run) upstreamStatus(n *Node) string {
+	status := NodeSuccess
+	for _, up := range n.Upstream {
+		upNode, ok := r.Nodes[up]
+		if !ok {
+			// not part of the run, the jobs changed since it started
+			continue
+		}
+		switch upNode.Status {
+		case NodeFailed, NodeSkipped:
+			return NodeFailed
+		case NodePending, NodeRunning:
+			status = NodePending
+		}
+	}
+	return status
+}
+
+func (r *Run) finishRun(at time.Time) {
+	if !r.Done() {
+		return
+	}
+	r.End = at
+	r.Status = RunSucceeded
+	for _, n := range r.Nodes {
+		if n.Status != NodeSuccess {
+			r.Status = RunFailed
+		}
+	}
+}
+
+func (r *Run) tokens() []string {
+	tokens := make([]string, 0, len(r.Nodes))
+	for t := range r.Nodes {
+		tokens = append(tokens, t)
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+// copy: a deep copy, safe to hand out
+func (r *Run) copy() Run {
+	c := *r
+	c.Nodes = make(map[string]*Node, len(r.Nodes))
+	for t, n := range r.Nodes {
+		nc := *n
+		c.Nodes[t] = &nc
+	}
+	return c
+}
+
+// Start: track a new run
+func (t *Tracker) Start(run *Run) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.runs = append(t.runs, run)
+	t.prune()
+}
+
+// Finish: the job of the run is over, see Run.Finish
+func (t *Tracker) Finish(id, token string, err error, at time.Time) (ready []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, run := range t.runs {
+		if run.ID == id {
+			ready = run.Finish(token, err, at)
+			break
+		}
+	}
+	t.prune()
+	return
+}
+
+// Runs: a copy of the tracked runs, oldest first
+func (t *Tracker) Runs() []Run {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	runs := make([]Run, 0, len(t.runs))
+	for _, run := range t.runs {
+		runs = append(runs, run.copy())
+	}
+	return runs
+}
+
+// Get: a copy of the run with the id
+func (t *Tracker) Get(id string) (run Run, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, r := range t.runs {
+		if r.ID == id {
+			return r.copy(), true
+		}
+	}
+	return
+}
+
+// prune: drop the oldest finished runs over MaxFinished
+func (t *Tracker) prune() {
+	if t.MaxFinished <= 0 {
+		return
+	}
+	finished := 0
+	for _, run := range t.runs {
+		if run.Done() {
+			finished++
+		}
+	}
+	kept := t.runs[:0]
+	for _, run := range t.runs {
+		if run.Done() && finished > t.MaxFinished {
+			finished--
+			continue
+		}
+		kept = append(kept, run)
+	}
+	t.runs = kept
+}
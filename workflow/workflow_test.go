@@ -0,0 +1,103 @@
+package workflow
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	j "github.com/keenfury/axenda/job"
+	"github.com/stretchr/testify/assert"
+)
+
+func testJobs() []j.Job {
+	return []j.Job{
+		{Token: "EXTRACT"},
+		{Token: "TRANSFORMA", DependsOn: []string{"EXTRACT"}},
+		{Token: "TRANSFORMB", DependsOn: []string{"EXTRACT"}},
+		{Token: "LOAD", DependsOn: []string{"TRANSFORMA", "TRANSFORMB"}},
+		{Token: "SINGLE"},
+	}
+}
+
+func TestNewGraphSuccess(t *testing.T) {
+	g, err := NewGraph(testJobs())
+	assert.Nil(t, err, "No error expected")
+	assert.True(t, g.IsRoot("EXTRACT"))
+	assert.False(t, g.IsRoot("LOAD"), "A job with depends_on is not a root")
+	assert.False(t, g.IsRoot("SINGLE"), "A job without dependents is not a root")
+	assert.Equal(t, []string{"LOAD", "TRANSFORMA", "TRANSFORMB"}, g.Descendants("EXTRACT"))
+}
+
+func TestNewGraphCycleFailure(t *testing.T) {
+	jobs := []j.Job{
+		{Token: "A", DependsOn: []string{"C"}},
+		{Token: "B", DependsOn: []string{"A"}},
+		{Token: "C", DependsOn: []string{"B"}},
+	}
+	_, err := NewGraph(jobs)
+	assert.NotNil(t, err, "Error expected")
+	assert.Contains(t, err.Error(), "cycle: A => B => C => A")
+}
+
+func TestNewGraphUnknownFailure(t *testing.T) {
+	jobs := []j.Job{{Token: "A", DependsOn: []string{"MISSING", "A"}}}
+	_, err := NewGraph(jobs)
+	assert.NotNil(t, err, "Error expected")
+	assert.Contains(t, err.Error(), "A: depends on unknown job: MISSING")
+	assert.Contains(t, err.Error(), "A: depends on itself")
+}
+
+func TestNewGraphFanInFailure(t *testing.T) {
+	jobs := []j.Job{
+		{Token: "EXTRACTA"},
+		{Token: "EXTRACTB"},
+		{Token: "TRANSFORM", DependsOn: []string{"EXTRACTA"}},
+		{Token: "LOAD", DependsOn: []string{"TRANSFORM", "EXTRACTB"}},
+	}
+	_, err := NewGraph(jobs)
+	assert.NotNil(t, err, "Error expected")
+	assert.Contains(t, err.Error(), "LOAD: downstream of more than one root: EXTRACTA, EXTRACTB")
+	assert.NotContains(t, err.Error(), "TRANSFORM:")
+}
+
+func TestRunFinishSuccess(t *testing.T) {
+	g, _ := NewGraph(testJobs())
+	at := time.Now()
+	run := NewRun(g, "RUN1", "EXTRACT", at)
+	assert.Equal(t, []string{"TRANSFORMA", "TRANSFORMB"}, run.Finish("EXTRACT", nil, at), "Expected the fan out")
+	assert.Nil(t, run.Finish("TRANSFORMA", nil, at), "LOAD waits for TRANSFORMB")
+	assert.Equal(t, []string{"LOAD"}, run.Finish("TRANSFORMB", nil, at), "Expected the fan in")
+	assert.Equal(t, RunRunning, run.Status)
+	run.Finish("LOAD", nil, at)
+	assert.True(t, run.Done())
+	assert.Equal(t, RunSucceeded, run.Status)
+}
+
+func TestRunFinishFailure(t *testing.T) {
+	g, _ := NewGraph(testJobs())
+	at := time.Now()
+	run := NewRun(g, "RUN1", "EXTRACT", at)
+	run.Finish("EXTRACT", nil, at)
+	assert.Nil(t, run.Finish("TRANSFORMA", fmt.Errorf("boom"), at), "Nothing runs after a failure")
+	assert.Equal(t, NodeSkipped, run.Nodes["LOAD"].Status, "Expected downstream to be skipped")
+	assert.False(t, run.Done(), "TRANSFORMB is still running")
+	run.Finish("TRANSFORMB", nil, at)
+	assert.Equal(t, RunFailed, run.Status)
+	assert.Equal(t, "boom", run.Nodes["TRANSFORMA"].Error)
+}
+
+func TestTrackerPrune(t *testing.T) {
+	g, _ := NewGraph(testJobs())
+	at := time.Now()
+	tracker := &Tracker{MaxFinished: 1}
+	for _, id := range []string{"RUN1", "RUN2", "RUN3"} {
+		tracker.Start(NewRun(g, id, "EXTRACT", at))
+		tracker.Finish(id, "EXTRACT", fmt.Errorf("boom"), at)
+	}
+	tracker.Start(NewRun(g, "RUN4", "EXTRACT", at))
+	runs := tracker.Runs()
+	assert.Equal(t, 2, len(runs), "Expected the last finished run and the running one")
+	assert.Equal(t, "RUN3", runs[0].ID)
+	_, ok := tracker.Get("RUN1")
+	assert.False(t, ok, "Expected the oldest run pruned")
+}
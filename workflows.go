@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	h "github.com/keenfury/axenda/history"
+	j "github.com/keenfury/axenda/job"
+	"github.com/keenfury/axenda/metrics"
+	"github.com/keenfury/axenda/util"
+	wf "github.com/keenfury/axenda/workflow"
+)
+
+/*
+Workflow executor on top of RunJobs, see the workflow package for the rules
+- CheckForJobs leaves out the jobs with depends_on, they only run within a workflow
+- RunJobs starts a run when a workflow root fires (StartWorkflow) and reports its result (FinishWorkflowJob)
+- the jobs ready next are run through the runner (RunWorkflowJob) and report their result the same way
+- the runs are kept in memory, on the admin api: GET /workflows and GET /workflows/{id}
+*/
+
+// how many finished runs are kept
+const maxFinishedWorkflows = 100
+
+var workflows = &wf.Tracker{MaxFinished: maxFinishedWorkflows}
+
+// WorkflowGraph: the DAG of all the jobs, only a JobStore discovery lists them all, for the others it is empty
+func WorkflowGraph() (*wf.Graph, error) {
+	store, ok := CurrentDiscovery().(JobStore)
+	if !ok {
+		return wf.NewGraph(nil)
+	}
+	jobs, errJobs := store.ListJobs()
+	if errJobs != nil {
+		return nil, errJobs
+	}
+	return wf.NewGraph(jobs)
+}
+
+// StartWorkflow: start a run when the job is a workflow root, the run's id is returned, empty if it is not a root
+func StartWorkflow(job j.Job, start time.Time) string {
+	graph, errGraph := WorkflowGraph()
+	if errGraph != nil {
+		logAdapter.Error("StartWorkflow: invalid workflow", "token", job.Token, "error", errGraph)
+		return ""
+	}
+	if !graph.IsRoot(job.Token) {
+		return ""
+	}
+	run := wf.NewRun(graph, h.NewRunID(), job.Token, start)
+	workflows.Start(run)
+	logAdapter.Info("Workflow: run started", "workflow_run", run.ID, "token", job.Token, "job_name", job.JobName)
+	return run.ID
+}
+
+// FinishWorkflowJob: the job of the run is over, the jobs ready next are run
+func FinishWorkflowJob(runID, token string, errRun error) {
+	if len(runID) == 0 {
+		return
+	}
+	ready := workflows.Finish(runID, token, errRun, util.GetNow())
+	if run, ok := workflows.Get(runID); ok && run.Status != wf.RunRunning {
+		logAdapter.Info("Workflow: run "+strings.ToLower(run.Status), "workflow_run", run.ID, "token", run.Root)
+	}
+	if len(ready) == 0 {
+		return
+	}
+	jobs, errJobs := ListAllJobs(util.GetNow())
+	for _, readyToken := range ready {
+		job, errFind := findIn(jobs, readyToken, errJobs)
+		if errFind == nil && !job.Active {
+			errFind = fmt.Errorf("Job is inactive")
+		}
+		if errFind != nil {
+			logAdapter.Error("Workflow: unable to run job", "workflow_run", runID, "token", readyToken, "error", errFind)
+			FinishWorkflowJob(runID, readyToken, errFind)
+			continue
+		}
+		go RunWorkflowJob(runID, job)
+	}
+}
+
+// RunWorkflowJob: run a job of the run through the runner, like a manual run its schedule is left alone
+func RunWorkflowJob(runID string, job j.Job) {
+	runner, history, done := acquireAdapters()
+	defer done()
+	job.Result = &j.RunResult{}
+	record := h.NewRecord(job, runner.WhichRunner(), util.GetNow())
+	record.Workflow = runID
+	errRun := RunnerRun(runner, job)
+	if errRun != nil {
+		logAdapter.Error("RunWorkflowJob: run failed", "workflow_run", runID, "token", job.Token, "job_name", job.JobName, "runner", record.Runner, "run_id", record.RunID, "error", errRun)
+	}
+	record.Finish(util.GetNow(), errRun)
+	record.SetResult(job.Result)
+	metrics.ObserveDispatch(record.Runner, record.End.Sub(record.Start), 0)
+	if errSave := history.Save(record); errSave != nil {
+		logAdapter.Error("RunWorkflowJob: unable to save history", "token", job.Token, "run_id", record.RunID, "error", errSave)
+	}
+	FinishWorkflowJob(runID, job.Token, errRun)
+	NotifyRun(job, errRun)
+	RunHooks(job, errRun, nil)
+}
+
+// CheckWorkflow: the DAG of the store's jobs once changed is valid
+func CheckWorkflow(store JobStore, change func([]j.Job) []j.Job) error {
+	jobs, errList := store.ListJobs()
+	if errList != nil {
+		return errList
+	}
+	_, errGraph := wf.NewGraph(change(jobs))
+	return errGraph
+}
+
+// withJob: the jobs with job added or replacing the one with the same token
+func withJob(job j.Job) func([]j.Job) []j.Job {
+	return func(jobs []j.Job) []j.Job {
+		for i := range jobs {
+			if jobs[i].Token == job.Token {
+				jobs[i] = job
+				return jobs
+			}
+		}
+		return append(jobs, job)
+	}
+}
+
+// withoutJob: the jobs without the token
+func withoutJob(token string) func([]j.Job) []j.Job {
+	return func(jobs []j.Job) []j.Job {
+		kept := []j.Job{}
+		for _, job := range jobs {
+			if job.Token != token {
+				kept = append(kept, job)
+			}
+		}
+		return kept
+	}
+}
+
+func AdminWorkflowsHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method not allowed"))
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(req.URL.Path, "/workflows"), "/")
+	if len(id) == 0 {
+		writeJSON(w, http.StatusOK, workflows.Runs())
+		return
+	}
+	run, ok := workflows.Get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("Workflow run not found: %s", id))
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
+
+func findIn(jobs []j.Job, token string, errJobs error) (job j.Job, err error) {
+	if errJobs != nil {
+		err = errJobs
+		return
+	}
+	for _, js := range jobs {
+		if js.Token == token {
+			job = js
+			return
+		}
+	}
+	err = fmt.Errorf("Job not found: %s", token)
+	return
+}